@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/config"
+	"io"
+	"net/http"
+	"time"
+)
+
+// check validates the configuration and probes each upstream API, printing a
+// line per check. The exit code is 1 when any check fails.
+func check(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	userID := fs.Int("user-id", 1, "user fetched from the upstream APIs by the probes")
+	configOnly := fs.Bool("config-only", false, "only validate the configuration, without probing upstreams")
+
+	cfg, code := loadConfig(fs, args, stderr)
+	if cfg == nil {
+		return code
+	}
+	// the specs config.Load leaves to the components built from them
+	probes, probesErr := upstreamProbes(cfg, *userID)
+	_, subsErr := webhookSubscriptions(cfg)
+	_, tiersErr := rateLimitTiers(cfg)
+	if err := errors.Join(probesErr, subsErr, tiersErr); err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	source := cfg.File
+	if source == "" {
+		source = "defaults, env and flags"
+	}
+	fmt.Fprintf(stdout, "ok    config (%s)\n", source)
+	if *configOnly {
+		return 0
+	}
+
+	failed := false
+	for _, p := range probes {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.AggTimeout)
+		start := time.Now()
+		err := p.probe(ctx)
+		cancel()
+
+		if err != nil {
+			failed = true
+			fmt.Fprintf(stdout, "FAIL  %s %s: %v\n", p.name, p.url, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "ok    %s %s (%s)\n", p.name, p.url, time.Since(start).Round(time.Millisecond))
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+type upstreamProbe struct {
+	name  string
+	url   string
+	probe func(ctx context.Context) error
+}
+
+// upstreamProbes fetches userID through the same fetchers the server uses,
+// bypassing breakers and limits so a probe always reaches the upstream.
+func upstreamProbes(cfg *config.Config, userID int) ([]upstreamProbe, error) {
+	client := &http.Client{Timeout: cfg.HTTPTimeout, Transport: upstreamTransport}
+	users, usersErr := upstreamUserFetcher(cfg, client)
+	posts, postsErr := upstreamPostsFetcher(cfg, client)
+	if err := errors.Join(usersErr, postsErr); err != nil {
+		return nil, err
+	}
+
+	return []upstreamProbe{
+		{"users", cfg.UsersBaseURL, func(ctx context.Context) error {
+			_, err := users.Fetch(ctx, userID)
+			return err
+		}},
+		{"posts", cfg.PostsBaseURL, func(ctx context.Context) error {
+			_, err := posts.Fetch(ctx, userID)
+			return err
+		}},
+	}, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/export"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+// exportSummaries writes the summaries of every user, or of -ids, to -out.
+// Failed users go to -failed as NDJSON, or are listed on stderr; the exit
+// code is 1 when any user failed.
+func exportSummaries(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "csv, ndjson or parquet (default: from the -out extension, else csv)")
+	out := fs.String("out", "", "output file (default stdout)")
+	failed := fs.String("failed", "", "write failed users to this NDJSON file (default: list them on stderr)")
+	ids := fs.String("ids", "", `users to export, e.g. "1-5,8" (default: every user)`)
+	concurrency := fs.Int("concurrency", 4, fmt.Sprintf("summaries aggregated at once (at most %d)", export.MaxConcurrency))
+	token := fs.String("token", "", "export as the principal of this API token; email needs the read:pii scope")
+
+	cfg, code := loadConfig(fs, args, stderr)
+	if cfg == nil {
+		return code
+	}
+	if err := initCLILogger(cfg, stderr); err != nil {
+		fmt.Fprintf(stderr, "initializing logger: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	opts := export.Options{Concurrency: *concurrency}
+	name := *format
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(*out), ".")
+		if _, err := export.ParseFormat(name); err != nil {
+			name = string(export.CSV)
+		}
+	}
+	f, err := export.ParseFormat(name)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts.Format = f
+	if *ids != "" {
+		if opts.IDs, err = export.ParseIDs(*ids); err != nil {
+			fmt.Fprintf(stderr, "invalid -ids: %v\n", err)
+			return 2
+		}
+	}
+	if opts.IDs == nil && cfg.UsersSource == "graphql" {
+		fmt.Fprintln(stderr, "-ids is required: the GraphQL users source can't list users")
+		return 2
+	}
+	if *token != "" {
+		// token specs were already validated by config.Load
+		tokens, _ := auth.ParseTokens(cfg.AuthTokens)
+		principal, ok := tokens[*token]
+		if !ok {
+			fmt.Fprintln(stderr, "unknown -token")
+			return 2
+		}
+		opts.IncludeEmail = principal.HasScope("read:pii")
+	}
+
+	if opts.Aggregator, err = newAggregator(cfg, newComponents()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	w := stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	res, err := export.Run(ctx, w, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "export failed: %v\n", err)
+		return 1
+	}
+	if *out != "" {
+		fmt.Fprintf(stderr, "exported %d users to %s, %d failed\n", res.Exported, *out, len(res.Failures))
+	}
+	if len(res.Failures) == 0 {
+		return 0
+	}
+
+	if *failed == "" {
+		for _, f := range res.Failures {
+			fmt.Fprintf(stderr, "FAIL  user %d: %s: %s\n", f.ID, f.Code, f.Error)
+		}
+		return 1
+	}
+	file, err := os.Create(*failed)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	for _, f := range res.Failures {
+		if err := enc.Encode(f); err != nil {
+			fmt.Fprintf(stderr, "writing failed users: %v\n", err)
+			return 1
+		}
+	}
+	return 1
+}
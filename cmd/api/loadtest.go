@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/loadtest"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// loadTest drives an operation mix against a running server and prints the
+// report. The exit code is 1 when the error rate exceeds -max-error-rate.
+func loadTest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("url", "http://localhost:8080/query", "GraphQL endpoint")
+	mixFile := fs.String("mix", "", "YAML file with the operation mix (name, query, variables, weight)")
+	query := fs.String("query", "", "single operation to run instead of -mix")
+	variables := fs.String("variables", "", "variables of -query as a JSON object")
+	rate := fs.Float64("rate", 0, "requests per second; 0 runs -concurrency workers back to back")
+	concurrency := fs.Int("concurrency", 10, "workers, or max requests in flight with -rate")
+	warmup := fs.Duration("warmup", 5*time.Second, "unmeasured warmup")
+	duration := fs.Duration("duration", 30*time.Second, "measured duration")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request")
+	token := fs.String("token", "", "API token sent as a bearer token")
+	out := fs.String("out", "", "also write the report as JSON to this file")
+	compare := fs.String("compare", "", "JSON report of a previous run to compare with")
+	maxErrorRate := fs.Float64("max-error-rate", 1, "fail when the error rate (0 to 1) is above this")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	var mix []loadtest.Operation
+	switch {
+	case *mixFile != "" && *query != "":
+		fmt.Fprintln(stderr, "pass either -mix or -query")
+		return 2
+	case *mixFile != "":
+		var err error
+		if mix, err = loadtest.LoadMix(*mixFile); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	case *query != "":
+		op := loadtest.Operation{Name: "query", Query: *query, Weight: 1}
+		if *variables != "" {
+			if err := json.Unmarshal([]byte(*variables), &op.Variables); err != nil {
+				fmt.Fprintf(stderr, "invalid -variables: %v\n", err)
+				return 2
+			}
+		}
+		mix = []loadtest.Operation{op}
+	default:
+		fmt.Fprintln(stderr, "missing operations: pass -mix or -query")
+		return 2
+	}
+
+	var base *loadtest.Report
+	if *compare != "" {
+		var err error
+		if base, err = loadtest.LoadReport(*compare); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	headers := http.Header{}
+	if *token != "" {
+		headers.Set("Authorization", "Bearer "+*token)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fmt.Fprintf(stderr, "running %d operation(s) against %s for %s after %s warmup...\n", len(mix), *target, *duration, *warmup)
+	report, err := loadtest.Run(ctx, loadtest.Options{
+		URL:         *target,
+		Mix:         mix,
+		Headers:     headers,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Warmup:      *warmup,
+		Duration:    *duration,
+		Timeout:     *timeout,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid load test:\n%v\n", strings.TrimSpace(err.Error()))
+		return 2
+	}
+
+	report.Print(stdout)
+	if base != nil {
+		fmt.Fprintln(stdout)
+		report.PrintComparison(stdout, base)
+	}
+	if *out != "" {
+		if err := report.Save(*out); err != nil {
+			fmt.Fprintf(stderr, "writing report: %v\n", err)
+			return 1
+		}
+	}
+
+	if report.ErrorRate > *maxErrorRate {
+		fmt.Fprintf(stderr, "error rate %.2f%% above -max-error-rate %.2f%%\n", report.ErrorRate*100, *maxErrorRate*100)
+		return 1
+	}
+	return 0
+}
@@ -2,140 +2,428 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/cachecontrol"
 	"go-graphql-aggregator/internal/config"
 	"go-graphql-aggregator/internal/fetcher"
 	"go-graphql-aggregator/internal/graph"
+	"go-graphql-aggregator/internal/jobs"
 	"go-graphql-aggregator/internal/logger"
 	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/ratelimit"
+	"go-graphql-aggregator/internal/responsecache"
+	"go-graphql-aggregator/internal/snapshot"
+	"go-graphql-aggregator/internal/store"
+	"go-graphql-aggregator/internal/webhook"
+	"io"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
-	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
-	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
-func newServer(ctx context.Context, cfg *config.Config) *handler.Server {
-	httpClient := http.Client{
-		Timeout: cfg.HTTPTimeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   5 * time.Second,
-		},
+// upstreamTransport is shared by every server built, so config reloads reuse
+// pooled upstream connections.
+var upstreamTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:        100,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 5 * time.Second,
+}
+
+// caches and upstreams track the components of the current server for the
+// admin endpoints; publish replaces them with those of each server in use.
+var (
+	caches    = cache.NewRegistry()
+	upstreams = fetcher.NewRegistry()
+)
+
+// localStore is opened by serve when STORE_FILE is set; each server published
+// points the store syncs at its own fetchers.
+var localStore *store.Store
+
+// jobManager runs the summary jobs of serve; the other commands have none.
+var jobManager *jobs.Manager
+
+// webhookManager delivers the webhooks of serve; the other commands have none.
+var webhookManager *webhook.Manager
+
+// currentAggregator is the aggregator of the server in use, published by
+// publish for the endpoints other than /query.
+var currentAggregator atomic.Pointer[aggregator.Aggregator]
+
+// currentResponseCache is the response cache of the server in use, if any,
+// published by publish and invalidated by invalidateSummaries.
+var currentResponseCache atomic.Pointer[responsecache.Cache]
+
+// invalidateSummaries drops the cached responses holding summaries or users,
+// once the store synced or the webhook detector saw them change.
+func invalidateSummaries() {
+	if c := currentResponseCache.Load(); c != nil {
+		c.Invalidate("UserSummary", "User")
 	}
+}
 
-	userFetcher := &fetcher.HTTPUserFetcher{
-		Client:  &httpClient,
-		BaseURL: cfg.UsersBaseURL,
+// components are the aggregator, caches and upstream clients of a server built
+// by newServer, which publish makes current once the server is in use.
+type components struct {
+	aggregator *aggregator.Aggregator
+	caches     *cache.Registry
+	upstreams  *fetcher.Registry
+	responses  *responsecache.Cache
+}
+
+func newComponents() *components {
+	return &components{caches: cache.NewRegistry(), upstreams: fetcher.NewRegistry()}
+}
+
+// upstreamClient wraps client with the response cache, circuit breaker and
+// limits of an upstream: fetcher → conditional cache (if enabled) → breaker →
+// limiter → chaos (if enabled) → HTTP, or the upstream's cassette when
+// recording or replaying. They are registered in comps, carrying over the
+// state of those of the current server.
+func upstreamClient(comps *components, name, baseURL string, client fetcher.HTTPClient, rps float64, maxInFlight int, cfg *config.Config) (fetcher.HTTPClient, error) {
+	path := fetcher.CassettePath(cfg.UpstreamCassetteDir, name)
+	switch cfg.UpstreamCassetteMode {
+	case "record":
+		var scrub []string
+		for h := range strings.SplitSeq(cfg.UpstreamCassetteScrub, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				scrub = append(scrub, h)
+			}
+		}
+		recorder, err := fetcher.NewRecordingClient(client, path, scrub...)
+		if err != nil {
+			return nil, fmt.Errorf("recording upstream %s: %w", name, err)
+		}
+		client = recorder
+	case "replay":
+		replayer, err := fetcher.NewReplayingClient(path)
+		if err != nil {
+			return nil, fmt.Errorf("replaying upstream %s: %w", name, err)
+		}
+		client = replayer
 	}
-	postsFetcher := &fetcher.HTTPPostsFetcher{
-		Client:  &httpClient,
-		BaseURL: cfg.PostsBaseURL,
+
+	if cfg.EnableChaos {
+		chaos := fetcher.NewChaosClient(name, client)
+		// rules set through the admin survive config reloads
+		if prev := upstreams.Chaos(name); prev != nil && prev.Rules().Enabled() {
+			chaos.SetRules(prev.Rules())
+		}
+		comps.upstreams.AddChaos(chaos)
+		client = chaos
 	}
 
+	limited := fetcher.NewLimitedClient(name, client, rps, maxInFlight)
+	breaker := fetcher.NewCircuitBreaker(name, limited, cfg.BreakerThreshold, cfg.BreakerCooldown)
+	breaker.Target = baseURL
+	// so do open circuits, forced or not, while the upstream stays the same
+	if prev := upstreams.Breaker(name); prev != nil && prev.Target == baseURL {
+		breaker.Restore(prev.Status())
+	}
+	comps.upstreams.AddLimiter(limited)
+	comps.upstreams.AddBreaker(breaker)
+	if cfg.UpstreamCacheSize == 0 {
+		return breaker, nil
+	}
+
+	cached := fetcher.NewConditionalClient(name, breaker, cfg.UpstreamCacheSize)
+	comps.caches.Register("upstream-"+name, cached)
+	return cached, nil
+}
+
+// newSchema builds the executable schema with the aggregator and resolvers
+// configured by cfg, registering its components in comps.
+func newSchema(cfg *config.Config, comps *components) (graphql.ExecutableSchema, *aggregator.Aggregator, error) {
+	agg, err := newAggregator(cfg, comps)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolver := &graph.Resolver{Aggregator: agg, Jobs: jobManager, WebhookManager: webhookManager}
+	if localStore != nil {
+		resolver.Store = localStore
+	}
+	return graph.NewExecutableSchema(graph.NewConfig(resolver)), agg, nil
+}
+
+// newAggregator builds the fetchers and aggregator configured by cfg.
+func newAggregator(cfg *config.Config, comps *components) (*aggregator.Aggregator, error) {
+	userFetcher, postsFetcher, err := newFetchers(cfg, comps)
+	if err != nil {
+		return nil, err
+	}
 	agg := &aggregator.Aggregator{
 		UserFetcher:  userFetcher,
 		PostsFetcher: postsFetcher,
 		Timeout:      cfg.AggTimeout,
 	}
+	if localStore != nil {
+		agg.Store, agg.StoreMaxAge = localStore, cfg.StoreMaxAge
+	}
+	return agg, nil
+}
+
+// publish makes the aggregator of comps that of the REST, gRPC, export, jobs
+// and webhook endpoints, points the local store syncs at its fetchers, and
+// shows its caches and upstream clients in the admin. It is called once the
+// server of comps is in use, so a failed reload changes nothing.
+func publish(comps *components) {
+	caches.Replace(comps.caches)
+	upstreams.Replace(comps.upstreams)
+	currentResponseCache.Store(comps.responses)
+	if localStore != nil {
+		localStore.SetFetchers(comps.aggregator.UserFetcher, comps.aggregator.PostsFetcher)
+	}
+	currentAggregator.Store(comps.aggregator)
+}
+
+// newFetchers builds the upstream fetchers, or the snapshot ones when
+// SnapshotMode is exclusive, falling back to the snapshot when it is fallback.
+func newFetchers(cfg *config.Config, comps *components) (fetcher.UserFetcher, fetcher.PostsFetcher, error) {
+	var snap *snapshot.Fetchers
+	if cfg.SnapshotMode != "off" {
+		s, err := snapshot.Load(cfg.SnapshotFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Log.Info("snapshot loaded", "file", cfg.SnapshotFile, "mode", cfg.SnapshotMode,
+			"createdAt", s.CreatedAt, "users", len(s.Users), "posts", len(s.Posts))
+		snap = snapshot.NewFetchers(s)
+		if cfg.SnapshotMode == "exclusive" {
+			return snap.Users(), snap.Posts(), nil
+		}
+	}
+
+	httpClient := http.Client{
+		Timeout:   cfg.HTTPTimeout,
+		Transport: upstreamTransport,
+	}
+
+	usersClient, err := upstreamClient(comps, "users", cfg.UsersBaseURL, &httpClient, cfg.UsersRPS, cfg.UsersMaxInFlight, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	postsClient, err := upstreamClient(comps, "posts", cfg.PostsBaseURL, &httpClient, cfg.PostsRPS, cfg.PostsMaxInFlight, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userFetcher, err := upstreamUserFetcher(cfg, usersClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	postsFetcher, err := upstreamPostsFetcher(cfg, postsClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	if snap != nil {
+		userFetcher = &snapshot.FallbackUserFetcher{Upstream: userFetcher, Snapshot: snap.Users()}
+		postsFetcher = &snapshot.FallbackPostsFetcher{Upstream: postsFetcher, Snapshot: snap.Posts()}
+	}
+	return userFetcher, postsFetcher, nil
+}
+
+// upstreamUserFetcher returns the REST or GraphQL users fetcher of cfg.
+func upstreamUserFetcher(cfg *config.Config, client fetcher.HTTPClient) (fetcher.UserFetcher, error) {
+	if cfg.UsersSource != "graphql" {
+		return &fetcher.HTTPUserFetcher{Client: client, BaseURL: cfg.UsersBaseURL}, nil
+	}
+	vars, err := fetcher.ParseGraphQLVariables(cfg.UsersGraphQLVariables)
+	if err != nil {
+		return nil, fmt.Errorf("usersGraphQLVariables: %w", err)
+	}
+	return &fetcher.GraphQLUserFetcher{
+		Client:   client,
+		Endpoint: cfg.UsersBaseURL,
+		Query:    fetcher.GraphQLQuery{Document: cfg.UsersGraphQLQuery, Variables: vars, Path: cfg.UsersGraphQLPath},
+	}, nil
+}
+
+// upstreamPostsFetcher returns the REST or GraphQL posts fetcher of cfg.
+func upstreamPostsFetcher(cfg *config.Config, client fetcher.HTTPClient) (fetcher.PostsFetcher, error) {
+	if cfg.PostsSource != "graphql" {
+		return &fetcher.HTTPPostsFetcher{Client: client, BaseURL: cfg.PostsBaseURL}, nil
+	}
+	vars, err := fetcher.ParseGraphQLVariables(cfg.PostsGraphQLVariables)
+	if err != nil {
+		return nil, fmt.Errorf("postsGraphQLVariables: %w", err)
+	}
+	return &fetcher.GraphQLPostsFetcher{
+		Client:   client,
+		Endpoint: cfg.PostsBaseURL,
+		Query:    fetcher.GraphQLQuery{Document: cfg.PostsGraphQLQuery, Variables: vars, Path: cfg.PostsGraphQLPath},
+	}, nil
+}
+
+// rateLimitTiers parses the rate limit tiers of cfg, none disabling limiting.
+func rateLimitTiers(cfg *config.Config) (map[string]ratelimit.Limit, error) {
+	tiers, err := ratelimit.ParseTiers(cfg.RateLimitTiers)
+	if err != nil {
+		return nil, fmt.Errorf("rateLimitTiers: %w", err)
+	}
+	if _, ok := tiers[middleware.DefaultTier]; !ok && len(tiers) > 0 {
+		return nil, fmt.Errorf("rateLimitTiers: must define the %q tier", middleware.DefaultTier)
+	}
+	return tiers, nil
+}
+
+// webhookSubscriptions parses the webhook subscriptions of cfg.
+func webhookSubscriptions(cfg *config.Config) ([]*webhook.Subscription, error) {
+	subs, err := webhook.ParseSubscriptions(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+// newServer builds the GraphQL server configured by cfg, and returns it with
+// its components for publish, or nil when it fails.
+func newServer(ctx context.Context, cfg *config.Config) (*handler.Server, *components) {
+	comps := newComponents()
+	schema, agg, err := newSchema(cfg, comps)
+	if err != nil {
+		logger.Log.Error("Server initialization failed", "error", err)
+		return nil, nil
+	}
 
 	select {
 	case <-ctx.Done():
 		logger.Log.Error("Server initialization cancelled", "error", ctx.Err())
-		return nil
+		return nil, nil
 	default:
 	}
+	comps.aggregator = agg
 
-	resolver := &graph.Resolver{Aggregator: agg}
-
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(schema)
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 
-	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	queryCache := cache.NewLRU[*ast.QueryDocument]("query", 1000)
+	comps.caches.Register("query", queryCache)
+	srv.SetQueryCache(queryCache)
 
-	if os.Getenv("ENABLE_INTROSPECTION") == "1" {
+	if cfg.EnableIntrospection {
 		srv.Use(extension.Introspection{})
 	}
-	if os.Getenv("ENABLE_APQ") == "1" {
-		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](100)})
+	if cfg.EnableAPQ {
+		apqCache := cache.NewLRU[string]("apq", 100)
+		comps.caches.Register("apq", apqCache)
+		srv.Use(extension.AutomaticPersistedQuery{Cache: apqCache})
 	}
+	if cfg.SnapshotMode != "off" {
+		srv.Use(snapshot.Extension{})
+	}
+	if cfg.ResponseCacheSize > 0 {
+		// its TTLs are the policies computed by the cachecontrol extension
+		responseCache := responsecache.New(cfg.ResponseCacheSize)
+		comps.caches.Register("response", responseCache)
+		comps.responses = responseCache
+		srv.Use(responseCache)
+	}
+	srv.Use(&cachecontrol.Extension{DefaultMaxAge: int(cfg.CacheControlDefaultMaxAge.Seconds())})
 
-	return srv
+	return srv, comps
 }
 
-func main() {
-	logger.Init()
-	logger.Log.Info("server starting...")
-
-	startupCtx, startupCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer startupCancel()
-
-	cfg := config.LoadConfig()
-
-	srvHandler := newServer(startupCtx, cfg)
-	if srvHandler == nil {
-		logger.Log.Error("server initialization failed")
-		os.Exit(1)
+// loggerOptions maps the logging settings of cfg; level and redaction rules
+// were validated by config.Load.
+func loggerOptions(cfg *config.Config) logger.Options {
+	level, _ := cfg.SlogLevel()
+	redact, _ := cfg.LogRedactRules()
+	return logger.Options{
+		Mode:             cfg.LogMode,
+		Level:            level,
+		File:             cfg.LogFile,
+		MaxSizeMB:        cfg.LogMaxSizeMB,
+		MaxAge:           cfg.LogMaxAge,
+		MaxBackups:       cfg.LogMaxBackups,
+		SampleFirst:      cfg.LogSampleFirst,
+		SampleThereafter: cfg.LogSampleThereafter,
+		Redact:           redact,
 	}
+}
 
-	http.Handle("/", middleware.LoggingAndRecoveryMiddleware(playground.Handler("GraphQL playground", "/query")))
-	http.Handle("/query", middleware.LoggingAndRecoveryMiddleware(srvHandler))
-
-	httpServer := &http.Server{
-		Addr:         ":" + cfg.ServerPort,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
-		Handler:      nil,
-	}
+const usage = `Usage: api [command] [flags]
 
-	serverErrCh := make(chan error, 1)
+Commands:
+  serve     run the GraphQL server (default)
+  query     run a GraphQL document in-process and print the JSON response
+  check     validate the configuration and probe the upstream APIs
+  schema    print the schema as SDL or introspection JSON
+  snapshot  download users, posts and comments into the local snapshot file
+  export    write user summaries as CSV, NDJSON or Parquet
+  loadtest  drive an operation mix against a server and report latencies and errors
 
-	go func() {
-		logger.Log.Info("server started", "port", cfg.ServerPort)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErrCh <- err
-		} else {
-			serverErrCh <- nil
-		}
-	}()
+serve, query, check, snapshot and export accept the config flags; run "api <command> -help" to list them.
+`
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	select {
-	case sig := <-sigCh:
-		logger.Log.Info("received OS signal, initiating shutdown", "signal", sig)
-	case err := <-serverErrCh:
-		if err != nil {
-			logger.Log.Error("server runtime error", "error", err)
-		} else {
-			logger.Log.Info("server stopped normally")
-		}
+// run dispatches args to a command and returns the process exit code. Without
+// a command, or when the first arg is a flag, the server is started, as
+// before commands existed.
+func run(args []string, stdout, stderr io.Writer) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
+	switch command {
+	case "serve":
+		return serve(args, stderr)
+	case "query":
+		return query(args, os.Stdin, stdout, stderr)
+	case "check":
+		return check(args, stdout, stderr)
+	case "schema":
+		return schema(args, stdout, stderr)
+	case "snapshot":
+		return takeSnapshot(args, stdout, stderr)
+	case "export":
+		return exportSummaries(args, stdout, stderr)
+	case "loadtest":
+		return loadTest(args, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+}
 
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		logger.Log.Error("error during server shutdown", "error", err)
-	} else {
-		logger.Log.Info("server shutdown completed")
+// loadConfig parses the config flags, plus the command's own flags already
+// registered on fs, printing problems to stderr. The returned exit code is
+// only meaningful when cfg is nil.
+func loadConfig(fs *flag.FlagSet, args []string, stderr io.Writer) (cfg *config.Config, code int) {
+	fs.SetOutput(stderr)
+	cfg, err := config.LoadFlags(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return nil, 1
 	}
+	return cfg, 0
 }
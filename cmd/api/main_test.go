@@ -1,12 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/cachecontrol"
 	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/store"
 	"go-graphql-aggregator/internal/test"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,8 +39,8 @@ func Test_NewServer_InitializesCorrectly(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cfg := config.LoadConfig()
-	srv := newServer(ctx, cfg)
+	cfg := config.Default()
+	srv, _ := newServer(ctx, cfg)
 	assert.NotNil(srv, "server should be created successfully")
 
 	req := httptest.NewRequest("GET", "/query", nil)
@@ -40,8 +56,8 @@ func Test_NewServer_ContextCancelled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	cfg := config.LoadConfig()
-	srv := newServer(ctx, cfg)
+	cfg := config.Default()
+	srv, _ := newServer(ctx, cfg)
 	assert.Nil(srv, "server should be nil if context is cancelled")
 }
 
@@ -51,8 +67,8 @@ func Test_Main_StartupAndShutdown(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	cfg := config.LoadConfig()
-	srv := newServer(ctx, cfg)
+	cfg := config.Default()
+	srv, _ := newServer(ctx, cfg)
 	assert.NotNil(srv, "server should initialize")
 
 	handler := http.NewServeMux()
@@ -72,4 +88,518 @@ func Test_Main_StartupAndShutdown(t *testing.T) {
 
 	testServer.CloseClientConnections()
 	time.Sleep(100 * time.Millisecond)
-}
\ No newline at end of file
+}
+
+func Test_Reloader_SwapsServerAndLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 6s\n"), 0o600))
+	args := []string{"-config", path}
+
+	cfg, err := config.Load(args)
+	assert.Nil(err)
+	srv, _ := newServer(context.Background(), cfg)
+	limiter, err := middleware.NewRateLimiter(cfg.RateLimitKey, nil, time.Minute)
+	assert.Nil(err)
+
+	h := newSwappableHandler(srv)
+	r := &reloader{args: args, cfg: cfg, handler: h, limiter: limiter}
+
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 7s\nlogLevel: debug\nrateLimitTiers: default:1:1\nserverPort: \"9999\"\n"), 0o600))
+	r.Reload("test")
+
+	assert.NotSame(srv, h.current.Load())
+	assert.Equal(7*time.Second, r.cfg.AggTimeout)
+	assert.Equal("8080", r.cfg.ServerPort, "port needs a restart")
+	assert.Equal(slog.LevelDebug, logger.Level())
+
+	limited := middleware.RateLimitMiddleware(limiter, h)
+	req := httptest.NewRequest("GET", "/query", nil)
+	w := httptest.NewRecorder()
+	limited.ServeHTTP(w, req)
+	assert.Equal("1", w.Header().Get("RateLimit-Limit"))
+
+	current := h.current.Load()
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 1s\n"), 0o600))
+	r.Reload("invalid")
+
+	assert.Same(current, h.current.Load(), "invalid config keeps the current server")
+	assert.Equal(7*time.Second, r.cfg.AggTimeout)
+
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 8s\nrateLimitTiers: gold:1:1\n"), 0o600))
+	r.Reload("invalid tiers")
+
+	assert.Same(current, h.current.Load(), "tiers without a default keep the current server")
+	assert.Equal(7*time.Second, r.cfg.AggTimeout)
+	logger.SetLevel(slog.LevelInfo)
+}
+
+func Test_Reloader_KeepsBreakersAndRestartSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 6s\n"), 0o600))
+	args := []string{"-config", path}
+
+	cfg, err := config.Load(args)
+	assert.Nil(err)
+	srv, comps := newServer(context.Background(), cfg)
+	publish(comps)
+	limiter, err := middleware.NewRateLimiter(cfg.RateLimitKey, nil, time.Minute)
+	assert.Nil(err)
+	r := &reloader{args: args, cfg: cfg, handler: newSwappableHandler(srv), limiter: limiter}
+	upstreams.Breaker("users").Open()
+	logger.SetLevel(slog.LevelWarn)
+	defer logger.SetLevel(slog.LevelInfo)
+
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 7s\njobWorkers: 9\nstoreSyncInterval: 1m\nrateLimitIdleTTL: 1h\n"), 0o600))
+	r.Reload("test")
+
+	assert.Equal(7*time.Second, r.cfg.AggTimeout)
+	assert.Equal(cfg.JobWorkers, r.cfg.JobWorkers)
+	assert.Equal(cfg.StoreSyncInterval, r.cfg.StoreSyncInterval)
+	assert.Equal(cfg.RateLimitIdleTTL, r.cfg.RateLimitIdleTTL)
+	status := upstreams.Breaker("users").Status()
+	assert.Equal(fetcher.BreakerOpen, status.State, "the breaker was rebuilt open")
+	assert.True(status.Forced)
+	assert.Equal(slog.LevelWarn, logger.Level(), "the level set at runtime stays without a LOG_LEVEL change")
+	upstreams.Breaker("users").Close()
+}
+
+func Test_Reloader_FailedReloadKeepsAggregator(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 6s\n"), 0o600))
+	args := []string{"-config", path}
+
+	cfg, err := config.Load(args)
+	assert.Nil(err)
+	srv, comps := newServer(context.Background(), cfg)
+	publish(comps)
+	limiter, err := middleware.NewRateLimiter(cfg.RateLimitKey, nil, time.Minute)
+	assert.Nil(err)
+	h := newSwappableHandler(srv)
+	r := &reloader{args: args, cfg: cfg, handler: h, limiter: limiter}
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 7s\nsnapshotMode: exclusive\nsnapshotFile: "+missing+"\n"), 0o600))
+	r.Reload("test")
+
+	assert.Same(srv, h.current.Load(), "the server can't be built without the snapshot")
+	assert.Same(comps.aggregator, currentAggregator.Load(), "the other endpoints keep the current aggregator")
+
+	breaker := upstreams.Breaker("users")
+	_, _ = newServer(context.Background(), cfg)
+	assert.Same(breaker, upstreams.Breaker("users"), "a server is only registered once published")
+
+	assert.Nil(os.WriteFile(path, []byte("aggTimeout: 7s\n"), 0o600))
+	r.Reload("test")
+	assert.NotSame(comps.aggregator, currentAggregator.Load())
+	assert.NotSame(breaker, upstreams.Breaker("users"))
+	assert.Equal(7*time.Second, currentAggregator.Load().Timeout)
+}
+
+func Test_Run_Query(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	usersURL, postsURL := upstream.UsersURL(), upstream.PostsURL()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"query", "-log-mode", "silent", "-users-url", usersURL, "-posts-url", postsURL,
+		"-variables", `{"id":1}`, `query($id: Int!) { userSummary(userId: $id) { name postCount } }`}, &stdout, &stderr)
+
+	assert.Equal(0, code, stderr.String())
+	assert.JSONEq(`{"data":{"userSummary":{"name":"Leanne Graham","postCount":10}}}`, stdout.String())
+}
+
+func Test_Run_QueryWithErrorsExitsNonZero(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	usersURL, postsURL := upstream.UsersURL(), upstream.PostsURL()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"query", "-log-mode", "silent", "-users-url", usersURL, "-posts-url", postsURL,
+		`{ userSummary(userId: 1) { email } }`}, &stdout, &stderr)
+
+	assert.Equal(1, code)
+	assert.Contains(stdout.String(), "UNAUTHENTICATED")
+}
+
+func Test_Run_QueryWithToken(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	usersURL, postsURL := upstream.UsersURL(), upstream.PostsURL()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "query.graphql")
+	assert.Nil(os.WriteFile(file, []byte(`{ userSummary(userId: 1) { email } }`), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"query", "-log-mode", "silent", "-users-url", usersURL, "-posts-url", postsURL,
+		"-auth-tokens", "t1|ops|USER|read:pii", "-token", "t1", "-file", file}, &stdout, &stderr)
+
+	assert.Equal(0, code, stderr.String())
+	assert.Contains(stdout.String(), "Sincere@april.biz")
+}
+
+func Test_Run_Check(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	usersURL, postsURL := upstream.UsersURL(), upstream.PostsURL()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"check", "-users-url", usersURL, "-posts-url", postsURL}, &stdout, &stderr)
+	assert.Equal(0, code, stdout.String())
+	assert.Contains(stdout.String(), "ok    users")
+	assert.Contains(stdout.String(), "ok    posts")
+
+	stdout.Reset()
+	code = run([]string{"check", "-users-url", usersURL + "/missing", "-posts-url", postsURL}, &stdout, &stderr)
+	assert.Equal(1, code)
+	assert.Contains(stdout.String(), "FAIL  users")
+
+	code = run([]string{"check", "-agg-timeout", "1ms"}, &stdout, &stderr)
+	assert.Equal(1, code)
+	assert.Contains(stderr.String(), "invalid configuration")
+
+	stderr.Reset()
+	code = run([]string{"check", "-config-only", "-users-source", "graphql", "-users-graphql-variables", `{"id":`,
+		"-webhook-check-interval", "0", "-webhooks", "http://hook", "-rate-limit-tiers", "gold:1"}, &stdout, &stderr)
+	assert.Equal(1, code)
+	assert.Contains(stderr.String(), "usersGraphQLVariables: variables must be a JSON object")
+	assert.Contains(stderr.String(), "webhooks:")
+	assert.Contains(stderr.String(), "rateLimitTiers:")
+}
+
+func Test_Run_Schema(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"schema"}, &stdout, &stderr))
+	assert.Contains(stdout.String(), "userSummary(userId: Int!): UserSummary!")
+
+	stdout.Reset()
+	assert.Equal(0, run([]string{"schema", "-format", "json"}, &stdout, &stderr))
+	var introspection struct {
+		Schema struct {
+			QueryType struct{ Name string } `json:"queryType"`
+		} `json:"__schema"`
+	}
+	assert.Nil(json.Unmarshal(stdout.Bytes(), &introspection))
+	assert.Equal("Query", introspection.Schema.QueryType.Name)
+}
+
+func Test_Run_UnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"bogus"}, &stdout, &stderr))
+}
+
+func Test_Run_QueryRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	dir := t.TempDir()
+	document := `{ userSummary(userId: 4) { name postCount } }`
+	args := []string{"query", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL(), "-cassette-dir", dir}
+
+	var recorded, stderr bytes.Buffer
+	assert.Equal(0, run(append(args, "-cassette-mode", "record", document), &recorded, &stderr), stderr.String())
+	assert.FileExists(filepath.Join(dir, "users.json"))
+	assert.FileExists(filepath.Join(dir, "posts.json"))
+
+	requests := upstream.Requests()
+	var replayed bytes.Buffer
+	assert.Equal(0, run(append(args, "-cassette-mode", "replay", document), &replayed, &stderr), stderr.String())
+	assert.JSONEq(recorded.String(), replayed.String())
+	assert.Equal(requests, upstream.Requests())
+
+	var unmatched bytes.Buffer
+	assert.Equal(1, run(append(args, "-cassette-mode", "replay", `{ userSummary(userId: 5) { name } }`), &unmatched, &stderr))
+	assert.Contains(unmatched.String(), "no recorded interaction")
+}
+
+func Test_Run_SnapshotExclusiveAndFallback(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"snapshot", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL(),
+		"-snapshot-file", file}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+	assert.Contains(stdout.String(), "10 users, 100 posts, 500 comments")
+
+	document := `{ userSummary(userId: 1) { name postCount } }`
+	for _, mode := range []string{"exclusive", "fallback"} {
+		var out bytes.Buffer
+		// nothing listens on the upstream URLs: only the snapshot can answer
+		code := run([]string{"query", "-log-mode", "silent", "-users-url", "http://127.0.0.1:1/users", "-posts-url", "http://127.0.0.1:1/posts",
+			"-snapshot-mode", mode, "-snapshot-file", file, document}, &out, &stderr)
+		assert.Equal(0, code, mode+": "+stderr.String())
+
+		var resp struct {
+			Data       json.RawMessage
+			Extensions struct {
+				Snapshot struct {
+					CreatedAt  string
+					AgeSeconds int64
+				}
+			}
+		}
+		assert.Nil(json.Unmarshal(out.Bytes(), &resp))
+		assert.JSONEq(`{"userSummary":{"name":"Leanne Graham","postCount":10}}`, string(resp.Data), mode)
+		assert.NotEmpty(resp.Extensions.Snapshot.CreatedAt, mode)
+		assert.GreaterOrEqual(resp.Extensions.Snapshot.AgeSeconds, int64(0), mode)
+	}
+
+	var out bytes.Buffer
+	code = run([]string{"query", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL(),
+		"-snapshot-mode", "fallback", "-snapshot-file", file, document}, &out, &stderr)
+	assert.Equal(0, code, stderr.String())
+	assert.NotContains(out.String(), "extensions", "upstreams answered, the snapshot was not used")
+}
+
+func Test_NewServer_ServesFromLocalStore(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "store.db"))
+	assert.Nil(err)
+	defer st.Close()
+	localStore = st
+	defer func() { localStore = nil }()
+
+	cfg := config.Default()
+	cfg.UsersBaseURL, cfg.PostsBaseURL = upstream.UsersURL(), upstream.PostsURL()
+	srv, comps := newServer(context.Background(), cfg)
+	assert.NotNil(srv)
+	publish(comps)
+	assert.Nil(st.Sync(context.Background()))
+
+	post := func(query string) string {
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":`+strconv.Quote(query)+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	requests := upstream.Requests()
+	assert.JSONEq(`{"data":{"userSummary":{"name":"Leanne Graham","postCount":10}}}`, post(`{ userSummary(userId: 1) { name postCount } }`))
+	assert.Equal(requests, upstream.Requests(), "summary served without calling the upstreams")
+
+	assert.JSONEq(`{"data":{"syncStatus":{"users":10,"posts":100,"summaries":10,"lastError":null}}}`,
+		post(`{ syncStatus { users posts summaries lastError } }`))
+}
+
+func Test_NewServer_SummaryJobs(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+
+	jm, err := jobs.NewManager(jobs.Options{Aggregator: currentAggregator.Load, TTL: time.Hour})
+	assert.Nil(err)
+	jobManager = jm
+	defer func() { jobManager = nil }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go jm.Run(ctx)
+
+	cfg := config.Default()
+	cfg.UsersBaseURL, cfg.PostsBaseURL = upstream.UsersURL(), upstream.PostsURL()
+	srv, comps := newServer(context.Background(), cfg)
+	assert.NotNil(srv)
+	publish(comps)
+
+	alice := &auth.Principal{Subject: "alice", Roles: []string{"USER"}}
+	request := func(p *auth.Principal, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":`+strconv.Quote(query)+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+	post := func(query string) map[string]any {
+		w := request(alice, query)
+		var resp struct{ Data map[string]any }
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+		return resp.Data
+	}
+
+	anonymous := request(nil, `mutation { startSummaryJob(userIds: [1]) { id } }`)
+	assert.Contains(anonymous.Body.String(), "UNAUTHENTICATED", "jobs require authentication")
+
+	started, _ := post(`mutation { startSummaryJob(userIds: [1, 2, 42]) { id status total } }`)["startSummaryJob"].(map[string]any)
+	if !assert.NotNil(started) {
+		return
+	}
+	assert.Equal(float64(3), started["total"])
+
+	var job map[string]any
+	for range 100 {
+		job, _ = post(`{ job(id: "` + started["id"].(string) + `") { status progress results { userId summary { name } } errors { userId code } } }`)["job"].(map[string]any)
+		if job["status"] == "COMPLETED" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal("COMPLETED", job["status"])
+	assert.Equal(float64(1), job["progress"])
+	assert.Len(job["results"], 2)
+	assert.Equal([]any{map[string]any{"userId": float64(42), "code": "NOT_FOUND"}}, job["errors"])
+
+	assert.Nil(post(`{ job(id: "unknown") { id } }`)["job"])
+}
+
+func Test_NewServer_ResponseCache(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+
+	cfg := config.Default()
+	cfg.UsersBaseURL, cfg.PostsBaseURL = upstream.UsersURL(), upstream.PostsURL()
+	cfg.ResponseCacheSize = 10
+	srv, comps := newServer(context.Background(), cfg)
+	publish(comps)
+	assert.NotNil(srv)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":"{ userSummary(userId: 1) { name postCount } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		assert.Contains(w.Body.String(), `"postCount":10`)
+	}
+
+	for _, s := range caches.Stats() {
+		if s.Name == "response" {
+			assert.Equal(1, s.Len)
+			assert.Equal(int64(1), s.Hits)
+			return
+		}
+	}
+	t.Error("response cache not registered")
+}
+
+func Test_InvalidateSummaries_DropsCachedResponses(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+
+	cfg := config.Default()
+	cfg.UsersBaseURL, cfg.PostsBaseURL = upstream.UsersURL(), upstream.PostsURL()
+	cfg.ResponseCacheSize = 10
+	srv, comps := newServer(context.Background(), cfg)
+	publish(comps)
+	assert.NotNil(srv)
+
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":"{ userSummary(userId: 1) { name postCount } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(1, currentResponseCache.Load().Stats().Len)
+
+	invalidateSummaries()
+	assert.Equal(0, currentResponseCache.Load().Stats().Len)
+}
+
+func Test_NewServer_AcceptsMultipartRequests(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+
+	cfg := config.Default()
+	cfg.UsersBaseURL, cfg.PostsBaseURL = upstream.UsersURL(), upstream.PostsURL()
+	srv, _ := newServer(context.Background(), cfg)
+	assert.NotNil(srv)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("operations", `{"query":"{ userSummary(userId: 1) { postCount } }"}`)
+	_ = mw.WriteField("map", `{}`)
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/query", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `"postCount":10`)
+}
+
+func Test_Run_ExportGraphQLUsersNeedsIDs(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"export", "-log-mode", "silent", "-users-source", "graphql", "-webhook-check-interval", "0"}, &stdout, &stderr)
+	assert.Equal(2, code)
+	assert.Contains(stderr.String(), "-ids is required")
+}
+
+func Test_Run_Export(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	dir := t.TempDir()
+	out, failed := filepath.Join(dir, "summaries.ndjson"), filepath.Join(dir, "failed.ndjson")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"export", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL(),
+		"-auth-tokens", "t1|ops|USER|read:pii", "-token", "t1", "-ids", "1-2,42", "-out", out, "-failed", failed}, &stdout, &stderr)
+	assert.Equal(1, code, "user 42 does not exist")
+	assert.Contains(stderr.String(), "exported 2 users to "+out+", 1 failed")
+
+	b, err := os.ReadFile(out)
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(lines, 2, "the format is inferred from the extension") {
+		assert.JSONEq(`{"id":1,"name":"Leanne Graham","email":"Sincere@april.biz","postCount":10}`, lines[0])
+	}
+	b, err = os.ReadFile(failed)
+	assert.Nil(err)
+	assert.Contains(string(b), `{"id":42,"code":"NOT_FOUND"`)
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"export", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL()}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+	assert.Equal(11, strings.Count(stdout.String(), "\n"), "a CSV header and every user")
+	assert.True(strings.HasPrefix(stdout.String(), "id,name,email,post_count\n1,Leanne Graham,,10\n"))
+}
+
+func Test_NewServer_DoesNotCacheSnapshotResponses(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"snapshot", "-log-mode", "silent", "-users-url", upstream.UsersURL(), "-posts-url", upstream.PostsURL(),
+		"-snapshot-file", file}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+
+	cfg := config.Default()
+	// nothing listens on the upstream URLs: only the snapshot can answer
+	cfg.UsersBaseURL, cfg.PostsBaseURL = "http://127.0.0.1:1/users", "http://127.0.0.1:1/posts"
+	cfg.SnapshotMode, cfg.SnapshotFile = "fallback", file
+	cfg.ResponseCacheSize = 10
+	srv, comps := newServer(context.Background(), cfg)
+	publish(comps)
+	assert.NotNil(srv)
+	handler := cachecontrol.Middleware(srv)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/query?query="+url.QueryEscape(`{ userSummary(userId: 1) { name postCount } }`), nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Contains(w.Body.String(), `"postCount":10`)
+		assert.Contains(w.Body.String(), `"snapshot"`)
+		assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	}
+
+	for _, s := range caches.Stats() {
+		if s.Name == "response" {
+			assert.Equal(0, s.Len)
+			assert.Equal(int64(0), s.Hits)
+			return
+		}
+	}
+	t.Error("response cache not registered")
+}
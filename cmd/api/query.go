@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/snapshot"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+)
+
+// query runs a GraphQL document against the in-process schema, without HTTP,
+// and prints the JSON response. The exit code is 1 when the response has errors.
+func query(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	file := fs.String("file", "", `read the document from this file ("-" = stdin)`)
+	variables := fs.String("variables", "", "variables as a JSON object")
+	operation := fs.String("operation", "", "operation to run, when the document has several")
+	token := fs.String("token", "", "run as the principal of this API token (see -auth-tokens)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api query [flags] [document]")
+		fs.PrintDefaults()
+	}
+
+	cfg, code := loadConfig(fs, args, stderr)
+	if cfg == nil {
+		return code
+	}
+	if err := initCLILogger(cfg, stderr); err != nil {
+		fmt.Fprintf(stderr, "initializing logger: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	params := &graphql.RawParams{OperationName: *operation}
+	switch {
+	case fs.NArg() > 0 && *file != "":
+		fmt.Fprintln(stderr, "pass the document either as an argument or with -file")
+		return 2
+	case fs.NArg() > 0:
+		params.Query = fs.Arg(0)
+	case *file == "-":
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "reading document: %v\n", err)
+			return 1
+		}
+		params.Query = string(b)
+	case *file != "":
+		b, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(stderr, "reading document: %v\n", err)
+			return 1
+		}
+		params.Query = string(b)
+	default:
+		fmt.Fprintln(stderr, "missing document: pass it as an argument or with -file")
+		return 2
+	}
+	if *variables != "" {
+		// numbers as json.Number, like the HTTP transports, so Int arguments coerce
+		dec := json.NewDecoder(strings.NewReader(*variables))
+		dec.UseNumber()
+		if err := dec.Decode(&params.Variables); err != nil {
+			fmt.Fprintf(stderr, "invalid -variables: %v\n", err)
+			return 2
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if *token != "" {
+		// token specs were already validated by config.Load
+		tokens, _ := auth.ParseTokens(cfg.AuthTokens)
+		principal, ok := tokens[*token]
+		if !ok {
+			fmt.Fprintln(stderr, "unknown -token")
+			return 2
+		}
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+
+	exec, err := newExecutor(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	resp := execute(ctx, exec, params)
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		fmt.Fprintf(stderr, "writing response: %v\n", err)
+		return 1
+	}
+	if len(resp.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// newExecutor builds an in-process executor of the schema for cfg, with the
+// same extensions the server enables.
+func newExecutor(cfg *config.Config) (*executor.Executor, error) {
+	schema, _, err := newSchema(cfg, newComponents())
+	if err != nil {
+		return nil, err
+	}
+	exec := executor.New(schema)
+	if cfg.EnableIntrospection {
+		exec.Use(extension.Introspection{})
+	}
+	if cfg.SnapshotMode != "off" {
+		exec.Use(snapshot.Extension{})
+	}
+	return exec, nil
+}
+
+// execute runs a single operation the way the HTTP transports do.
+func execute(ctx context.Context, exec *executor.Executor, params *graphql.RawParams) *graphql.Response {
+	ctx = graphql.StartOperationTrace(ctx)
+	now := graphql.Now()
+	params.ReadTime = graphql.TraceTiming{Start: now, End: now}
+
+	rc, errs := exec.CreateOperationContext(ctx, params)
+	if errs != nil {
+		return exec.DispatchError(graphql.WithOperationContext(ctx, rc), errs)
+	}
+	responses, ctx := exec.DispatchOperation(ctx, rc)
+	return responses(ctx)
+}
+
+// initCLILogger initializes the logger for commands whose stdout is their
+// result: logs go to stderr only, never to the server's log file.
+func initCLILogger(cfg *config.Config, stderr io.Writer) error {
+	opts := loggerOptions(cfg)
+	opts.File = ""
+	opts.Writer = stderr
+	return logger.Init(opts)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+)
+
+// swappableHandler serves each request with the GraphQL server current when the
+// request arrives, so in-flight requests finish with the settings they started with.
+type swappableHandler struct {
+	current atomic.Pointer[handler.Server]
+}
+
+func newSwappableHandler(srv *handler.Server) *swappableHandler {
+	h := &swappableHandler{}
+	h.current.Store(srv)
+	return h
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().ServeHTTP(w, r)
+}
+
+// reloader re-reads the configuration and applies it to new requests.
+type reloader struct {
+	mu      sync.Mutex
+	args    []string
+	cfg     *config.Config
+	handler *swappableHandler
+	limiter *middleware.RateLimiter
+}
+
+// Current returns the configuration in effect.
+func (r *reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// Reload loads the configuration again and swaps fetcher base URLs, timeouts,
+// log level and rate limits. An invalid configuration is logged and ignored.
+func (r *reloader) Reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.args)
+	if err != nil {
+		logger.Log.Error("config reload rejected, keeping current config", "reason", reason, "error", err)
+		return
+	}
+
+	changes := config.Diff(r.cfg, next)
+	if len(changes) == 0 {
+		logger.Log.Info("config reload: no changes", "reason", reason)
+		return
+	}
+	for _, c := range changes {
+		if c.RestartRequired {
+			logger.Log.Warn("config changed, restart required to apply", "setting", c.Setting, "old", c.Old, "new", c.New)
+			continue
+		}
+		logger.Log.Info("config changed", "setting", c.Setting, "old", c.Old, "new", c.New)
+	}
+	// settings that need a restart stay as they were, which the new ones
+	// must still be valid with
+	next.KeepRestartSettings(r.cfg)
+	if err := next.Validate(); err != nil {
+		logger.Log.Error("config reload rejected, keeping current config", "reason", reason, "error", err)
+		return
+	}
+
+	tiers, err := rateLimitTiers(next)
+	if err != nil {
+		logger.Log.Error("config reload rejected, keeping current config", "reason", reason, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv, comps := newServer(ctx, next)
+	if srv == nil {
+		logger.Log.Error("config reload failed building server, keeping current config", "reason", reason)
+		return
+	}
+
+	if err := r.limiter.Update(next.RateLimitKey, tiers); err != nil {
+		logger.Log.Error("config reload failed updating rate limits, keeping current config", "reason", reason, "error", err)
+		return
+	}
+
+	// the level may have been set at runtime, which only a new LOG_LEVEL overrides
+	if slices.ContainsFunc(changes, func(c config.Change) bool { return c.Setting == "LOG_LEVEL" }) {
+		level, _ := next.SlogLevel()
+		logger.SetLevel(level)
+	}
+	r.handler.current.Store(srv)
+	publish(comps)
+
+	r.cfg = next
+	logger.Log.Info("config reloaded", "reason", reason, "changes", len(changes))
+}
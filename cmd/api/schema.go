@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/graph"
+	"io"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+// introspectionQuery is the query GraphQL tooling runs to download a schema.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
+
+// schema prints the GraphQL schema as SDL or as introspection JSON. It needs
+// no config, since no resolver runs.
+func schema(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "sdl", "output format: sdl or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	es := graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{}))
+
+	switch *format {
+	case "sdl":
+		formatter.NewFormatter(stdout, formatter.WithIndent("  ")).FormatSchema(es.Schema())
+		return 0
+	case "json":
+		exec := executor.New(es)
+		exec.Use(extension.Introspection{})
+		resp := execute(context.Background(), exec, &graphql.RawParams{Query: introspectionQuery})
+		if len(resp.Errors) > 0 {
+			fmt.Fprintf(stderr, "introspection failed: %v\n", resp.Errors)
+			return 1
+		}
+
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resp.Data); err != nil {
+			fmt.Fprintf(stderr, "writing schema: %v\n", err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown -format %q: must be sdl or json\n", *format)
+		return 2
+	}
+}
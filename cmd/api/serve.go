@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/admin"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/cachecontrol"
+	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/export"
+	"go-graphql-aggregator/internal/grpcapi"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/rest"
+	"go-graphql-aggregator/internal/store"
+	"go-graphql-aggregator/internal/webhook"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/playground"
+	"google.golang.org/grpc"
+)
+
+// serve runs the GraphQL server until SIGINT/SIGTERM, reloading the config on SIGHUP.
+func serve(args []string, stderr io.Writer) int {
+	cfg, code := loadConfig(flag.NewFlagSet("serve", flag.ContinueOnError), args, stderr)
+	if cfg == nil {
+		return code
+	}
+
+	if err := logger.Init(loggerOptions(cfg)); err != nil {
+		fmt.Fprintf(stderr, "initializing logger: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+	logger.Log.Info("server starting...")
+	logger.Log.Info("config loaded", "config", cfg)
+
+	if cfg.StoreFile != "" {
+		st, err := store.Open(cfg.StoreFile)
+		if err != nil {
+			logger.Log.Error("local store failed", "error", err)
+			return 1
+		}
+		defer st.Close()
+		st.OnSync = invalidateSummaries
+		localStore = st
+		defer func() { localStore = nil }()
+	}
+
+	jobOpts := jobs.Options{
+		Aggregator:  currentAggregator.Load,
+		Workers:     cfg.JobWorkers,
+		Concurrency: cfg.JobConcurrency,
+		MaxUsers:    cfg.JobMaxUsers,
+		TTL:         cfg.JobTTL,
+	}
+	if cfg.JobPersist {
+		jobOpts.Persister = localStore
+	}
+	jm, err := jobs.NewManager(jobOpts)
+	if err != nil {
+		logger.Log.Error("job manager failed", "error", err)
+		return 1
+	}
+	jobManager = jm
+	defer func() { jobManager = nil }()
+
+	subs, err := webhookSubscriptions(cfg)
+	if err != nil {
+		logger.Log.Error("invalid webhook config", "error", err)
+		return 1
+	}
+	wm := webhook.NewManager(webhook.Options{
+		Client:      &http.Client{Timeout: cfg.HTTPTimeout, Transport: upstreamTransport},
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Backoff:     cfg.WebhookBackoff,
+	}, subs)
+	webhookManager = wm
+	defer func() { webhookManager = nil }()
+
+	startupCtx, startupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer startupCancel()
+
+	srvHandler, comps := newServer(startupCtx, cfg)
+	if srvHandler == nil {
+		logger.Log.Error("server initialization failed")
+		return 1
+	}
+	publish(comps)
+
+	// token specs were already validated by config.Load
+	tokens, _ := auth.ParseTokens(cfg.AuthTokens)
+	tiers, err := rateLimitTiers(cfg)
+	if err != nil {
+		logger.Log.Error("invalid rate limit config", "error", err)
+		return 1
+	}
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	defer runCancel()
+
+	limiter, err := middleware.NewRateLimiter(cfg.RateLimitKey, tiers, cfg.RateLimitIdleTTL)
+	if err != nil {
+		logger.Log.Error("invalid rate limit config", "error", err)
+		return 1
+	}
+	go limiter.Store().Run(runCtx, time.Minute)
+
+	storeDone := make(chan struct{})
+	if localStore != nil {
+		go func() {
+			defer close(storeDone)
+			localStore.Run(runCtx, cfg.StoreSyncInterval)
+		}()
+	} else {
+		close(storeDone)
+	}
+
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobManager.Run(runCtx)
+	}()
+
+	detectorDone := make(chan struct{})
+	if cfg.WebhookCheckInterval > 0 {
+		detector := &webhook.Detector{
+			Aggregator: currentAggregator.Load,
+			Publish: func(ev webhook.Event) {
+				invalidateSummaries()
+				wm.Publish(ev)
+			},
+			Active:      func() bool { return len(wm.Subscriptions()) > 0 },
+			Concurrency: 4,
+		}
+		go func() {
+			defer close(detectorDone)
+			detector.Run(runCtx, cfg.WebhookCheckInterval)
+		}()
+	} else {
+		close(detectorDone)
+	}
+
+	gqlHandler := newSwappableHandler(srvHandler)
+	reload := &reloader{args: args, cfg: cfg, handler: gqlHandler, limiter: limiter}
+	if cfg.File != "" {
+		go config.Watch(runCtx, cfg.File, 2*time.Second, func() { reload.Reload("config file changed") })
+	}
+
+	queryHandler := middleware.RateLimitMiddleware(limiter, cachecontrol.Middleware(gqlHandler))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", middleware.LoggingAndRecoveryMiddleware(playground.Handler("GraphQL playground", "/query")))
+	mux.Handle("/query", middleware.LoggingAndRecoveryMiddleware(middleware.AuthMiddleware(tokens, limiter, queryHandler)))
+
+	restHandler := rest.NewHandler(rest.Options{Aggregator: currentAggregator.Load, Store: localStore})
+	mux.Handle("/api/v1/", middleware.LoggingAndRecoveryMiddleware(middleware.AuthMiddleware(tokens, limiter, middleware.RateLimitMiddleware(limiter, restHandler))))
+	mux.Handle("/openapi.json", middleware.LoggingAndRecoveryMiddleware(restHandler))
+	mux.Handle("/export", middleware.LoggingAndRecoveryMiddleware(middleware.AuthMiddleware(tokens, limiter, middleware.RateLimitMiddleware(limiter, export.Handler(currentAggregator.Load)))))
+
+	httpServer := &http.Server{
+		Addr:         ":" + cfg.ServerPort,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+		Handler:      mux,
+	}
+
+	serverErrCh := make(chan error, 3)
+
+	var adminServer *http.Server
+	if cfg.AdminAddr != "" {
+		ln, err := admin.Listen(cfg.AdminAddr)
+		if err != nil {
+			logger.Log.Error("admin listener failed", "addr", cfg.AdminAddr, "error", err)
+			return 1
+		}
+		adminServer = &http.Server{
+			Handler:     middleware.LoggingAndRecoveryMiddleware(admin.NewHandler(admin.Options{Config: reload.Current, Caches: caches, Upstreams: upstreams})),
+			ReadTimeout: 15 * time.Second,
+			IdleTimeout: 60 * time.Second,
+		}
+		go func() {
+			logger.Log.Info("admin server started", "addr", cfg.AdminAddr)
+			if err := adminServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				serverErrCh <- fmt.Errorf("admin server: %w", err)
+			}
+		}()
+	}
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		ln, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			logger.Log.Error("grpc listener failed", "addr", cfg.GRPCAddr, "error", err)
+			return 1
+		}
+		grpcServer = grpcapi.NewServer(grpcapi.Options{Aggregator: currentAggregator.Load, Tokens: tokens, Limiter: limiter})
+		go func() {
+			logger.Log.Info("grpc server started", "addr", cfg.GRPCAddr)
+			if err := grpcServer.Serve(ln); err != nil {
+				serverErrCh <- fmt.Errorf("grpc server: %w", err)
+			}
+		}()
+	}
+
+	go func() {
+		logger.Log.Info("server started", "port", cfg.ServerPort)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErrCh <- err
+		} else {
+			serverErrCh <- nil
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	exitCode := 0
+wait:
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				logger.Log.Info("received SIGHUP, reloading config")
+				reload.Reload("SIGHUP")
+				continue
+			}
+			logger.Log.Info("received OS signal, initiating shutdown", "signal", sig)
+			break wait
+		case err := <-serverErrCh:
+			if err != nil {
+				logger.Log.Error("server runtime error", "error", err)
+				exitCode = 1
+			} else {
+				logger.Log.Info("server stopped normally")
+			}
+			break wait
+		}
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error("error during server shutdown", "error", err)
+	} else {
+		logger.Log.Info("server shutdown completed")
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error("error during admin server shutdown", "error", err)
+		}
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+	// stop syncing, running jobs and delivering webhooks before the store is closed
+	runCancel()
+	<-storeDone
+	<-jobsDone
+	<-detectorDone
+	wm.Close()
+	return exitCode
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/snapshot"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// takeSnapshot downloads every user, post and comment from the upstreams into
+// the snapshot file served by SNAPSHOT_MODE=fallback or exclusive.
+func takeSnapshot(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write (default -snapshot-file)")
+	commentsURL := fs.String("comments-url", "", `comments upstream (default: the posts URL with "/posts" replaced by "/comments"; "-" skips comments)`)
+
+	cfg, code := loadConfig(fs, args, stderr)
+	if cfg == nil {
+		return code
+	}
+	if *out == "" {
+		*out = cfg.SnapshotFile
+	}
+	if *out == "" {
+		fmt.Fprintln(stderr, "missing -out or -snapshot-file")
+		return 2
+	}
+
+	src := snapshot.Source{UsersURL: cfg.UsersBaseURL, PostsURL: cfg.PostsBaseURL, CommentsURL: *commentsURL}
+	switch src.CommentsURL {
+	case "-":
+		src.CommentsURL = ""
+	case "":
+		if base, ok := strings.CutSuffix(cfg.PostsBaseURL, "/posts"); ok {
+			src.CommentsURL = base + "/comments"
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	start := time.Now()
+	client := &http.Client{Timeout: cfg.HTTPTimeout, Transport: upstreamTransport}
+	s, err := snapshot.Download(ctx, client, src)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := s.Save(*out); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s: %d users, %d posts, %d comments (%s)\n",
+		*out, len(s.Users), len(s.Posts), len(s.Comments), time.Since(start).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/fakeupstream"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// fake-upstream serves the users, posts and comments fixtures with the
+// jsonplaceholder routes, for running the aggregator offline:
+//
+//	fake-upstream -addr :8081 -latency 50ms -error-rate 0.1
+//	api serve -users-url http://localhost:8081/users -posts-url http://localhost:8081/posts
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	dir := flag.String("fixtures", "", "directory with users.json, posts.json and comments.json (default: embedded)")
+	latency := flag.Duration("latency", 0, "delay added to every request")
+	jitter := flag.Duration("jitter", 0, "random extra delay, up to this much")
+	errorRate := flag.Float64("error-rate", 0, "fraction of requests failed, 0 to 1")
+	errorStatus := flag.Int("error-status", http.StatusInternalServerError, "status of failed requests")
+	seed := flag.Uint64("seed", 0, "seed for reproducible faults (0 = random)")
+	logMode := flag.String("log-mode", "text", "log format: text, json or silent")
+	flag.Parse()
+
+	if err := logger.Init(logger.Options{Mode: *logMode}); err != nil {
+		fmt.Fprintf(os.Stderr, "initializing logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	srv, err := fakeupstream.New(fakeupstream.Options{
+		Dir:    *dir,
+		Seed:   *seed,
+		Faults: fakeupstream.Faults{Latency: *latency, Jitter: *jitter, ErrorRate: *errorRate, ErrorStatus: *errorStatus},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starting fake upstream: %v\n", err)
+		os.Exit(1)
+	}
+
+	httpServer := &http.Server{
+		Addr:        *addr,
+		Handler:     middleware.LoggingAndRecoveryMiddleware(srv),
+		ReadTimeout: 15 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Log.Info("fake upstream started", "addr", *addr, "faults", srv.Faults(fakeupstream.AllResources))
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Log.Error("fake upstream failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Log.Info("fake upstream stopped")
+}
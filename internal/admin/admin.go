@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/logger"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Options wires the admin endpoints to the running server.
+type Options struct {
+	// Config returns the effective configuration.
+	Config    func() *config.Config
+	Caches    *cache.Registry
+	Upstreams *fetcher.Registry
+}
+
+var startedAt = time.Now()
+
+// NewHandler returns the admin endpoints. They are meant for a private
+// listener and perform no authentication.
+func NewHandler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("GET /runtime", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, runtimeStats())
+	})
+	mux.HandleFunc("GET /buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("build info not available"))
+			return
+		}
+		writeJSON(w, http.StatusOK, buildInfo(info))
+	})
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, opts.Config().Dump())
+	})
+	mux.Handle("/log-level", logger.LevelHandler())
+
+	mux.HandleFunc("GET /caches", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, opts.Caches.Stats())
+	})
+	mux.HandleFunc("POST /caches/purge", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if err := opts.Caches.Purge(name); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		logger.Log.Warn("cache purged via admin", "cache", name)
+		writeJSON(w, http.StatusOK, opts.Caches.Stats())
+	})
+
+	mux.HandleFunc("GET /upstreams", func(w http.ResponseWriter, r *http.Request) {
+		upstreams := map[string]any{
+			"breakers": opts.Upstreams.BreakerStatuses(),
+			"limiters": opts.Upstreams.LimiterStats(),
+		}
+		if opts.Config().EnableChaos {
+			upstreams["chaos"] = opts.Upstreams.ChaosStatuses()
+		}
+		writeJSON(w, http.StatusOK, upstreams)
+	})
+	mux.HandleFunc("GET /breakers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, opts.Upstreams.BreakerStatuses())
+	})
+	mux.HandleFunc("POST /breakers/{name}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		cb := opts.Upstreams.Breaker(r.PathValue("name"))
+		if cb == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown breaker %q", r.PathValue("name")))
+			return
+		}
+		switch action := r.PathValue("action"); action {
+		case "open":
+			cb.Open()
+		case "close":
+			cb.Close()
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown action %q, expected open or close", action))
+			return
+		}
+		logger.Log.Warn("circuit breaker changed via admin", "upstream", cb.Name, "action", r.PathValue("action"))
+		writeJSON(w, http.StatusOK, cb.Status())
+	})
+
+	mux.HandleFunc("GET /chaos", func(w http.ResponseWriter, r *http.Request) {
+		if !opts.Config().EnableChaos {
+			writeError(w, http.StatusNotFound, errChaosDisabled)
+			return
+		}
+		writeJSON(w, http.StatusOK, opts.Upstreams.ChaosStatuses())
+	})
+	mux.HandleFunc("/chaos/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if !opts.Config().EnableChaos {
+			writeError(w, http.StatusNotFound, errChaosDisabled)
+			return
+		}
+		cc := opts.Upstreams.Chaos(r.PathValue("name"))
+		if cc == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown upstream %q", r.PathValue("name")))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			rules, err := fetcher.ParseChaosRules(cc.Rules(), r.URL.Query())
+			if err == nil {
+				err = cc.SetRules(rules)
+			}
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			logger.Log.Warn("chaos rules changed via admin", "upstream", cc.Name, "query", r.URL.RawQuery)
+		case http.MethodDelete:
+			cc.SetRules(fetcher.ChaosRules{})
+			logger.Log.Warn("chaos disabled via admin", "upstream", cc.Name)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		writeJSON(w, http.StatusOK, cc.Status())
+	})
+
+	return mux
+}
+
+var errChaosDisabled = errors.New("chaos is disabled, set ENABLE_CHAOS")
+
+// Listen opens the admin listener on a TCP address or, with the "unix:"
+// prefix, on a unix socket (a stale socket file is removed first).
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("removing stale admin socket: %w", err)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+type runtimeInfo struct {
+	Uptime       string `json:"uptime"`
+	GoVersion    string `json:"goVersion"`
+	NumCPU       int    `json:"numCPU"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	Goroutines   int    `json:"goroutines"`
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	Sys          uint64 `json:"sys"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	NumGC        uint32 `json:"numGC"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+}
+
+func runtimeStats() runtimeInfo {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return runtimeInfo{
+		Uptime:       time.Since(startedAt).Round(time.Second).String(),
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		TotalAlloc:   m.TotalAlloc,
+		NumGC:        m.NumGC,
+		PauseTotalNs: m.PauseTotalNs,
+	}
+}
+
+func buildInfo(info *debug.BuildInfo) map[string]any {
+	settings := map[string]string{}
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+	deps := map[string]string{}
+	for _, d := range info.Deps {
+		deps[d.Path] = d.Version
+	}
+	return map[string]any{
+		"goVersion": info.GoVersion,
+		"path":      info.Path,
+		"version":   info.Main.Version,
+		"settings":  settings,
+		"deps":      deps,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,157 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/admin"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/config"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func newAdmin() (http.Handler, *cache.LRU[string], *fetcher.CircuitBreaker) {
+	cfg := config.Default()
+	cfg.AuthTokens = "s3cret|alice|ADMIN"
+
+	caches := cache.NewRegistry()
+	apq := cache.NewLRU[string]("apq", 10)
+	caches.Register("apq", apq)
+
+	upstreams := fetcher.NewRegistry()
+	cb := fetcher.NewCircuitBreaker("users", mock.NewMockHTTPClient("{}", http.StatusOK, nil), 5, time.Minute)
+	upstreams.AddBreaker(cb)
+
+	h := admin.NewHandler(admin.Options{
+		Config:    func() *config.Config { return cfg },
+		Caches:    caches,
+		Upstreams: upstreams,
+	})
+	return h, apq, cb
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func Test_Admin_ConfigMasksSecrets(t *testing.T) {
+	assert := assert.New(t)
+	h, _, _ := newAdmin()
+
+	w := serve(h, http.MethodGet, "/config")
+
+	var dump map[string]string
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Nil(json.NewDecoder(w.Body).Decode(&dump))
+	assert.Equal("****", dump["AUTH_TOKENS"])
+	assert.Equal("8080", dump["SERVER_PORT"])
+	assert.NotContains(w.Body.String(), "s3cret")
+}
+
+func Test_Admin_CacheStatsAndPurge(t *testing.T) {
+	assert := assert.New(t)
+	h, apq, _ := newAdmin()
+	apq.Add(context.Background(), "hash", "query { userSummary(userId: 1) { name } }")
+	apq.Get(context.Background(), "hash")
+
+	w := serve(h, http.MethodGet, "/caches")
+	var stats []cache.Stats
+	assert.Nil(json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal([]cache.Stats{{Name: "apq", Len: 1, Capacity: 10, Hits: 1}}, stats)
+
+	w = serve(h, http.MethodPost, "/caches/purge?name=apq")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(0, apq.Stats().Len)
+
+	w = serve(h, http.MethodPost, "/caches/purge?name=unknown")
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func Test_Admin_BreakerControl(t *testing.T) {
+	assert := assert.New(t)
+	h, _, cb := newAdmin()
+
+	w := serve(h, http.MethodPost, "/breakers/users/open")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(fetcher.BreakerOpen, cb.Status().State)
+
+	w = serve(h, http.MethodPost, "/breakers/users/close")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State)
+
+	assert.Equal(http.StatusNotFound, serve(h, http.MethodPost, "/breakers/comments/open").Code)
+	assert.Equal(http.StatusBadRequest, serve(h, http.MethodPost, "/breakers/users/toggle").Code)
+}
+
+func Test_Admin_RuntimeAndPprof(t *testing.T) {
+	assert := assert.New(t)
+	h, _, _ := newAdmin()
+
+	w := serve(h, http.MethodGet, "/runtime")
+	var stats map[string]any
+	assert.Nil(json.NewDecoder(w.Body).Decode(&stats))
+	assert.Greater(stats["goroutines"], float64(0))
+
+	assert.Equal(http.StatusOK, serve(h, http.MethodGet, "/debug/pprof/").Code)
+	assert.Equal(http.StatusOK, serve(h, http.MethodGet, "/log-level").Code)
+}
+
+func Test_Listen_UnixSocket(t *testing.T) {
+	assert := assert.New(t)
+	path := t.TempDir() + "/admin.sock"
+
+	ln, err := admin.Listen("unix:" + path)
+	assert.Nil(err)
+	assert.Equal("unix", ln.Addr().Network())
+	ln.Close()
+}
+
+func Test_Admin_Chaos(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.Default()
+	upstreams := fetcher.NewRegistry()
+	cc := fetcher.NewChaosClient("posts", mock.NewMockHTTPClient("[]", http.StatusOK, nil))
+	upstreams.AddChaos(cc)
+	h := admin.NewHandler(admin.Options{
+		Config:    func() *config.Config { return cfg },
+		Caches:    cache.NewRegistry(),
+		Upstreams: upstreams,
+	})
+
+	w := serve(h, http.MethodPut, "/chaos/posts?failPercent=100")
+	assert.Equal(http.StatusNotFound, w.Code, "chaos must be enabled in the config")
+	assert.False(cc.Rules().Enabled())
+
+	cfg.EnableChaos = true
+	w = serve(h, http.MethodPut, "/chaos/posts?failPercent=25&failStatus=503&delayPercent=10&delay=100ms")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(fetcher.ChaosRules{FailPercent: 25, FailStatus: 503, DelayPercent: 10, Delay: 100 * time.Millisecond}, cc.Rules())
+
+	w = serve(h, http.MethodPut, "/chaos/posts?truncatePercent=101")
+	assert.Equal(http.StatusBadRequest, w.Code)
+	w = serve(h, http.MethodPut, "/chaos/users?failPercent=1")
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	w = serve(h, http.MethodGet, "/chaos")
+	var statuses []fetcher.ChaosStatus
+	assert.Nil(json.NewDecoder(w.Body).Decode(&statuses))
+	assert.Len(statuses, 1)
+	assert.Equal(25.0, statuses[0].Rules.FailPercent)
+
+	w = serve(h, http.MethodDelete, "/chaos/posts")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.False(cc.Rules().Enabled())
+}
@@ -14,6 +14,11 @@ type Aggregator struct {
 	UserFetcher  fetcher.UserFetcher
 	PostsFetcher fetcher.PostsFetcher
 	Timeout      time.Duration
+
+	// Store, when set, serves summaries precomputed at most StoreMaxAge ago
+	// (any age when zero); the fetchers are only called on a miss.
+	Store       SummaryStore
+	StoreMaxAge time.Duration
 }
 
 // NewAggregator creates a new Aggregator instance.
@@ -31,6 +36,13 @@ func (agg *Aggregator) GetUserSummary(ctx context.Context, userID int) (*UserSum
 		return nil, fmt.Errorf("invalid user ID: %d", userID)
 	}
 
+	if agg.Store != nil {
+		if summary, ok := agg.Store.UserSummary(ctx, userID, agg.StoreMaxAge); ok {
+			logger.Log.Debug("summary served from store", "userId", userID)
+			return summary, nil
+		}
+	}
+
 	start := time.Now()
 
 	if agg.Timeout <= 0 {
@@ -16,7 +16,7 @@ func TestMain(m *testing.M) {
 	test.SetupTests(m)
 }
 
-func Test_GetUserSummary_Success(t *testing.T){
+func Test_GetUserSummary_Success(t *testing.T) {
 	assert := assert.New(t)
 	userMock := &mock.MockUserFetcher{User: mock.UserMock}
 	postsMock := &mock.MockPostsFetcher{Posts: mock.PostsMock}
@@ -31,7 +31,7 @@ func Test_GetUserSummary_Success(t *testing.T){
 	assert.Equal(2, summary.PostCount)
 }
 
-func Test_GetUserSummary_InvalidUserID(t *testing.T){
+func Test_GetUserSummary_InvalidUserID(t *testing.T) {
 	assert := assert.New(t)
 	agg := aggregator.NewAggregator(&mock.MockUserFetcher{}, &mock.MockPostsFetcher{}, 2*time.Second)
 	summary, err := agg.GetUserSummary(context.Background(), 0)
@@ -41,7 +41,7 @@ func Test_GetUserSummary_InvalidUserID(t *testing.T){
 	assert.Contains(err.Error(), "invalid user ID")
 }
 
-func Test_GetUserSummary_FetchUserError(t *testing.T){
+func Test_GetUserSummary_FetchUserError(t *testing.T) {
 	assert := assert.New(t)
 	userMock := &mock.MockUserFetcher{Err: errors.New("user not found")}
 	postsMock := &mock.MockPostsFetcher{}
@@ -54,7 +54,7 @@ func Test_GetUserSummary_FetchUserError(t *testing.T){
 	assert.Equal("fetching user: user not found", err.Error())
 }
 
-func Test_GetUserSummary_FetchPostsError(t *testing.T){
+func Test_GetUserSummary_FetchPostsError(t *testing.T) {
 	assert := assert.New(t)
 	userMock := &mock.MockUserFetcher{User: mock.UserMock}
 	postsMock := &mock.MockPostsFetcher{Err: errors.New("fetch posts error")}
@@ -76,4 +76,48 @@ func TestAggregator_GetUserSummary_Timeout(t *testing.T) {
 	assert.NotNil(err)
 	assert.Nil(summary)
 	assert.Contains(err.Error(), "context deadline exceeded")
-}
\ No newline at end of file
+}
+
+func Test_GetUserSummary_DoesNotLogPII(t *testing.T) {
+	assert := assert.New(t)
+	logs := test.CaptureLogs(t)
+	userMock := &mock.MockUserFetcher{User: mock.UserMock}
+	postsMock := &mock.MockPostsFetcher{Posts: mock.PostsMock}
+
+	agg := aggregator.NewAggregator(userMock, postsMock, 2*time.Second)
+	_, err := agg.GetUserSummary(context.Background(), 1)
+	assert.Nil(err)
+
+	failing := aggregator.NewAggregator(&mock.MockUserFetcher{Err: errors.New("no user with email john@example.com")}, postsMock, 2*time.Second)
+	_, err = failing.GetUserSummary(context.Background(), 1)
+	assert.NotNil(err)
+
+	assert.Contains(logs.String(), "aggregation complete")
+	logs.AssertNoPII(t, mock.UserMock.Name, mock.UserMock.Email)
+}
+
+type summaryStoreStub struct {
+	summary *aggregator.UserSummary
+	maxAge  time.Duration
+}
+
+func (s *summaryStoreStub) UserSummary(ctx context.Context, userID int, maxAge time.Duration) (*aggregator.UserSummary, bool) {
+	s.maxAge = maxAge
+	return s.summary, s.summary != nil
+}
+
+func Test_GetUserSummary_ServedFromStore(t *testing.T) {
+	assert := assert.New(t)
+	store := &summaryStoreStub{summary: &aggregator.UserSummary{Name: "Stored", PostCount: 7}}
+	agg := aggregator.NewAggregator(&mock.MockUserFetcher{Err: errors.New("not called")}, &mock.MockPostsFetcher{Err: errors.New("not called")}, 2*time.Second)
+	agg.Store, agg.StoreMaxAge = store, time.Minute
+
+	summary, err := agg.GetUserSummary(context.Background(), 1)
+	assert.Nil(err)
+	assert.Equal("Stored", summary.Name)
+	assert.Equal(time.Minute, store.maxAge)
+
+	store.summary = nil
+	_, err = agg.GetUserSummary(context.Background(), 1)
+	assert.ErrorContains(err, "not called", "a store miss falls back to the fetchers")
+}
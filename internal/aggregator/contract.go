@@ -1,5 +1,10 @@
 package aggregator
 
+import (
+	"context"
+	"time"
+)
+
 
 
 type UserSummary struct {
@@ -8,5 +13,8 @@ type UserSummary struct {
 	PostCount int    `json:"postCount"`
 }
 
-
-
+// SummaryStore serves summaries computed ahead of time, like store.Store.
+type SummaryStore interface {
+	// UserSummary reports false when the summary is missing or older than maxAge.
+	UserSummary(ctx context.Context, userID int, maxAge time.Duration) (*UserSummary, bool)
+}
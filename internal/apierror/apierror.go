@@ -0,0 +1,85 @@
+// Package apierror holds the error codes and the error body shared by the
+// GraphQL, REST, gRPC, export and job endpoints, so a failure reads the same
+// whichever endpoint reports it.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-graphql-aggregator/internal/fetcher"
+	"net/http"
+)
+
+// Error codes, reported in extensions.code of the error bodies and in the
+// failures of jobs and exports.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeNotAcceptable    = "NOT_ACCEPTABLE"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeTimeout          = "TIMEOUT"
+	CodeUnavailable      = "UPSTREAM_UNAVAILABLE"
+	CodeUpstream         = "UPSTREAM_ERROR"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+)
+
+// Code classifies an error of the aggregator: a missing resource, a timeout,
+// an open circuit, or any other upstream failure.
+func Code(err error) string {
+	switch {
+	case errors.Is(err, fetcher.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, fetcher.ErrCircuitOpen):
+		return CodeUnavailable
+	}
+	return CodeUpstream
+}
+
+// Status returns the HTTP status of an error classified by Code.
+func Status(code string) int {
+	switch code {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// ErrorResponse is the body of every error, in the shape of a GraphQL error
+// response, so REST and GraphQL clients (and the auth and rate limit
+// middlewares) share it. The type names are those of the OpenAPI schemas.
+type ErrorResponse struct {
+	Errors []ErrorItem `json:"errors"`
+}
+
+// ErrorItem is an error of an ErrorResponse.
+type ErrorItem struct {
+	Message    string          `json:"message"`
+	Extensions ErrorExtensions `json:"extensions"`
+}
+
+// ErrorExtensions holds the machine-readable code of an ErrorItem.
+type ErrorExtensions struct {
+	Code string `json:"code"`
+}
+
+// New returns the body of a single error.
+func New(code, message string) ErrorResponse {
+	return ErrorResponse{Errors: []ErrorItem{{Message: message, Extensions: ErrorExtensions{Code: code}}}}
+}
+
+// Write writes the JSON body of a single error with status.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(New(code, message))
+}
@@ -0,0 +1,37 @@
+package apierror_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func Test_Code_ClassifiesErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := map[error]struct {
+		code   string
+		status int
+	}{
+		fmt.Errorf("user 42: %w", fetcher.ErrNotFound):       {apierror.CodeNotFound, http.StatusNotFound},
+		fmt.Errorf("fetching: %w", context.DeadlineExceeded): {apierror.CodeTimeout, http.StatusGatewayTimeout},
+		fmt.Errorf("users: %w", fetcher.ErrCircuitOpen):      {apierror.CodeUnavailable, http.StatusServiceUnavailable},
+		errors.New("connection refused"):                     {apierror.CodeUpstream, http.StatusBadGateway},
+	}
+	for err, want := range cases {
+		code := apierror.Code(err)
+		assert.Equal(want.code, code, err.Error())
+		assert.Equal(want.status, apierror.Status(code), err.Error())
+	}
+}
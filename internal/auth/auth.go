@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RoleAdmin satisfies every role requirement.
+const RoleAdmin = "ADMIN"
+
+// Principal identifies the caller of a request and what it is allowed to see.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+// HasRole reports whether the principal holds the given role. Admins hold every role.
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Roles, RoleAdmin) || slices.Contains(p.Roles, role)
+}
+
+// HasScope reports whether the principal was granted the given scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, or nil for anonymous callers.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Tokens maps API tokens to the principal they authenticate.
+type Tokens map[string]*Principal
+
+// ParseTokens parses a token spec in the form
+// "token|subject|ROLE1,ROLE2|scope1,scope2;token2|...".
+// Roles and scopes are optional.
+func ParseTokens(spec string) (Tokens, error) {
+	tokens := Tokens{}
+	for entry := range strings.SplitSeq(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) < 2 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid token entry %q: expected token|subject|roles|scopes", entry)
+		}
+		p := &Principal{Subject: parts[1]}
+		if len(parts) > 2 {
+			p.Roles = splitList(parts[2])
+		}
+		if len(parts) > 3 {
+			p.Scopes = splitList(parts[3])
+		}
+		if _, dup := tokens[parts[0]]; dup {
+			return nil, fmt.Errorf("duplicate token for subject %q", p.Subject)
+		}
+		tokens[parts[0]] = p
+	}
+	return tokens, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package auth_test
+
+import (
+	"go-graphql-aggregator/internal/auth"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseTokens_Success(t *testing.T) {
+	assert := assert.New(t)
+
+	tokens, err := auth.ParseTokens("s3cret|alice|ADMIN|read:pii,write:jobs; t0k|bob")
+
+	assert.Nil(err)
+	assert.Len(tokens, 2)
+	assert.Equal("alice", tokens["s3cret"].Subject)
+	assert.True(tokens["s3cret"].HasRole("USER"))
+	assert.True(tokens["s3cret"].HasScope("read:pii"))
+	assert.Equal("bob", tokens["t0k"].Subject)
+	assert.False(tokens["t0k"].HasRole("USER"))
+	assert.False(tokens["t0k"].HasScope("read:pii"))
+}
+
+func Test_ParseTokens_InvalidEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	tokens, err := auth.ParseTokens("only-token")
+
+	assert.NotNil(err)
+	assert.Nil(tokens)
+	assert.Contains(err.Error(), "invalid token entry")
+}
+
+func Test_Principal_Nil(t *testing.T) {
+	assert := assert.New(t)
+	var p *auth.Principal
+
+	assert.False(p.HasRole("USER"))
+	assert.False(p.HasScope("read:pii"))
+}
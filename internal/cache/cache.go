@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/99designs/gqlgen/graphql"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Stats is a snapshot of a cache's usage.
+type Stats struct {
+	Name      string `json:"name"`
+	Len       int    `json:"len"`
+	Capacity  int    `json:"capacity"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+}
+
+// Purgeable is a cache that reports stats and can be emptied.
+type Purgeable interface {
+	Stats() Stats
+	Purge()
+}
+
+// LRU is a size-bounded cache that counts hits, misses and evictions.
+// It implements gqlgen's graphql.Cache.
+type LRU[T any] struct {
+	name      string
+	capacity  int
+	lru       *lru.Cache[string, T]
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+var _ graphql.Cache[any] = &LRU[any]{}
+
+// NewLRU creates an LRU holding up to size entries.
+func NewLRU[T any](name string, size int) *LRU[T] {
+	c := &LRU[T]{name: name, capacity: size}
+	l, err := lru.New[string, T](size)
+	if err != nil {
+		// an error is only returned for non-positive sizes
+		panic(fmt.Sprintf("creating cache %s: %v", name, err))
+	}
+	c.lru = l
+	return c
+}
+
+// Get looks up a key's value from the cache.
+func (c *LRU[T]) Get(ctx context.Context, key string) (T, bool) {
+	v, ok := c.lru.Get(key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return v, ok
+}
+
+// Peek looks up a key's value without updating its recency or the stats.
+func (c *LRU[T]) Peek(key string) (T, bool) {
+	return c.lru.Peek(key)
+}
+
+// Keys returns the keys of the cache, oldest first.
+func (c *LRU[T]) Keys() []string {
+	return c.lru.Keys()
+}
+
+// Add adds a value to the cache, counting the eviction it may cause.
+func (c *LRU[T]) Add(ctx context.Context, key string, value T) {
+	if c.lru.Add(key, value) {
+		c.evictions.Add(1)
+	}
+}
+
+// Remove removes a key from the cache. Removals are not counted as evictions.
+func (c *LRU[T]) Remove(key string) {
+	c.lru.Remove(key)
+}
+
+// Stats returns the cache usage counters.
+func (c *LRU[T]) Stats() Stats {
+	return Stats{
+		Name:      c.name,
+		Len:       c.lru.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// Purge removes every entry. Purged entries are not counted as evictions.
+func (c *LRU[T]) Purge() {
+	c.lru.Purge()
+}
+
+// Registry tracks named caches so they can be inspected and purged at runtime.
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]Purgeable
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{caches: map[string]Purgeable{}}
+}
+
+// Register adds c under name, replacing any cache previously registered with it.
+func (r *Registry) Register(name string, c Purgeable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches[name] = c
+}
+
+// Replace makes the caches of r those of other, dropping the rest.
+func (r *Registry) Replace(other *Registry) {
+	other.mu.RLock()
+	caches := maps.Clone(other.caches)
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches = caches
+}
+
+// Stats returns the stats of every cache, sorted by name.
+func (r *Registry) Stats() []Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]Stats, 0, len(r.caches))
+	for name, c := range r.caches {
+		s := c.Stats()
+		s.Name = name
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b Stats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Purge empties the named cache, or every cache when name is empty.
+func (r *Registry) Purge(name string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		for _, c := range r.caches {
+			c.Purge()
+		}
+		return nil
+	}
+	c, ok := r.caches[name]
+	if !ok {
+		return fmt.Errorf("unknown cache %q", name)
+	}
+	c.Purge()
+	return nil
+}
@@ -0,0 +1,34 @@
+package cache_test
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/test"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func Test_LRU_CountsOnlyEvictions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	c := cache.NewLRU[int]("test", 2)
+
+	c.Add(ctx, "a", 1)
+	c.Add(ctx, "b", 2)
+	c.Add(ctx, "c", 3)
+	assert.Equal(int64(1), c.Stats().Evictions)
+
+	c.Remove("b")
+	c.Add(ctx, "d", 4)
+	assert.Equal(int64(1), c.Stats().Evictions, "removals free a slot without counting")
+
+	c.Purge()
+	stats := c.Stats()
+	assert.Equal(int64(1), stats.Evictions)
+	assert.Equal(0, stats.Len)
+}
@@ -0,0 +1,211 @@
+package cachecontrol
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Scope tells whether a response may be stored by shared caches.
+type Scope string
+
+const (
+	Public  Scope = "PUBLIC"
+	Private Scope = "PRIVATE"
+)
+
+// Policy is the caching policy of a response: the smallest maxAge of the
+// fields it resolved, private if any of them is.
+type Policy struct {
+	MaxAge int
+	Scope  Scope
+}
+
+// Cacheable reports whether the response may be served without revalidation.
+func (p Policy) Cacheable() bool {
+	return p.MaxAge > 0
+}
+
+// Header returns the Cache-Control header value of p.
+func (p Policy) Header() string {
+	switch {
+	case p.Cacheable() && p.Scope == Private:
+		return fmt.Sprintf("private, max-age=%d", p.MaxAge)
+	case p.Cacheable():
+		return fmt.Sprintf("public, max-age=%d", p.MaxAge)
+	case p.Scope == Private:
+		return "private, no-cache"
+	default:
+		return "no-cache"
+	}
+}
+
+// Extension computes the policy of each query from the @cacheControl hints
+// of the fields it resolves:
+//
+//   - a field's maxAge is that of its hint, or of the hint of the object,
+//     interface or union it returns;
+//   - without one, root fields and fields returning those types get
+//     DefaultMaxAge, while other fields, or those with inheritMaxAge, don't
+//     restrict the maxAge of the response;
+//   - a PRIVATE scope on the field or its type makes the response private.
+//
+// Policies are only computed for operations run through Middleware or Record.
+type Extension struct {
+	DefaultMaxAge int
+
+	schema *ast.Schema
+	hints  sync.Map // *ast.FieldDefinition → fieldHint
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = &Extension{}
+
+func (e *Extension) ExtensionName() string {
+	return "CacheControl"
+}
+
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema.Schema()
+	return nil
+}
+
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	res, ok := ctx.Value(resultKey{}).(*result)
+	if !ok || !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	if op := graphql.GetOperationContext(ctx).Operation; op == nil || op.Operation != ast.Query {
+		return next(ctx)
+	}
+
+	t := &tracker{}
+	resp := next(context.WithValue(ctx, trackerKey{}, t))
+	// responses with errors are never cached, nor are those of queries
+	// resolving no field with a maxAge, such as introspection
+	if resp != nil && len(resp.Errors) == 0 {
+		t.mu.Lock()
+		if t.bounded {
+			res.set(Policy{MaxAge: t.maxAge, Scope: t.scope()})
+		}
+		t.mu.Unlock()
+	}
+	return resp
+}
+
+func (e *Extension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		if fc := graphql.GetFieldContext(ctx); fc != nil && fc.Field.Definition != nil {
+			t.add(e.hint(fc.Object, fc.Field.Definition))
+		}
+	}
+	return next(ctx)
+}
+
+// Uncacheable makes the response of the operation of ctx uncacheable, for
+// data that may be stale, such as that served from a snapshot.
+func Uncacheable(ctx context.Context) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.add(fieldHint{maxAge: 0, bounded: true})
+	}
+}
+
+type fieldHint struct {
+	maxAge  int
+	bounded bool
+	private bool
+}
+
+// hint returns the hint of field def of the object named parent.
+func (e *Extension) hint(parent string, def *ast.FieldDefinition) fieldHint {
+	if h, ok := e.hints.Load(def); ok {
+		return h.(fieldHint)
+	}
+
+	var h fieldHint
+	field := parseHint(def.Directives)
+	var typ directiveHint
+	composite := false
+	if td := e.schema.Types[def.Type.Name()]; td != nil {
+		composite = td.Kind == ast.Object || td.Kind == ast.Interface || td.Kind == ast.Union
+		if composite {
+			typ = parseHint(td.Directives)
+		}
+	}
+	root := e.schema.Query != nil && parent == e.schema.Query.Name
+	switch {
+	case strings.HasPrefix(def.Name, "__"), strings.HasPrefix(parent, "__"):
+		// introspection doesn't restrict the policy
+	case field.maxAge != nil:
+		h.maxAge, h.bounded = *field.maxAge, true
+	case field.inherit:
+	case typ.maxAge != nil:
+		h.maxAge, h.bounded = *typ.maxAge, true
+	case composite || root:
+		h.maxAge, h.bounded = e.DefaultMaxAge, true
+	}
+	h.private = field.private || typ.private
+
+	e.hints.Store(def, h)
+	return h
+}
+
+type directiveHint struct {
+	maxAge  *int
+	private bool
+	inherit bool
+}
+
+func parseHint(directives ast.DirectiveList) directiveHint {
+	var h directiveHint
+	d := directives.ForName("cacheControl")
+	if d == nil {
+		return h
+	}
+	if a := d.Arguments.ForName("maxAge"); a != nil && a.Value != nil {
+		if n, err := strconv.Atoi(a.Value.Raw); err == nil {
+			h.maxAge = &n
+		}
+	}
+	if a := d.Arguments.ForName("scope"); a != nil && a.Value != nil {
+		h.private = Scope(a.Value.Raw) == Private
+	}
+	if a := d.Arguments.ForName("inheritMaxAge"); a != nil && a.Value != nil {
+		h.inherit = a.Value.Raw == "true"
+	}
+	return h
+}
+
+type trackerKey struct{}
+
+// tracker restricts the policy of an operation as its fields resolve.
+type tracker struct {
+	mu      sync.Mutex
+	maxAge  int
+	bounded bool
+	private bool
+}
+
+func (t *tracker) add(h fieldHint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h.bounded && (!t.bounded || h.maxAge < t.maxAge) {
+		t.maxAge, t.bounded = h.maxAge, true
+	}
+	t.private = t.private || h.private
+}
+
+func (t *tracker) scope() Scope {
+	if t.private {
+		return Private
+	}
+	return Public
+}
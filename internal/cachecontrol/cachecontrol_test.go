@@ -0,0 +1,152 @@
+package cachecontrol_test
+
+import (
+	"errors"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/cachecontrol"
+	"go-graphql-aggregator/internal/graph"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"go-graphql-aggregator/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func newHandler(userErr error, defaultMaxAge int) http.Handler {
+	agg := &aggregator.Aggregator{
+		UserFetcher:  &mock.MockUserFetcher{User: mock.UserMock, Err: userErr},
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+		Timeout:      time.Second,
+	}
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Aggregator: agg})))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(&cachecontrol.Extension{DefaultMaxAge: defaultMaxAge})
+	return cachecontrol.Middleware(srv)
+}
+
+func get(h http.Handler, query string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/query?query="+url.QueryEscape(query), nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "tester", Scopes: []string{"read:pii"}}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func Test_Middleware_PublicQuery(t *testing.T) {
+	assert := assert.New(t)
+	h := newHandler(nil, 0)
+
+	w := get(h, "{ userSummary(userId: 1) { name postCount } }")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal([]string{"Authorization", "X-Api-Key"}, w.Header().Values("Vary"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(etag)
+
+	again := get(h, "{ userSummary(userId: 1) { name postCount } }", "If-None-Match", `"other", `+etag)
+	assert.Equal(http.StatusNotModified, again.Code)
+	assert.Empty(again.Body.String())
+	assert.Equal(etag, again.Header().Get("ETag"))
+
+	changed := get(h, "{ userSummary(userId: 1) { name } }", "If-None-Match", etag)
+	assert.Equal(http.StatusOK, changed.Code)
+	assert.NotEqual(etag, changed.Header().Get("ETag"))
+}
+
+func Test_Middleware_PrivateField(t *testing.T) {
+	assert := assert.New(t)
+
+	w := get(newHandler(nil, 0), "{ userSummary(userId: 1) { name email } }")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("private, max-age=60", w.Header().Get("Cache-Control"))
+}
+
+func Test_Middleware_SmallestMaxAgeWins(t *testing.T) {
+	assert := assert.New(t)
+	query := "{ userSummary(userId: 1) { name } syncStatus { users } }"
+
+	w := get(newHandler(nil, 0), query)
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"), "syncStatus has maxAge 0")
+	assert.NotEmpty(w.Header().Get("ETag"), "uncacheable responses can still be revalidated")
+
+	w = get(newHandler(nil, 30), `{ userSummary(userId: 1) { name } _entities(representations: [{__typename: "User", id: 1}]) { __typename } }`)
+	assert.Equal("public, max-age=30", w.Header().Get("Cache-Control"), "_entities has no hint")
+}
+
+func Test_Middleware_DefaultMaxAgeLeavesStateUncacheable(t *testing.T) {
+	assert := assert.New(t)
+	agg := &aggregator.Aggregator{
+		UserFetcher:  &mock.MockUserFetcher{User: mock.UserMock},
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+		Timeout:      time.Second,
+	}
+	jm, err := jobs.NewManager(jobs.Options{Aggregator: func() *aggregator.Aggregator { return agg }, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm := webhook.NewManager(webhook.Options{}, nil)
+	defer wm.Close()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Aggregator: agg, Jobs: jm, WebhookManager: wm})))
+	srv.AddTransport(transport.GET{})
+	srv.Use(&cachecontrol.Extension{DefaultMaxAge: 30})
+	h := cachecontrol.Middleware(srv)
+
+	cases := map[string]string{
+		"{ syncStatus { users } }":                                 "no-cache",
+		`{ job(id: "unknown") { status } }`:                        "private, no-cache",
+		"{ webhooks { id } }":                                      "no-cache",
+		"{ webhookDeliveries { id } }":                             "no-cache",
+		"{ userSummary(userId: 1) { name } syncStatus { users } }": "no-cache",
+	}
+	for query, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/query?query="+url.QueryEscape(query), nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "ops", Roles: []string{"ADMIN"}}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.NotContains(w.Body.String(), "errors", query)
+		assert.Equal(want, w.Header().Get("Cache-Control"), query)
+	}
+}
+
+func Test_Middleware_ErrorsAreNotCached(t *testing.T) {
+	assert := assert.New(t)
+
+	w := get(newHandler(errors.New("user fetch failed"), 0), "{ userSummary(userId: 1) { name } }")
+
+	assert.Contains(w.Body.String(), "errors")
+	assert.Equal("no-store", w.Header().Get("Cache-Control"))
+	assert.Empty(w.Header().Get("ETag"))
+}
+
+func Test_Middleware_IgnoresPOST(t *testing.T) {
+	assert := assert.New(t)
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"query":"{ userSummary(userId: 1) { name } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	newHandler(nil, 0).ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Empty(w.Header().Get("Cache-Control"))
+	assert.Empty(w.Header().Get("ETag"))
+}
@@ -0,0 +1,130 @@
+package cachecontrol
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type resultKey struct{}
+
+// result receives the policy of the operation of a request, and passes it
+// on to the result of an outer Record.
+type result struct {
+	mu     sync.Mutex
+	policy Policy
+	ok     bool
+	parent *result
+}
+
+func (r *result) set(p Policy) {
+	r.mu.Lock()
+	r.policy, r.ok = p, true
+	r.mu.Unlock()
+	if r.parent != nil {
+		r.parent.set(p)
+	}
+}
+
+func (r *result) get() (Policy, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.policy, r.ok
+}
+
+// Record makes Extension compute the policy of the operation run with the
+// returned context, which the returned function reports once it's known.
+func Record(ctx context.Context) (context.Context, func() (Policy, bool)) {
+	parent, _ := ctx.Value(resultKey{}).(*result)
+	res := &result{parent: parent}
+	return context.WithValue(ctx, resultKey{}, res), res.get
+}
+
+// Report sets the policy of the operation of ctx, for responses not computed
+// by Extension, such as those served from a cache.
+func Report(ctx context.Context, p Policy) {
+	if res, ok := ctx.Value(resultKey{}).(*result); ok {
+		res.set(p)
+	}
+}
+
+// Middleware sets Cache-Control and ETag on the responses to GET queries,
+// per the policy computed by Extension, and answers 304 Not Modified when
+// If-None-Match holds the ETag. Responses without a policy, such as errors,
+// are marked no-store. Other methods pass through untouched.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, policyOf := Record(r.Context())
+		buf := &bufferedWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r.WithContext(ctx))
+
+		h := w.Header()
+		// responses depend on the credentials, through @hasScope and @auth
+		h.Add("Vary", "Authorization")
+		h.Add("Vary", "X-Api-Key")
+		policy, ok := policyOf()
+		if buf.status != http.StatusOK || !ok {
+			h.Set("Cache-Control", "no-store")
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", policy.Header())
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(buf.status)
+		_, _ = w.Write(buf.body.Bytes())
+	})
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag,
+// comparing weakly as RFC 9110 requires.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds a response until its headers can be computed.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
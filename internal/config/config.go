@@ -1,51 +1,671 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"go-graphql-aggregator/internal/auth"
 	"go-graphql-aggregator/internal/logger"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config holds every setting of the service. Values are resolved with the
+// following precedence (highest first):
+//
+//  1. command-line flags
+//  2. environment variables
+//  3. the YAML config file (-config flag or CONFIG_FILE)
+//  4. built-in defaults
 type Config struct {
-	ServerPort   string
-	UsersBaseURL string
-	PostsBaseURL string
-	HTTPTimeout  time.Duration
-	AggTimeout   time.Duration
-}
-
-func LoadConfig() *Config {
-	cfg := &Config{
-		ServerPort:   getEnv("SERVER_PORT", "8080"),
-		UsersBaseURL: getEnv("USERS_BASE_URL", "https://jsonplaceholder.typicode.com/users"),
-		PostsBaseURL: getEnv("POSTS_BASE_URL", "https://jsonplaceholder.typicode.com/posts"),
-		HTTPTimeout:  getEnvAsDuration("HTTP_TIMEOUT", 5*time.Second),
-		AggTimeout:   getEnvAsDuration("AGG_TIMEOUT", 5*time.Second),
-	}
-	logger.Log.Info("config loaded",
-		"port", cfg.ServerPort,
-		"usersURL", cfg.UsersBaseURL,
-		"postsURL", cfg.PostsBaseURL,
-		"httpTimeout", cfg.HTTPTimeout,
-		"aggTimeout", cfg.AggTimeout,
+	ServerPort   string        `yaml:"serverPort"`
+	UsersBaseURL string        `yaml:"usersBaseURL"`
+	PostsBaseURL string        `yaml:"postsBaseURL"`
+	HTTPTimeout  time.Duration `yaml:"httpTimeout"`
+	AggTimeout   time.Duration `yaml:"aggTimeout"`
+
+	// UsersSource and PostsSource are "rest" or "graphql". A GraphQL source
+	// posts its Query to the base URL with Variables, a JSON object where
+	// "$userId" stands for the user ID, and reads the records at the
+	// dot-separated Path of the response data.
+	UsersSource           string `yaml:"usersSource"`
+	UsersGraphQLQuery     string `yaml:"usersGraphQLQuery"`
+	UsersGraphQLVariables string `yaml:"usersGraphQLVariables"`
+	UsersGraphQLPath      string `yaml:"usersGraphQLPath"`
+	PostsSource           string `yaml:"postsSource"`
+	PostsGraphQLQuery     string `yaml:"postsGraphQLQuery"`
+	PostsGraphQLVariables string `yaml:"postsGraphQLVariables"`
+	PostsGraphQLPath      string `yaml:"postsGraphQLPath"`
+
+	EnableIntrospection bool   `yaml:"enableIntrospection"`
+	EnableAPQ           bool   `yaml:"enableAPQ"`
+	LogMode             string `yaml:"logMode"`
+	LogLevel            string `yaml:"logLevel"`
+
+	// LogFile enables file output with rotation; empty logs to stdout only.
+	LogFile             string        `yaml:"logFile"`
+	LogMaxSizeMB        int           `yaml:"logMaxSizeMB"`
+	LogMaxAge           time.Duration `yaml:"logMaxAge"`
+	LogMaxBackups       int           `yaml:"logMaxBackups"`
+	LogSampleFirst      int           `yaml:"logSampleFirst"`
+	LogSampleThereafter int           `yaml:"logSampleThereafter"`
+
+	// LogRedact hides PII (LogRedactFields plus e-mails, phones and
+	// LogRedactPatterns) from every log record, masking or hashing it.
+	LogRedact         bool   `yaml:"logRedact"`
+	LogRedactFields   string `yaml:"logRedactFields"`
+	LogRedactPatterns string `yaml:"logRedactPatterns"`
+	LogRedactMode     string `yaml:"logRedactMode"`
+
+	AuthTokens string `yaml:"authTokens"`
+
+	// Outbound limits per upstream; zero disables the limit.
+	UsersRPS         float64 `yaml:"usersRPS"`
+	UsersMaxInFlight int     `yaml:"usersMaxInFlight"`
+	PostsRPS         float64 `yaml:"postsRPS"`
+	PostsMaxInFlight int     `yaml:"postsMaxInFlight"`
+
+	// Circuit breaker per upstream; a zero threshold disables it.
+	BreakerThreshold int           `yaml:"breakerThreshold"`
+	BreakerCooldown  time.Duration `yaml:"breakerCooldown"`
+
+	// UpstreamCacheSize responses per upstream are kept to be served while
+	// fresh and revalidated with conditional requests; zero disables it.
+	UpstreamCacheSize int `yaml:"upstreamCacheSize"`
+
+	// AdminAddr is a TCP address or "unix:/path" for the admin listener; empty disables it.
+	AdminAddr string `yaml:"adminAddr"`
+
+	// GRPCAddr is the TCP address of the gRPC API; empty disables it.
+	GRPCAddr string `yaml:"grpcAddr"`
+
+	// EnableChaos wires fault injection into the upstream clients, driven
+	// through the admin /chaos endpoints. Never enable it in production.
+	EnableChaos bool `yaml:"enableChaos"`
+
+	// UpstreamCassetteMode "record" saves upstream traffic to cassettes in
+	// UpstreamCassetteDir and "replay" answers from them without network; "off"
+	// talks to the upstreams normally.
+	UpstreamCassetteMode  string `yaml:"upstreamCassetteMode"`
+	UpstreamCassetteDir   string `yaml:"upstreamCassetteDir"`
+	UpstreamCassetteScrub string `yaml:"upstreamCassetteScrub"`
+
+	// SnapshotMode "fallback" serves SnapshotFile when an upstream fails and
+	// "exclusive" serves it without calling the upstreams; "off" ignores it.
+	SnapshotMode string `yaml:"snapshotMode"`
+	SnapshotFile string `yaml:"snapshotFile"`
+
+	// StoreFile enables the local store, synced every StoreSyncInterval;
+	// summaries are served from it while its last sync is at most StoreMaxAge
+	// old (any age when zero).
+	StoreFile         string        `yaml:"storeFile"`
+	StoreSyncInterval time.Duration `yaml:"storeSyncInterval"`
+	StoreMaxAge       time.Duration `yaml:"storeMaxAge"`
+
+	// JobWorkers summary jobs run at once, each aggregating up to
+	// JobConcurrency summaries at a time. Finished jobs are kept for JobTTL,
+	// and across restarts in the local store when JobPersist is set.
+	JobWorkers     int           `yaml:"jobWorkers"`
+	JobConcurrency int           `yaml:"jobConcurrency"`
+	JobMaxUsers    int           `yaml:"jobMaxUsers"`
+	JobTTL         time.Duration `yaml:"jobTTL"`
+	JobPersist     bool          `yaml:"jobPersist"`
+
+	// Webhooks are the subscriptions of the config, besides those created
+	// through the API. Changes are detected every WebhookCheckInterval (0
+	// disables detection); deliveries are tried WebhookMaxAttempts times,
+	// waiting WebhookBackoff, doubled each time, between attempts.
+	Webhooks             string        `yaml:"webhooks"`
+	WebhookCheckInterval time.Duration `yaml:"webhookCheckInterval"`
+	WebhookMaxAttempts   int           `yaml:"webhookMaxAttempts"`
+	WebhookBackoff       time.Duration `yaml:"webhookBackoff"`
+
+	// RateLimitTiers disables client rate limiting when empty.
+	RateLimitKey     string        `yaml:"rateLimitKey"`
+	RateLimitTiers   string        `yaml:"rateLimitTiers"`
+	RateLimitIdleTTL time.Duration `yaml:"rateLimitIdleTTL"`
+
+	// CacheControlDefaultMaxAge is the maxAge of root fields and fields
+	// returning objects without a @cacheControl hint; zero makes them uncacheable.
+	CacheControlDefaultMaxAge time.Duration `yaml:"cacheControlDefaultMaxAge"`
+
+	// ResponseCacheSize whole query responses are cached for the maxAge of
+	// their @cacheControl policy; zero disables the cache.
+	ResponseCacheSize int `yaml:"responseCacheSize"`
+
+	// File is the config file the values were read from, if any.
+	File string `yaml:"-"`
+}
+
+// Default returns the built-in defaults.
+func Default() *Config {
+	return &Config{
+		ServerPort:       "8080",
+		UsersBaseURL:     "https://jsonplaceholder.typicode.com/users",
+		PostsBaseURL:     "https://jsonplaceholder.typicode.com/posts",
+		HTTPTimeout:      5 * time.Second,
+		AggTimeout:       5 * time.Second,
+		LogMode:          "text",
+		LogLevel:         "info",
+		LogMaxSizeMB:     100,
+		LogMaxAge:        24 * time.Hour,
+		LogMaxBackups:    7,
+		LogRedact:        true,
+		LogRedactFields:  "name,email,phone",
+		LogRedactMode:    "mask",
+		BreakerThreshold: 0,
+		BreakerCooldown:  30 * time.Second,
+		RateLimitKey:     "api_key",
+		RateLimitIdleTTL: 10 * time.Minute,
+
+		UpstreamCacheSize: 1000,
+
+		UpstreamCassetteMode: "off",
+		UpstreamCassetteDir:  "testdata/cassettes",
+
+		SnapshotMode: "off",
+		SnapshotFile: "snapshot.json",
+
+		StoreSyncInterval: 5 * time.Minute,
+		StoreMaxAge:       15 * time.Minute,
+
+		JobWorkers:     2,
+		JobConcurrency: 4,
+		JobMaxUsers:    10000,
+		JobTTL:         time.Hour,
+
+		WebhookCheckInterval: time.Minute,
+		WebhookMaxAttempts:   5,
+		WebhookBackoff:       time.Second,
+
+		UsersSource:           "rest",
+		UsersGraphQLQuery:     "query($id: ID!) { user(id: $id) { id name email } }",
+		UsersGraphQLVariables: `{"id": "$userId"}`,
+		UsersGraphQLPath:      "user",
+		PostsSource:           "rest",
+		PostsGraphQLQuery:     "query($id: ID!) { user(id: $id) { posts { data { id title body } } } }",
+		PostsGraphQLVariables: `{"id": "$userId"}`,
+		PostsGraphQLPath:      "user.posts.data",
+	}
+}
+
+// setting binds a Config field to its env var and flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	ptr   any
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"SERVER_PORT", "port", "HTTP listen port", &c.ServerPort},
+		{"USERS_BASE_URL", "users-url", "users API base URL", &c.UsersBaseURL},
+		{"POSTS_BASE_URL", "posts-url", "posts API base URL", &c.PostsBaseURL},
+		{"USERS_SOURCE", "users-source", "users API kind: rest or graphql", &c.UsersSource},
+		{"USERS_GRAPHQL_QUERY", "users-graphql-query", "GraphQL document fetching a user", &c.UsersGraphQLQuery},
+		{"USERS_GRAPHQL_VARIABLES", "users-graphql-variables", `variables of the users query as JSON; "$userId" is replaced by the user ID`, &c.UsersGraphQLVariables},
+		{"USERS_GRAPHQL_PATH", "users-graphql-path", "dot-separated path of the user in the response data", &c.UsersGraphQLPath},
+		{"POSTS_SOURCE", "posts-source", "posts API kind: rest or graphql", &c.PostsSource},
+		{"POSTS_GRAPHQL_QUERY", "posts-graphql-query", "GraphQL document fetching the posts of a user", &c.PostsGraphQLQuery},
+		{"POSTS_GRAPHQL_VARIABLES", "posts-graphql-variables", `variables of the posts query as JSON; "$userId" is replaced by the user ID`, &c.PostsGraphQLVariables},
+		{"POSTS_GRAPHQL_PATH", "posts-graphql-path", "dot-separated path of the posts list in the response data", &c.PostsGraphQLPath},
+		{"HTTP_TIMEOUT", "http-timeout", "timeout of each upstream HTTP request", &c.HTTPTimeout},
+		{"AGG_TIMEOUT", "agg-timeout", "timeout of a whole aggregation, retries included", &c.AggTimeout},
+		{"ENABLE_INTROSPECTION", "introspection", "enable GraphQL introspection", &c.EnableIntrospection},
+		{"ENABLE_APQ", "apq", "enable automatic persisted queries", &c.EnableAPQ},
+		{"LOG_MODE", "log-mode", "log format: text, json or silent", &c.LogMode},
+		{"LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", &c.LogLevel},
+		{"LOG_FILE", "log-file", "also write logs to this file, with rotation", &c.LogFile},
+		{"LOG_MAX_SIZE_MB", "log-max-size-mb", "rotate the log file after this size (0 = no limit)", &c.LogMaxSizeMB},
+		{"LOG_MAX_AGE", "log-max-age", "rotate the log file after this age, and remove older rotated files (0 = no limit)", &c.LogMaxAge},
+		{"LOG_MAX_BACKUPS", "log-max-backups", "rotated log files to keep (0 = keep all)", &c.LogMaxBackups},
+		{"LOG_SAMPLE_FIRST", "log-sample-first", "per message and second, info logs emitted before sampling (0 = no sampling)", &c.LogSampleFirst},
+		{"LOG_SAMPLE_THEREAFTER", "log-sample-thereafter", "after LOG_SAMPLE_FIRST, emit one of every N info logs (0 = drop)", &c.LogSampleThereafter},
+		{"LOG_REDACT", "log-redact", "redact PII from logs", &c.LogRedact},
+		{"LOG_REDACT_FIELDS", "log-redact-fields", "comma-separated attribute keys always redacted", &c.LogRedactFields},
+		{"LOG_REDACT_PATTERNS", "log-redact-patterns", "extra whitespace-separated regexes redacted in log strings", &c.LogRedactPatterns},
+		{"LOG_REDACT_MODE", "log-redact-mode", "redaction mode: mask or hash", &c.LogRedactMode},
+		{"AUTH_TOKENS", "auth-tokens", "API tokens as token|subject|ROLES|scopes;...", &c.AuthTokens},
+		{"USERS_RPS", "users-rps", "max requests per second to the users API (0 = unlimited)", &c.UsersRPS},
+		{"USERS_MAX_IN_FLIGHT", "users-max-in-flight", "max concurrent requests to the users API (0 = unlimited)", &c.UsersMaxInFlight},
+		{"POSTS_RPS", "posts-rps", "max requests per second to the posts API (0 = unlimited)", &c.PostsRPS},
+		{"POSTS_MAX_IN_FLIGHT", "posts-max-in-flight", "max concurrent requests to the posts API (0 = unlimited)", &c.PostsMaxInFlight},
+		{"BREAKER_THRESHOLD", "breaker-threshold", "consecutive upstream failures that open the circuit (0 = disabled)", &c.BreakerThreshold},
+		{"BREAKER_COOLDOWN", "breaker-cooldown", "how long an open circuit rejects requests before a trial", &c.BreakerCooldown},
+		{"UPSTREAM_CACHE_SIZE", "upstream-cache-size", "upstream responses cached per upstream for conditional requests (0 = disabled)", &c.UpstreamCacheSize},
+		{"ADMIN_ADDR", "admin-addr", "admin listener: host:port or unix:/path (empty = disabled)", &c.AdminAddr},
+		{"GRPC_ADDR", "grpc-addr", "gRPC API listener host:port (empty = disabled)", &c.GRPCAddr},
+		{"ENABLE_CHAOS", "chaos", "allow injecting upstream faults through the admin /chaos endpoints", &c.EnableChaos},
+		{"UPSTREAM_CASSETTE_MODE", "cassette-mode", "upstream traffic: off, record or replay", &c.UpstreamCassetteMode},
+		{"UPSTREAM_CASSETTE_DIR", "cassette-dir", "directory of the upstream cassettes, one <upstream>.json each", &c.UpstreamCassetteDir},
+		{"UPSTREAM_CASSETTE_SCRUB", "cassette-scrub", "comma-separated headers scrubbed from cassettes, besides Authorization, Cookie and the like", &c.UpstreamCassetteScrub},
+		{"SNAPSHOT_MODE", "snapshot-mode", "local snapshot use: off, fallback (when an upstream fails) or exclusive", &c.SnapshotMode},
+		{"SNAPSHOT_FILE", "snapshot-file", "snapshot file written by the snapshot command", &c.SnapshotFile},
+		{"STORE_FILE", "store-file", "local store file kept in sync with the upstreams; empty disables it", &c.StoreFile},
+		{"STORE_SYNC_INTERVAL", "store-sync-interval", "how often the local store syncs", &c.StoreSyncInterval},
+		{"STORE_MAX_AGE", "store-max-age", "oldest sync served from the local store before falling back to the upstreams; 0 = any", &c.StoreMaxAge},
+		{"JOB_WORKERS", "job-workers", "summary jobs run at once", &c.JobWorkers},
+		{"JOB_CONCURRENCY", "job-concurrency", "summaries each job aggregates at once", &c.JobConcurrency},
+		{"JOB_MAX_USERS", "job-max-users", "most users in a summary job", &c.JobMaxUsers},
+		{"JOB_TTL", "job-ttl", "how long finished jobs are kept", &c.JobTTL},
+		{"JOB_PERSIST", "job-persist", "keep jobs in the local store across restarts (requires STORE_FILE)", &c.JobPersist},
+		{"WEBHOOKS", "webhooks", "webhook subscriptions as url|secret|EVENTS;...", &c.Webhooks},
+		{"WEBHOOK_CHECK_INTERVAL", "webhook-check-interval", "how often summaries are compared to detect changes (0 = disabled)", &c.WebhookCheckInterval},
+		{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts of a webhook delivery before it is dead-lettered", &c.WebhookMaxAttempts},
+		{"WEBHOOK_BACKOFF", "webhook-backoff", "wait before the first webhook retry, doubled after each one", &c.WebhookBackoff},
+		{"RATE_LIMIT_KEY", "rate-limit-key", "client rate limit key: api_key, ip or header:<Name>", &c.RateLimitKey},
+		{"RATE_LIMIT_TIERS", "rate-limit-tiers", "client rate limit tiers as name:rate:burst,... (empty = disabled)", &c.RateLimitTiers},
+		{"CACHE_CONTROL_DEFAULT_MAX_AGE", "cache-control-default-max-age", "max-age of GraphQL fields without a @cacheControl hint", &c.CacheControlDefaultMaxAge},
+		{"RESPONSE_CACHE_SIZE", "response-cache-size", "GraphQL query responses cached per their @cacheControl maxAge (0 = disabled)", &c.ResponseCacheSize},
+		{"RATE_LIMIT_IDLE_TTL", "rate-limit-idle-ttl", "evict client buckets idle for longer than this", &c.RateLimitIdleTTL},
+	}
+}
+
+// Load resolves the configuration from defaults, config file, environment and
+// the given command-line args, then validates it. All problems found are
+// returned together.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return LoadFlags(fs, args)
+}
+
+// LoadFlags is Load with the config flags registered on fs, so commands can
+// parse their own flags alongside them.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (env CONFIG_FILE)")
+	flagValues := map[string]*rawFlag{}
+	for _, s := range settings {
+		_, isBool := s.ptr.(*bool)
+		v := &rawFlag{isBool: isBool}
+		flagValues[s.flag] = v
+		fs.Var(v, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	if *configFile != "" {
+		errs = append(errs, cfg.loadFile(*configFile))
+		cfg.File = *configFile
+	}
+
+	// a variable set to an empty value clears what the file set
+	for _, s := range settings {
+		if val, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(val); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", s.env, err))
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v := flagValues[s.flag]; v.set {
+			if err := s.set(v.value); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", s.flag, err))
+			}
+		}
+	}
+
+	// fields that failed to parse keep their previous value, so validation
+	// still reports problems in the remaining ones
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks every setting and returns all problems joined. The
+// webhook, rate limit tier and GraphQL variable specs are parsed, and their
+// errors reported, by the components built from them.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("serverPort: invalid port %q", c.ServerPort))
+	}
+	for name, raw := range map[string]string{"usersBaseURL": c.UsersBaseURL, "postsBaseURL": c.PostsBaseURL} {
+		if err := validateURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	for _, src := range []struct{ name, source, query, path string }{
+		{"users", c.UsersSource, c.UsersGraphQLQuery, c.UsersGraphQLPath},
+		{"posts", c.PostsSource, c.PostsGraphQLQuery, c.PostsGraphQLPath},
+	} {
+		switch src.source {
+		case "rest":
+		case "graphql":
+			if strings.TrimSpace(src.query) == "" {
+				errs = append(errs, fmt.Errorf("%sGraphQLQuery: must not be empty", src.name))
+			}
+			if strings.TrimSpace(src.path) == "" {
+				errs = append(errs, fmt.Errorf("%sGraphQLPath: must not be empty", src.name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%sSource: must be rest or graphql, got %q", src.name, src.source))
+		}
+	}
+	// the GraphQL fetchers fetch one user at a time: they can't list users
+	// nor fetch every post at once
+	if c.UsersSource == "graphql" && c.WebhookCheckInterval > 0 {
+		errs = append(errs, errors.New("usersSource: graphql can't list users for the webhook detector, set webhookCheckInterval to 0"))
+	}
+	if c.PostsSource == "graphql" && c.StoreFile != "" {
+		errs = append(errs, errors.New("postsSource: graphql can't fetch every post for the store sync, unset storeFile"))
+	}
+	if c.HTTPTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("httpTimeout: must be positive, got %s", c.HTTPTimeout))
+	}
+	if c.AggTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("aggTimeout: must be positive, got %s", c.AggTimeout))
+	}
+	if c.HTTPTimeout > 0 && c.AggTimeout > 0 && c.AggTimeout < c.HTTPTimeout {
+		errs = append(errs, fmt.Errorf("aggTimeout (%s) must not be shorter than httpTimeout (%s), or a single upstream request could never complete", c.AggTimeout, c.HTTPTimeout))
+	}
+	if !slices.Contains([]string{"text", "json", "silent"}, c.LogMode) {
+		errs = append(errs, fmt.Errorf("logMode: must be text, json or silent, got %q", c.LogMode))
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("logLevel: %w", err))
+	}
+	if c.LogMaxSizeMB < 0 || c.LogMaxAge < 0 || c.LogMaxBackups < 0 {
+		errs = append(errs, errors.New("logMaxSizeMB/logMaxAge/logMaxBackups: must not be negative"))
+	}
+	if c.LogSampleFirst < 0 || c.LogSampleThereafter < 0 {
+		errs = append(errs, errors.New("logSampleFirst/logSampleThereafter: must not be negative"))
+	}
+	if _, err := c.LogRedactRules(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := auth.ParseTokens(c.AuthTokens); err != nil {
+		errs = append(errs, fmt.Errorf("authTokens: %w", err))
+	}
+	if c.UsersRPS < 0 || c.PostsRPS < 0 {
+		errs = append(errs, errors.New("usersRPS/postsRPS: must not be negative"))
+	}
+	if c.UsersMaxInFlight < 0 || c.PostsMaxInFlight < 0 {
+		errs = append(errs, errors.New("usersMaxInFlight/postsMaxInFlight: must not be negative"))
+	}
+	if c.BreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("breakerThreshold: must not be negative, got %d", c.BreakerThreshold))
+	}
+	if c.BreakerThreshold > 0 && c.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("breakerCooldown: must be positive, got %s", c.BreakerCooldown))
+	}
+	if c.UpstreamCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("upstreamCacheSize: must not be negative, got %d", c.UpstreamCacheSize))
+	}
+	if path, isUnix := strings.CutPrefix(c.AdminAddr, "unix:"); isUnix && path == "" {
+		errs = append(errs, errors.New("adminAddr: unix socket path is empty"))
+	} else if !isUnix && c.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			errs = append(errs, fmt.Errorf("adminAddr: %w", err))
+		}
+	}
+	if c.GRPCAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCAddr); err != nil {
+			errs = append(errs, fmt.Errorf("grpcAddr: %w", err))
+		}
+	}
+	if !slices.Contains([]string{"off", "record", "replay"}, c.UpstreamCassetteMode) {
+		errs = append(errs, fmt.Errorf("upstreamCassetteMode: must be off, record or replay, got %q", c.UpstreamCassetteMode))
+	} else if c.UpstreamCassetteMode != "off" && c.UpstreamCassetteDir == "" {
+		errs = append(errs, errors.New("upstreamCassetteDir: required when recording or replaying"))
+	}
+	if !slices.Contains([]string{"off", "fallback", "exclusive"}, c.SnapshotMode) {
+		errs = append(errs, fmt.Errorf("snapshotMode: must be off, fallback or exclusive, got %q", c.SnapshotMode))
+	} else if c.SnapshotMode != "off" && c.SnapshotFile == "" {
+		errs = append(errs, errors.New("snapshotFile: required when snapshotMode is not off"))
+	}
+	if c.StoreFile != "" && c.StoreSyncInterval <= 0 {
+		errs = append(errs, fmt.Errorf("storeSyncInterval: must be positive, got %s", c.StoreSyncInterval))
+	}
+	if c.StoreMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("storeMaxAge: must not be negative, got %s", c.StoreMaxAge))
+	}
+	if c.JobWorkers <= 0 || c.JobConcurrency <= 0 || c.JobMaxUsers <= 0 {
+		errs = append(errs, errors.New("jobWorkers/jobConcurrency/jobMaxUsers: must be positive"))
+	}
+	if c.JobTTL <= 0 {
+		errs = append(errs, fmt.Errorf("jobTTL: must be positive, got %s", c.JobTTL))
+	}
+	if c.JobPersist && c.StoreFile == "" {
+		errs = append(errs, errors.New("jobPersist: requires storeFile"))
+	}
+	if c.WebhookCheckInterval < 0 {
+		errs = append(errs, fmt.Errorf("webhookCheckInterval: must not be negative, got %s", c.WebhookCheckInterval))
+	}
+	if c.WebhookMaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("webhookMaxAttempts: must be positive, got %d", c.WebhookMaxAttempts))
+	}
+	if c.WebhookBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("webhookBackoff: must be positive, got %s", c.WebhookBackoff))
+	}
+	if c.RateLimitKey != "api_key" && c.RateLimitKey != "ip" && !strings.HasPrefix(c.RateLimitKey, "header:") {
+		errs = append(errs, fmt.Errorf("rateLimitKey: must be api_key, ip or header:<Name>, got %q", c.RateLimitKey))
+	}
+	if c.CacheControlDefaultMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cacheControlDefaultMaxAge: must not be negative, got %s", c.CacheControlDefaultMaxAge))
+	}
+	if c.ResponseCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("responseCacheSize: must not be negative, got %d", c.ResponseCacheSize))
+	}
+	if c.RateLimitIdleTTL <= 0 {
+		errs = append(errs, fmt.Errorf("rateLimitIdleTTL: must be positive, got %s", c.RateLimitIdleTTL))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel parses LogLevel.
+func (c *Config) SlogLevel() (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(c.LogLevel))
+	return l, err
+}
+
+// LogRedactRules builds the log redaction rules, or nil when redaction is off.
+func (c *Config) LogRedactRules() (*logger.RedactRules, error) {
+	if c.LogRedactMode != "mask" && c.LogRedactMode != "hash" {
+		return nil, fmt.Errorf("logRedactMode: must be mask or hash, got %q", c.LogRedactMode)
+	}
+	if !c.LogRedact {
+		return nil, nil
+	}
+
+	rules := logger.DefaultRedactRules()
+	rules.Fields = nil
+	for f := range strings.SplitSeq(c.LogRedactFields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			rules.Fields = append(rules.Fields, f)
+		}
+	}
+	for _, expr := range strings.Fields(c.LogRedactPatterns) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("logRedactPatterns: invalid regex %q: %w", expr, err)
+		}
+		rules.Patterns = append(rules.Patterns, re)
+	}
+	rules.Hash = c.LogRedactMode == "hash"
+	return &rules, nil
+}
+
+// LogValue logs the configuration without secrets.
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("file", c.File),
+		slog.String("port", c.ServerPort),
+		slog.String("usersURL", c.UsersBaseURL),
+		slog.String("postsURL", c.PostsBaseURL),
+		slog.String("usersSource", c.UsersSource),
+		slog.String("usersGraphQLPath", c.UsersGraphQLPath),
+		slog.String("postsSource", c.PostsSource),
+		slog.String("postsGraphQLPath", c.PostsGraphQLPath),
+		slog.Duration("httpTimeout", c.HTTPTimeout),
+		slog.Duration("aggTimeout", c.AggTimeout),
+		slog.Bool("introspection", c.EnableIntrospection),
+		slog.Bool("apq", c.EnableAPQ),
+		slog.String("logMode", c.LogMode),
+		slog.String("logLevel", c.LogLevel),
+		slog.String("logFile", c.LogFile),
+		slog.Int("logMaxSizeMB", c.LogMaxSizeMB),
+		slog.Duration("logMaxAge", c.LogMaxAge),
+		slog.Int("logMaxBackups", c.LogMaxBackups),
+		slog.Int("logSampleFirst", c.LogSampleFirst),
+		slog.Int("logSampleThereafter", c.LogSampleThereafter),
+		slog.Bool("logRedact", c.LogRedact),
+		slog.String("logRedactFields", c.LogRedactFields),
+		slog.String("logRedactPatterns", c.LogRedactPatterns),
+		slog.String("logRedactMode", c.LogRedactMode),
+		slog.Bool("authTokensSet", c.AuthTokens != ""),
+		slog.Float64("usersRPS", c.UsersRPS),
+		slog.Int("usersMaxInFlight", c.UsersMaxInFlight),
+		slog.Float64("postsRPS", c.PostsRPS),
+		slog.Int("postsMaxInFlight", c.PostsMaxInFlight),
+		slog.Int("breakerThreshold", c.BreakerThreshold),
+		slog.Duration("breakerCooldown", c.BreakerCooldown),
+		slog.Int("upstreamCacheSize", c.UpstreamCacheSize),
+		slog.String("adminAddr", c.AdminAddr),
+		slog.String("grpcAddr", c.GRPCAddr),
+		slog.Bool("chaos", c.EnableChaos),
+		slog.String("cassetteMode", c.UpstreamCassetteMode),
+		slog.String("cassetteDir", c.UpstreamCassetteDir),
+		slog.String("snapshotMode", c.SnapshotMode),
+		slog.String("snapshotFile", c.SnapshotFile),
+		slog.String("storeFile", c.StoreFile),
+		slog.Duration("storeSyncInterval", c.StoreSyncInterval),
+		slog.Duration("storeMaxAge", c.StoreMaxAge),
+		slog.Int("jobWorkers", c.JobWorkers),
+		slog.Int("jobConcurrency", c.JobConcurrency),
+		slog.Int("jobMaxUsers", c.JobMaxUsers),
+		slog.Duration("jobTTL", c.JobTTL),
+		slog.Bool("jobPersist", c.JobPersist),
+		slog.Bool("webhooksSet", c.Webhooks != ""),
+		slog.Duration("webhookCheckInterval", c.WebhookCheckInterval),
+		slog.Int("webhookMaxAttempts", c.WebhookMaxAttempts),
+		slog.Duration("webhookBackoff", c.WebhookBackoff),
+		slog.String("rateLimitKey", c.RateLimitKey),
+		slog.String("rateLimitTiers", c.RateLimitTiers),
+		slog.Duration("rateLimitIdleTTL", c.RateLimitIdleTTL),
+		slog.Duration("cacheControlDefaultMaxAge", c.CacheControlDefaultMaxAge),
+		slog.Int("responseCacheSize", c.ResponseCacheSize),
 	)
-	return cfg
 }
 
-func getEnv(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+func (c *Config) loadFile(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("config file %s: unsupported format %q, expected .yaml or .yml", path, ext)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening config file: %w", err)
+	}
+	defer f.Close()
+
+	// the decoder keeps going past fields it can't decode, which keep their
+	// previous value, and reports them all in a TypeError
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	var terr *yaml.TypeError
+	if errors.As(err, &terr) {
+		errs := make([]error, len(terr.Errors))
+		for i, msg := range terr.Errors {
+			errs[i] = fmt.Errorf("config file %s: %s", path, msg)
+		}
+		return errors.Join(errs...)
 	}
-	return defaultVal
+	if err != nil {
+		return fmt.Errorf("decoding config file %s: %w", path, err)
+	}
+	return nil
 }
 
-func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
-	if val := os.Getenv(key); val != "" {
-		dur, err := time.ParseDuration(val)
-		if err == nil {
-			return dur
+// set parses raw into the setting; an empty raw resets it to its zero value.
+func (s setting) set(raw string) error {
+	if raw == "" {
+		reflect.ValueOf(s.ptr).Elem().SetZero()
+		return nil
+	}
+	switch p := s.ptr.(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*p = b
+	case *int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = i
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
 		}
-		logger.Log.Info("invalid duration for env var, using default", "key", key, "val", val, "default", defaultVal)
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*p = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", s.ptr)
+	}
+	return nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", raw, err)
 	}
-	return defaultVal
-}
\ No newline at end of file
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an absolute http(s) URL", raw)
+	}
+	return nil
+}
+
+// rawFlag records a flag's raw value so it can be applied after the config
+// file and env vars, through the same parsing as env values.
+type rawFlag struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *rawFlag) String() string { return f.value }
+
+func (f *rawFlag) Set(v string) error {
+	f.value, f.set = v, true
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
@@ -0,0 +1,246 @@
+package config_test
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearEnv unsets every setting for the test, so the process env, e.g. that
+// of the Docker image, doesn't leak into the loaded config.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for env := range config.Default().Dump() {
+		t.Setenv(env, "") // restores the variable after the test
+		os.Unsetenv(env)
+	}
+}
+
+func Test_Load_Defaults(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+
+	cfg, err := config.Load(nil)
+
+	assert.Nil(err)
+	assert.Equal(config.Default(), cfg)
+}
+
+func Test_Load_Precedence(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+serverPort: "9000"
+httpTimeout: 2s
+aggTimeout: 4s
+logMode: json
+enableAPQ: true
+`)
+	t.Setenv("HTTP_TIMEOUT", "3s")
+	t.Setenv("LOG_MODE", "silent")
+
+	cfg, err := config.Load([]string{"-config", path, "-log-mode", "text", "-introspection"})
+
+	assert.Nil(err)
+	assert.Equal(path, cfg.File)
+	assert.Equal("9000", cfg.ServerPort, "file overrides defaults")
+	assert.Equal(4*time.Second, cfg.AggTimeout, "file overrides defaults")
+	assert.Equal(3*time.Second, cfg.HTTPTimeout, "env overrides file")
+	assert.Equal("text", cfg.LogMode, "flags override env")
+	assert.True(cfg.EnableAPQ)
+	assert.True(cfg.EnableIntrospection)
+}
+
+func Test_Load_ConfigFileFromEnv(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yml", "serverPort: \"9100\"\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := config.Load(nil)
+
+	assert.Nil(err)
+	assert.Equal("9100", cfg.ServerPort)
+}
+
+func Test_Load_EmptyEnvClearsFileValue(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", "logFile: /var/log/api.log\nusersRPS: 5\n")
+	t.Setenv("LOG_FILE", "")
+	t.Setenv("USERS_RPS", "")
+
+	cfg, err := config.Load([]string{"-config", path})
+
+	assert.Nil(err)
+	assert.Equal("", cfg.LogFile)
+	assert.Zero(cfg.UsersRPS)
+}
+
+func Test_Load_ReportsAllErrors(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	t.Setenv("HTTP_TIMEOUT", "soon")
+	t.Setenv("USERS_RPS", "fast")
+
+	cfg, err := config.Load([]string{"-posts-url", "not a url", "-agg-timeout", "-1s", "-log-mode", "xml"})
+
+	assert.Nil(cfg)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "env HTTP_TIMEOUT")
+	assert.Contains(err.Error(), "env USERS_RPS")
+	assert.Contains(err.Error(), "postsBaseURL")
+	assert.Contains(err.Error(), "aggTimeout: must be positive")
+	assert.Contains(err.Error(), "logMode")
+}
+
+func Test_Validate_AggTimeoutShorterThanHTTPTimeout(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.Default()
+	cfg.HTTPTimeout = 8 * time.Second
+	cfg.AggTimeout = 6 * time.Second
+
+	err := cfg.Validate()
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "must not be shorter than httpTimeout")
+}
+
+func Test_Validate_GraphQLSources(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.Default()
+	assert.Nil(cfg.Validate(), "the GraphQL defaults are only checked when used")
+
+	cfg.UsersSource = "graphql"
+	cfg.WebhookCheckInterval = 0
+	assert.Nil(cfg.Validate())
+
+	cfg.PostsSource = "soap"
+	cfg.UsersGraphQLPath = ""
+	err := cfg.Validate()
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "postsSource: must be rest or graphql")
+	assert.Contains(err.Error(), "usersGraphQLPath: must not be empty")
+}
+
+func Test_Validate_GraphQLSourcesCantList(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.Default()
+	cfg.UsersSource, cfg.PostsSource = "graphql", "graphql"
+	cfg.StoreFile = "store.db"
+
+	err := cfg.Validate()
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "usersSource: graphql can't list users for the webhook detector")
+	assert.Contains(err.Error(), "postsSource: graphql can't fetch every post for the store sync")
+
+	cfg.WebhookCheckInterval, cfg.StoreFile = 0, ""
+	assert.Nil(cfg.Validate())
+}
+
+func Test_Load_UnknownFileKey(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", "serverPrt: \"9000\"\n")
+
+	_, err := config.Load([]string{"-config", path})
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "serverPrt")
+}
+
+func Test_Load_ReportsAllFileErrors(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", "serverPrt: \"9000\"\nhttpTimeout: soon\nusersRPS: fast\n")
+	t.Setenv("AGG_TIMEOUT", "later")
+
+	_, err := config.Load([]string{"-config", path})
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "serverPrt")
+	assert.Contains(err.Error(), "soon")
+	assert.Contains(err.Error(), "fast")
+	assert.Contains(err.Error(), "env AGG_TIMEOUT")
+}
+
+func Test_Load_UnsupportedFileFormat(t *testing.T) {
+	assert := assert.New(t)
+	clearEnv(t)
+	path := writeConfigFile(t, "config.toml", "serverPort = \"9000\"\n")
+
+	_, err := config.Load([]string{"-config", path})
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "unsupported format")
+}
+
+func Test_Diff_MasksSecretsAndFlagsRestart(t *testing.T) {
+	assert := assert.New(t)
+	old := config.Default()
+	next := config.Default()
+	next.UsersBaseURL = "https://example.com/users"
+	next.AggTimeout = 8 * time.Second
+	next.AuthTokens = "s3cret|alice"
+
+	changes := config.Diff(old, next)
+
+	assert.Equal([]config.Change{
+		{Setting: "USERS_BASE_URL", Old: "https://jsonplaceholder.typicode.com/users", New: "https://example.com/users"},
+		{Setting: "AGG_TIMEOUT", Old: "5s", New: "8s"},
+		{Setting: "AUTH_TOKENS", Old: "", New: "****", RestartRequired: true},
+	}, changes)
+	assert.Empty(config.Diff(old, config.Default()))
+}
+
+func Test_KeepRestartSettings(t *testing.T) {
+	assert := assert.New(t)
+	running := config.Default()
+	next := config.Default()
+	next.AggTimeout = 8 * time.Second
+	next.JobWorkers = 7
+	next.GRPCAddr = ":9090"
+	next.RateLimitIdleTTL = time.Hour
+
+	next.KeepRestartSettings(running)
+
+	assert.Equal(8*time.Second, next.AggTimeout)
+	assert.Equal(running.JobWorkers, next.JobWorkers)
+	assert.Equal(running.GRPCAddr, next.GRPCAddr)
+	assert.Equal(running.RateLimitIdleTTL, next.RateLimitIdleTTL)
+}
+
+func Test_Watch_CallsOnChange(t *testing.T) {
+	assert := assert.New(t)
+	path := writeConfigFile(t, "config.yaml", "serverPort: \"9000\"\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	go config.Watch(ctx, path, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(os.WriteFile(path, []byte("serverPort: \"9001\"\n"), 0o600))
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected onChange to be called")
+	}
+}
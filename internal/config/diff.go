@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// secretSettings are masked whenever a value is printed.
+var secretSettings = map[string]bool{"AUTH_TOKENS": true, "WEBHOOKS": true}
+
+// restartSettings only take effect after a restart.
+var restartSettings = map[string]bool{
+	"SERVER_PORT":            true,
+	"LOG_MODE":               true,
+	"LOG_FILE":               true,
+	"LOG_MAX_SIZE_MB":        true,
+	"LOG_MAX_AGE":            true,
+	"LOG_MAX_BACKUPS":        true,
+	"LOG_SAMPLE_FIRST":       true,
+	"LOG_SAMPLE_THEREAFTER":  true,
+	"LOG_REDACT":             true,
+	"LOG_REDACT_FIELDS":      true,
+	"LOG_REDACT_PATTERNS":    true,
+	"LOG_REDACT_MODE":        true,
+	"ADMIN_ADDR":             true,
+	"GRPC_ADDR":              true,
+	"AUTH_TOKENS":            true,
+	"STORE_FILE":             true,
+	"STORE_SYNC_INTERVAL":    true,
+	"JOB_WORKERS":            true,
+	"JOB_CONCURRENCY":        true,
+	"JOB_MAX_USERS":          true,
+	"JOB_TTL":                true,
+	"JOB_PERSIST":            true,
+	"WEBHOOKS":               true,
+	"WEBHOOK_CHECK_INTERVAL": true,
+	"WEBHOOK_MAX_ATTEMPTS":   true,
+	"WEBHOOK_BACKOFF":        true,
+	"RATE_LIMIT_IDLE_TTL":    true,
+}
+
+// KeepRestartSettings sets the settings that only take effect after a restart
+// back to those of running, so c describes the config in effect after a reload.
+func (c *Config) KeepRestartSettings(running *Config) {
+	current := running.settings()
+	for i, s := range c.settings() {
+		if restartSettings[s.env] {
+			s.copy(current[i])
+		}
+	}
+}
+
+// Change describes a setting that differs between two configs.
+type Change struct {
+	Setting         string
+	Old             string
+	New             string
+	RestartRequired bool
+}
+
+// Diff lists the settings that differ from old to new, with secrets masked.
+func Diff(old, new *Config) []Change {
+	oldSettings, newSettings := old.settings(), new.settings()
+
+	var changes []Change
+	for i, s := range oldSettings {
+		o, n := s.format(), newSettings[i].format()
+		if o == n {
+			continue
+		}
+		if secretSettings[s.env] {
+			o, n = mask(o), mask(n)
+		}
+		changes = append(changes, Change{Setting: s.env, Old: o, New: n, RestartRequired: restartSettings[s.env]})
+	}
+	return changes
+}
+
+// Watch polls path every interval and calls onChange whenever the file's
+// modification time or size changes, until ctx is done.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				onChange()
+			}
+		}
+	}
+}
+
+func (s setting) format() string {
+	switch p := s.ptr.(type) {
+	case *string:
+		return *p
+	case *bool:
+		return fmt.Sprint(*p)
+	case *int:
+		return fmt.Sprint(*p)
+	case *float64:
+		return fmt.Sprint(*p)
+	case *time.Duration:
+		return p.String()
+	default:
+		return fmt.Sprint(p)
+	}
+}
+
+// copy sets the value of s to that of from, the same setting of another config.
+func (s setting) copy(from setting) {
+	switch p := s.ptr.(type) {
+	case *string:
+		*p = *from.ptr.(*string)
+	case *bool:
+		*p = *from.ptr.(*bool)
+	case *int:
+		*p = *from.ptr.(*int)
+	case *float64:
+		*p = *from.ptr.(*float64)
+	case *time.Duration:
+		*p = *from.ptr.(*time.Duration)
+	}
+}
+
+func mask(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "****"
+}
+
+// Dump returns every setting keyed by env var name, with secrets masked.
+func (c *Config) Dump() map[string]string {
+	dump := map[string]string{"CONFIG_FILE": c.File}
+	for _, s := range c.settings() {
+		v := s.format()
+		if secretSettings[s.env] {
+			v = mask(v)
+		}
+		dump[s.env] = v
+	}
+	return dump
+}
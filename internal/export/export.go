@@ -0,0 +1,191 @@
+// Package export writes user summaries in bulk as CSV, NDJSON or Parquet,
+// streaming them as the aggregator produces them.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/fetcher"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MaxConcurrency caps the summaries aggregated at once.
+const MaxConcurrency = 16
+
+// Row is an exported summary. Email is only set when Options.IncludeEmail is.
+type Row struct {
+	ID        int     `json:"id" parquet:"id"`
+	Name      string  `json:"name" parquet:"name"`
+	Email     *string `json:"email,omitempty" parquet:"email,optional"`
+	PostCount int     `json:"postCount" parquet:"post_count"`
+}
+
+// Failure is a user whose summary could not be exported.
+type Failure struct {
+	ID    int    `json:"id"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// ErrCantList is returned for an export of every user when the user fetcher
+// can't list users, as the GraphQL one.
+var ErrCantList = errors.New("the user fetcher can't list users, pass the IDs to export")
+
+// Options configures a run.
+type Options struct {
+	Aggregator *aggregator.Aggregator
+	Format     Format
+	// IDs are the users exported, in order; nil exports every user listed by
+	// the aggregator's user fetcher, which must implement fetcher.UserLister.
+	IDs          []int
+	Concurrency  int
+	IncludeEmail bool
+	// FailuresSection appends the failures to the output (see Format);
+	// otherwise they are only returned.
+	FailuresSection bool
+}
+
+// Result summarizes a run.
+type Result struct {
+	Exported int
+	Failures []Failure
+}
+
+// Run writes the summaries of opts.IDs to w in order. At most opts.Concurrency
+// summaries are aggregated, or waiting to be written, at once, so memory stays
+// bounded whatever the number of users. Users that fail are reported in the
+// result; the returned error is only set when the output itself fails.
+func Run(ctx context.Context, w io.Writer, opts Options) (*Result, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	opts.Concurrency = min(opts.Concurrency, MaxConcurrency)
+	ids := opts.IDs
+	if ids == nil {
+		var err error
+		if ids, err = AllUserIDs(ctx, opts.Aggregator.UserFetcher); err != nil {
+			return nil, err
+		}
+	}
+	out, err := newWriter(opts.Format, w)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		row     Row
+		failure *Failure
+	}
+	// each pending channel is a summary in flight; the writer takes them in
+	// order, so its capacity bounds both concurrency and buffered results
+	pending := make(chan chan result, opts.Concurrency-1)
+	go func() {
+		defer close(pending)
+		for _, id := range ids {
+			ch := make(chan result, 1)
+			select {
+			case pending <- ch:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				summary, err := opts.Aggregator.GetUserSummary(ctx, id)
+				if err != nil {
+					ch <- result{failure: &Failure{ID: id, Code: apierror.Code(err), Error: err.Error()}}
+					return
+				}
+				row := Row{ID: id, Name: summary.Name, PostCount: summary.PostCount}
+				if opts.IncludeEmail {
+					row.Email = &summary.Email
+				}
+				ch <- result{row: row}
+			}()
+		}
+	}()
+
+	res := &Result{}
+	for ch := range pending {
+		r := <-ch
+		if r.failure != nil {
+			res.Failures = append(res.Failures, *r.failure)
+			continue
+		}
+		if err := out.Write(r.row); err != nil {
+			return res, fmt.Errorf("writing export: %w", err)
+		}
+		res.Exported++
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	var failures []Failure
+	if opts.FailuresSection {
+		failures = res.Failures
+	}
+	if err := out.Close(failures); err != nil {
+		return res, fmt.Errorf("writing export: %w", err)
+	}
+	return res, nil
+}
+
+// AllUserIDs lists the IDs of every user, sorted.
+func AllUserIDs(ctx context.Context, users fetcher.UserFetcher) ([]int, error) {
+	lister, ok := users.(fetcher.UserLister)
+	if !ok {
+		return nil, ErrCantList
+	}
+	all, err := lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	ids := make([]int, 0, len(all))
+	for _, u := range all {
+		ids = append(ids, u.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// ParseIDs parses a comma-separated list of IDs and inclusive ranges, such as
+// "1-5,8,10-12". IDs are deduplicated and sorted.
+func ParseIDs(spec string) ([]int, error) {
+	var ids []int
+	for part := range strings.SplitSeq(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || first <= 0 {
+			return nil, fmt.Errorf("invalid user ID %q", from)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || last < first {
+				return nil, fmt.Errorf("invalid user ID range %q", part)
+			}
+			if last-first >= 1_000_000 {
+				return nil, fmt.Errorf("user ID range %q is too large", part)
+			}
+		}
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no user IDs")
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
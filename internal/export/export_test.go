@@ -0,0 +1,230 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/export"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func newAggregator(t *testing.T) *aggregator.Aggregator {
+	upstream := test.StartFakeUpstream(t)
+	return aggregator.NewAggregator(
+		&fetcher.HTTPUserFetcher{Client: http.DefaultClient, BaseURL: upstream.UsersURL()},
+		&fetcher.HTTPPostsFetcher{Client: http.DefaultClient, BaseURL: upstream.PostsURL()},
+		2*time.Second,
+	)
+}
+
+func Test_ParseIDs(t *testing.T) {
+	assert := assert.New(t)
+
+	ids, err := export.ParseIDs("8, 1-3,2,10-11")
+	assert.Nil(err)
+	assert.Equal([]int{1, 2, 3, 8, 10, 11}, ids)
+
+	for _, spec := range []string{"", "a", "0", "3-1", "1-x", "1-2000000"} {
+		_, err := export.ParseIDs(spec)
+		assert.NotNil(err, spec)
+	}
+}
+
+func Test_Run_CSV(t *testing.T) {
+	assert := assert.New(t)
+	agg := newAggregator(t)
+
+	var buf bytes.Buffer
+	res, err := export.Run(context.Background(), &buf, export.Options{
+		Aggregator:      agg,
+		Format:          export.CSV,
+		IDs:             []int{2, 1, 42},
+		Concurrency:     2,
+		FailuresSection: true,
+	})
+	assert.Nil(err)
+	assert.Equal(2, res.Exported)
+	if assert.Len(res.Failures, 1) {
+		assert.Equal(42, res.Failures[0].ID)
+		assert.Equal(apierror.CodeNotFound, res.Failures[0].Code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(lines, 6) {
+		assert.Equal("id,name,email,post_count", lines[0])
+		assert.Equal("2,Ervin Howell,,10", lines[1])
+		assert.Equal("1,Leanne Graham,,10", lines[2])
+		assert.Equal("", lines[3])
+		assert.Equal("failed_id,code,error", lines[4])
+		assert.True(strings.HasPrefix(lines[5], "42,NOT_FOUND,"), lines[5])
+	}
+}
+
+func Test_Run_NDJSONAllUsers(t *testing.T) {
+	assert := assert.New(t)
+	agg := newAggregator(t)
+
+	var buf bytes.Buffer
+	res, err := export.Run(context.Background(), &buf, export.Options{
+		Aggregator:   agg,
+		Format:       export.NDJSON,
+		IncludeEmail: true,
+	})
+	assert.Nil(err)
+	assert.Equal(10, res.Exported)
+	assert.Empty(res.Failures)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(lines, 10) {
+		assert.JSONEq(`{"id":1,"name":"Leanne Graham","email":"Sincere@april.biz","postCount":10}`, lines[0])
+		var last export.Row
+		assert.Nil(json.Unmarshal([]byte(lines[9]), &last))
+		assert.Equal(10, last.ID)
+	}
+}
+
+func Test_Run_Parquet(t *testing.T) {
+	assert := assert.New(t)
+	agg := newAggregator(t)
+
+	var buf bytes.Buffer
+	res, err := export.Run(context.Background(), &buf, export.Options{
+		Aggregator:      agg,
+		Format:          export.Parquet,
+		IDs:             []int{1, 3, 42},
+		FailuresSection: true,
+	})
+	assert.Nil(err)
+	assert.Equal(2, res.Exported)
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !assert.Nil(err) {
+		return
+	}
+	rows, err := parquet.Read[export.Row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Nil(err)
+	if assert.Len(rows, 2) {
+		assert.Equal(export.Row{ID: 1, Name: "Leanne Graham", PostCount: 10}, rows[0])
+		assert.Equal(3, rows[1].ID)
+		assert.Nil(rows[1].Email)
+	}
+
+	failures, ok := file.Lookup("failures")
+	assert.True(ok)
+	var parsed []export.Failure
+	assert.Nil(json.Unmarshal([]byte(failures), &parsed))
+	if assert.Len(parsed, 1) {
+		assert.Equal(export.Failure{ID: 42, Code: apierror.CodeNotFound, Error: res.Failures[0].Error}, parsed[0])
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	assert := assert.New(t)
+	agg := newAggregator(t)
+	h := export.Handler(func() *aggregator.Aggregator { return agg })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "ops", Roles: []string{"ADMIN"}})))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/export?format=ndjson&ids=1,42")
+	if !assert.Nil(err) {
+		return
+	}
+	body := new(bytes.Buffer)
+	_, err = body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(`attachment; filename="summaries.ndjson"`, resp.Header.Get("Content-Disposition"))
+	assert.Equal("1", resp.Trailer.Get("Export-Exported"))
+	assert.Equal("1", resp.Trailer.Get("Export-Failed"))
+
+	lines := strings.Split(strings.TrimSpace(body.String()), "\n")
+	if assert.Len(lines, 2) {
+		assert.JSONEq(`{"id":1,"name":"Leanne Graham","postCount":10}`, lines[0])
+		assert.Contains(lines[1], `"code":"NOT_FOUND"`)
+	}
+}
+
+func Test_Handler_Email(t *testing.T) {
+	assert := assert.New(t)
+	agg := newAggregator(t)
+	h := export.Handler(func() *aggregator.Aggregator { return agg })
+
+	req := httptest.NewRequest(http.MethodGet, "/export?format=csv&ids=1", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Scopes: []string{export.Scope, "read:pii"}}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("id,name,email,post_count\n1,Leanne Graham,Sincere@april.biz,10\n", w.Body.String())
+}
+
+func Test_Handler_Unauthenticated(t *testing.T) {
+	assert := assert.New(t)
+	h := export.Handler(func() *aggregator.Aggregator { return nil })
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?ids=1", nil))
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.Contains(w.Body.String(), `"code":"UNAUTHENTICATED"`)
+}
+
+func Test_Handler_Forbidden(t *testing.T) {
+	assert := assert.New(t)
+	h := export.Handler(func() *aggregator.Aggregator { return nil })
+
+	req := httptest.NewRequest(http.MethodGet, "/export?ids=1", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "bob", Roles: []string{"USER"}, Scopes: []string{"read:pii"}}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Contains(w.Body.String(), `"code":"FORBIDDEN"`)
+}
+
+func Test_Handler_BadRequest(t *testing.T) {
+	assert := assert.New(t)
+	h := export.Handler(func() *aggregator.Aggregator { return nil })
+	admin := &auth.Principal{Subject: "ops", Roles: []string{"ADMIN"}}
+
+	for _, query := range []string{"format=xml", "ids=0", "concurrency=-1"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/export?"+query, nil)
+		h.ServeHTTP(w, req.WithContext(auth.WithPrincipal(req.Context(), admin)))
+		assert.Equal(http.StatusBadRequest, w.Code, query)
+		assert.Contains(w.Body.String(), `"code":"BAD_REQUEST"`, query)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/export", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+}
+
+func Test_Handler_AllUsersNeedsLister(t *testing.T) {
+	assert := assert.New(t)
+	agg := aggregator.NewAggregator(&fetcher.GraphQLUserFetcher{}, &fetcher.HTTPPostsFetcher{}, time.Second)
+	h := export.Handler(func() *aggregator.Aggregator { return agg })
+
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "ops", Roles: []string{"ADMIN"}})))
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Contains(w.Body.String(), export.ErrCantList.Error())
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Format is an output format. Each has its own failures section:
+//
+//   - CSV: after the summaries, an empty line and a failed_id,code,error table;
+//   - NDJSON: after the summaries, a {"id","code","error"} line per failure;
+//   - Parquet: the "failures" key of the file metadata, as a JSON array.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case CSV, NDJSON, Parquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv, ndjson or parquet", s)
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/vnd.apache.parquet"
+}
+
+// rowWriter writes rows as they come and, on Close, the failures section.
+type rowWriter interface {
+	Write(Row) error
+	Close(failures []Failure) error
+}
+
+func newWriter(f Format, w io.Writer) (rowWriter, error) {
+	switch f {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"id", "name", "email", "post_count"}); err != nil {
+			return nil, err
+		}
+		return &csvWriter{out: w, w: cw}, nil
+	case NDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case Parquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[Row](w, parquet.Compression(&parquet.Snappy))}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// flushEvery bounds the rows buffered before they reach the output.
+const flushEvery = 1000
+
+type csvWriter struct {
+	out  io.Writer
+	w    *csv.Writer
+	rows int
+}
+
+func (c *csvWriter) Write(r Row) error {
+	email := ""
+	if r.Email != nil {
+		email = *r.Email
+	}
+	if err := c.w.Write([]string{strconv.Itoa(r.ID), r.Name, email, strconv.Itoa(r.PostCount)}); err != nil {
+		return err
+	}
+	if c.rows++; c.rows%flushEvery == 0 {
+		c.w.Flush()
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close(failures []Failure) error {
+	if len(failures) > 0 {
+		c.w.Flush()
+		if _, err := io.WriteString(c.out, "\n"); err != nil {
+			return err
+		}
+		c.w.Write([]string{"failed_id", "code", "error"})
+		for _, f := range failures {
+			c.w.Write([]string{strconv.Itoa(f.ID), f.Code, f.Error})
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(r Row) error {
+	return n.enc.Encode(r)
+}
+
+func (n *ndjsonWriter) Close(failures []Failure) error {
+	for _, f := range failures {
+		if err := n.enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type parquetWriter struct {
+	w    *parquet.GenericWriter[Row]
+	rows int
+}
+
+func (p *parquetWriter) Write(r Row) error {
+	if _, err := p.w.Write([]Row{r}); err != nil {
+		return err
+	}
+	if p.rows++; p.rows%flushEvery == 0 {
+		return p.w.Flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close(failures []Failure) error {
+	if len(failures) > 0 {
+		b, err := json.Marshal(failures)
+		if err != nil {
+			return err
+		}
+		p.w.SetKeyValueMetadata("failures", string(b))
+	}
+	return p.w.Close()
+}
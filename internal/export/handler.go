@@ -0,0 +1,101 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Scope grants access to the /export endpoint to callers without the ADMIN role.
+const Scope = "export"
+
+// Handler serves GET /export?format=csv|ndjson|parquet&ids=1-5,8&concurrency=4,
+// streaming the summaries of the given users, or of every user, with the
+// failures section appended. Exports are restricted to admins and callers
+// granted Scope, as one request fans out to every user. Email is only exported
+// for callers granted the read:pii scope. The counts are sent in the
+// Export-Exported and Export-Failed trailers.
+func Handler(agg func() *aggregator.Aggregator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+			return
+		}
+		principal := auth.FromContext(r.Context())
+		if principal == nil {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthenticated, "authentication required")
+			return
+		}
+		if !principal.HasRole(auth.RoleAdmin) && !principal.HasScope(Scope) {
+			apierror.Write(w, http.StatusForbidden, apierror.CodeForbidden, "forbidden: requires role ADMIN or scope "+Scope)
+			return
+		}
+		q := r.URL.Query()
+		opts := Options{
+			Aggregator:      agg(),
+			Format:          CSV,
+			IncludeEmail:    principal.HasScope("read:pii"),
+			FailuresSection: true,
+		}
+		if v := q.Get("format"); v != "" {
+			f, err := ParseFormat(v)
+			if err != nil {
+				apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+				return
+			}
+			opts.Format = f
+		}
+		if v := q.Get("ids"); v != "" {
+			ids, err := ParseIDs(v)
+			if err != nil {
+				apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+				return
+			}
+			opts.IDs = ids
+		}
+		if v := q.Get("concurrency"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("invalid concurrency %q", v))
+				return
+			}
+			opts.Concurrency = n
+		}
+		if opts.IDs == nil {
+			// list first, so a failure can still be reported with a status
+			ids, err := AllUserIDs(r.Context(), opts.Aggregator.UserFetcher)
+			if errors.Is(err, ErrCantList) {
+				apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+				return
+			}
+			if err != nil {
+				apierror.Write(w, http.StatusBadGateway, apierror.CodeUpstream, err.Error())
+				return
+			}
+			opts.IDs = ids
+		}
+
+		// an export can outlast the server write timeout
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", opts.Format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="summaries.%s"`, opts.Format))
+		w.Header().Set("Trailer", "Export-Exported, Export-Failed")
+		res, err := Run(r.Context(), w, opts)
+		if err != nil {
+			// the status is already sent: the client sees a truncated body
+			logger.Log.Error("export failed", "format", opts.Format, "error", err)
+			return
+		}
+		w.Header().Set("Export-Exported", strconv.Itoa(res.Exported))
+		w.Header().Set("Export-Failed", strconv.Itoa(len(res.Failures)))
+		logger.Log.Info("export done", "format", opts.Format, "exported", res.Exported, "failed", len(res.Failures))
+	})
+}
@@ -0,0 +1,365 @@
+// Package fakeupstream serves users, posts and comments from fixture JSON with
+// jsonplaceholder-compatible routes and filters, plus injectable latency and
+// errors, so the whole stack can run and be tested offline.
+package fakeupstream
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Resources are the collections served, each read from <name>.json.
+var Resources = []string{"users", "posts", "comments"}
+
+// AllResources is the Faults key applied to resources without their own faults.
+const AllResources = "*"
+
+// Faults are injected into requests to a resource: each request waits Latency
+// plus up to Jitter, then a fraction ErrorRate (0 to 1) of them is answered
+// with ErrorStatus instead of the data.
+type Faults struct {
+	Latency     time.Duration
+	Jitter      time.Duration
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+// Validate checks the fault values.
+func (f Faults) Validate() error {
+	if f.Latency < 0 || f.Jitter < 0 {
+		return fmt.Errorf("latency and jitter must not be negative")
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("error rate must be between 0 and 1, got %v", f.ErrorRate)
+	}
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599) {
+		return fmt.Errorf("error status must be 4xx or 5xx, got %d", f.ErrorStatus)
+	}
+	return nil
+}
+
+// MarshalJSON writes the durations in time.Duration string form, as ParseFaults reads them.
+func (f Faults) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"latency":     f.Latency.String(),
+		"jitter":      f.Jitter.String(),
+		"errorRate":   f.ErrorRate,
+		"errorStatus": f.ErrorStatus,
+	})
+}
+
+// ParseFaults reads faults from latency, jitter, errorRate and errorStatus
+// values, starting from base for the ones absent.
+func ParseFaults(base Faults, values url.Values) (Faults, error) {
+	f := base
+	var err error
+	if v := values.Get("latency"); v != "" {
+		if f.Latency, err = time.ParseDuration(v); err != nil {
+			return base, fmt.Errorf("latency: %w", err)
+		}
+	}
+	if v := values.Get("jitter"); v != "" {
+		if f.Jitter, err = time.ParseDuration(v); err != nil {
+			return base, fmt.Errorf("jitter: %w", err)
+		}
+	}
+	if v := values.Get("errorRate"); v != "" {
+		if f.ErrorRate, err = strconv.ParseFloat(v, 64); err != nil {
+			return base, fmt.Errorf("errorRate: %w", err)
+		}
+	}
+	if v := values.Get("errorStatus"); v != "" {
+		if f.ErrorStatus, err = strconv.Atoi(v); err != nil {
+			return base, fmt.Errorf("errorStatus: %w", err)
+		}
+	}
+	return f, f.Validate()
+}
+
+// Options configures a Server.
+type Options struct {
+	// Dir, if set, holds the fixture files instead of the embedded ones.
+	Dir string
+	// Faults applied to every resource until changed.
+	Faults Faults
+	// Seed makes injected errors and jitter reproducible; zero seeds randomly.
+	Seed uint64
+}
+
+// Server is an http.Handler serving the fixtures:
+//
+//	GET /{resource}                  all items, filtered by query params (?userId=1)
+//	GET /{resource}/{id}             one item, 404 with {} when absent
+//	GET /{resource}/{id}/{child}     child items of the parent (/users/1/posts)
+//	GET, PUT /_faults?resource=      current faults / change them (see ParseFaults)
+//
+// Filters match fields by their string form; repeating a param ORs its values.
+// _start and _limit paginate, as in jsonplaceholder.
+type Server struct {
+	data     map[string][]item
+	requests atomic.Int64
+
+	mu     sync.Mutex
+	faults map[string]Faults
+	rand   *rand.Rand
+}
+
+// New loads the fixtures and returns a Server.
+func New(opts Options) (*Server, error) {
+	if err := opts.Faults.Validate(); err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	if opts.Dir != "" {
+		fsys = os.DirFS(opts.Dir)
+	} else {
+		sub, err := fs.Sub(fixtures, "fixtures")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	data := make(map[string][]item, len(Resources))
+	for _, name := range Resources {
+		items, err := loadFixture(fsys, name+".json")
+		if err != nil {
+			return nil, err
+		}
+		data[name] = items
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Server{
+		data:   data,
+		faults: map[string]Faults{AllResources: opts.Faults},
+		rand:   rand.New(rand.NewPCG(seed, seed)),
+	}, nil
+}
+
+// item keeps the fixture JSON as written, so responses preserve its key
+// order, and its top-level fields for filtering.
+type item struct {
+	raw    json.RawMessage
+	fields map[string]any
+}
+
+func loadFixture(fsys fs.FS, name string) ([]item, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("opening fixture: %w", err)
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return nil, fmt.Errorf("decoding fixture %s: %w", name, err)
+	}
+	items := make([]item, len(raws))
+	for i, raw := range raws {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&items[i].fields); err != nil {
+			return nil, fmt.Errorf("decoding fixture %s: item %d: %w", name, i, err)
+		}
+		items[i].raw = raw
+	}
+	return items, nil
+}
+
+// SetFaults changes the faults of resource, or of every resource without its
+// own faults when resource is AllResources.
+func (s *Server) SetFaults(resource string, f Faults) error {
+	if _, ok := s.data[resource]; !ok && resource != AllResources {
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[resource] = f
+	return nil
+}
+
+// Faults returns the faults applied to resource.
+func (s *Server) Faults(resource string) Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.faults[resource]; ok {
+		return f
+	}
+	return s.faults[AllResources]
+}
+
+// Requests returns how many resource requests were served, faulty ones included.
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(path.Clean(r.URL.Path), "/"), "/")
+	if parts[0] == "_faults" {
+		s.serveFaults(w, r)
+		return
+	}
+
+	resource := parts[0]
+	items, ok := s.data[resource]
+	if !ok || len(parts) > 3 {
+		writeJSON(w, http.StatusNotFound, map[string]any{})
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{})
+		return
+	}
+
+	s.requests.Add(1)
+	if !s.injectFaults(w, r, resource) {
+		return
+	}
+
+	switch len(parts) {
+	case 1:
+		writeJSON(w, http.StatusOK, filter(items, r.URL.Query()))
+	case 2:
+		for _, it := range items {
+			if fmt.Sprint(it.fields["id"]) == parts[1] {
+				writeJSON(w, http.StatusOK, it.raw)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]any{})
+	case 3:
+		children, ok := s.data[parts[2]]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{})
+			return
+		}
+		q := r.URL.Query()
+		q.Set(strings.TrimSuffix(resource, "s")+"Id", parts[1])
+		writeJSON(w, http.StatusOK, filter(children, q))
+	}
+}
+
+// injectFaults delays the request and answers it with an error when drawn,
+// reporting whether the request should still be served.
+func (s *Server) injectFaults(w http.ResponseWriter, r *http.Request, resource string) bool {
+	f := s.Faults(resource)
+
+	s.mu.Lock()
+	delay := f.Latency
+	if f.Jitter > 0 {
+		delay += time.Duration(s.rand.Int64N(int64(f.Jitter)))
+	}
+	fail := f.ErrorRate > 0 && s.rand.Float64() < f.ErrorRate
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(delay):
+		}
+	}
+	if fail {
+		status := f.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, map[string]any{"error": "injected fault"})
+		return false
+	}
+	return true
+}
+
+func (s *Server) serveFaults(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		resource = AllResources
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		f, err := ParseFaults(s.Faults(resource), r.URL.Query())
+		if err == nil {
+			err = s.SetFaults(resource, f)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{})
+		return
+	}
+
+	s.mu.Lock()
+	faults := make(map[string]Faults, len(s.faults))
+	for k, v := range s.faults {
+		faults[k] = v
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, faults)
+}
+
+// filter returns the items matching every query param, ignoring the ones
+// starting with "_" except for the _start and _limit pagination.
+func filter(items []item, q url.Values) []json.RawMessage {
+	matched := []json.RawMessage{}
+	for _, it := range items {
+		if matches(it.fields, q) {
+			matched = append(matched, it.raw)
+		}
+	}
+
+	start, _ := strconv.Atoi(q.Get("_start"))
+	start = min(max(start, 0), len(matched))
+	matched = matched[start:]
+	if limit, err := strconv.Atoi(q.Get("_limit")); err == nil && limit >= 0 && limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+func matches(fields map[string]any, q url.Values) bool {
+	for key, values := range q {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		if !slices.Contains(values, fmt.Sprint(fields[key])) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
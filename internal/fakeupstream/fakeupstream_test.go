@@ -0,0 +1,171 @@
+package fakeupstream_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/fakeupstream"
+	"go-graphql-aggregator/internal/fetcher"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, h http.Handler, method, target string) (*httptest.ResponseRecorder, any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var body any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func Test_Server_Routes(t *testing.T) {
+	assert := assert.New(t)
+	srv, err := fakeupstream.New(fakeupstream.Options{})
+	assert.Nil(err)
+
+	w, body := get(t, srv, http.MethodGet, "/users")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(body, 10)
+
+	w, body = get(t, srv, http.MethodGet, "/users/1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("Leanne Graham", body.(map[string]any)["name"])
+
+	w, body = get(t, srv, http.MethodGet, "/users/999")
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Equal(map[string]any{}, body)
+
+	_, body = get(t, srv, http.MethodGet, "/posts?userId=1")
+	assert.Len(body, 10)
+
+	_, body = get(t, srv, http.MethodGet, "/posts?userId=1&userId=2")
+	assert.Len(body, 20, "repeated params are ORed")
+
+	_, body = get(t, srv, http.MethodGet, "/posts?userId=1&id=3")
+	assert.Len(body, 1)
+
+	_, body = get(t, srv, http.MethodGet, "/posts/1/comments")
+	assert.Len(body, 5)
+
+	_, body = get(t, srv, http.MethodGet, "/users/2/posts?_start=8&_limit=5")
+	assert.Len(body, 2)
+
+	w, _ = get(t, srv, http.MethodGet, "/albums")
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	w, _ = get(t, srv, http.MethodDelete, "/posts/1")
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(int64(8), srv.Requests(), "unknown routes and methods are not counted")
+}
+
+func Test_Server_InjectsErrors(t *testing.T) {
+	assert := assert.New(t)
+	srv, err := fakeupstream.New(fakeupstream.Options{Seed: 1})
+	assert.Nil(err)
+
+	assert.Nil(srv.SetFaults("posts", fakeupstream.Faults{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}))
+
+	w, body := get(t, srv, http.MethodGet, "/posts")
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	assert.Equal("injected fault", body.(map[string]any)["error"])
+
+	w, _ = get(t, srv, http.MethodGet, "/users/1")
+	assert.Equal(http.StatusOK, w.Code, "faults are per resource")
+
+	assert.Nil(srv.SetFaults(fakeupstream.AllResources, fakeupstream.Faults{ErrorRate: 0.5}))
+	failed := 0
+	for range 200 {
+		if w, _ := get(t, srv, http.MethodGet, "/users/1"); w.Code == http.StatusInternalServerError {
+			failed++
+		}
+	}
+	assert.InDelta(100, failed, 30)
+}
+
+func Test_Server_InjectsLatency(t *testing.T) {
+	assert := assert.New(t)
+	srv, err := fakeupstream.New(fakeupstream.Options{Faults: fakeupstream.Faults{Latency: 50 * time.Millisecond}})
+	assert.Nil(err)
+
+	start := time.Now()
+	w, _ := get(t, srv, http.MethodGet, "/users/1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Empty(rec.Body.String(), "cancelled requests get no response")
+}
+
+func Test_Server_FaultsEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	srv, err := fakeupstream.New(fakeupstream.Options{})
+	assert.Nil(err)
+
+	w, body := get(t, srv, http.MethodPut, "/_faults?resource=users&latency=20ms&errorRate=0.25&errorStatus=502")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(map[string]any{"latency": "20ms", "jitter": "0s", "errorRate": 0.25, "errorStatus": float64(502)},
+		body.(map[string]any)["users"])
+	assert.Equal(fakeupstream.Faults{Latency: 20 * time.Millisecond, ErrorRate: 0.25, ErrorStatus: 502}, srv.Faults("users"))
+	assert.Equal(fakeupstream.Faults{}, srv.Faults("posts"))
+
+	w, _ = get(t, srv, http.MethodPut, "/_faults?errorRate=2")
+	assert.Equal(http.StatusBadRequest, w.Code)
+	w, _ = get(t, srv, http.MethodPut, "/_faults?resource=albums")
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func Test_Server_FixturesDir(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"users.json":    `[{"id":7,"name":"Ada","email":"ada@example.com"}]`,
+		"posts.json":    `[{"id":1,"userId":7}]`,
+		"comments.json": `[]`,
+	} {
+		assert.Nil(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	srv, err := fakeupstream.New(fakeupstream.Options{Dir: dir})
+	assert.Nil(err)
+	_, body := get(t, srv, http.MethodGet, "/users/7/posts")
+	assert.Len(body, 1)
+
+	_, err = fakeupstream.New(fakeupstream.Options{Dir: t.TempDir()})
+	assert.ErrorContains(err, "opening fixture")
+}
+
+func Test_Server_CompatibleWithFetchers(t *testing.T) {
+	assert := assert.New(t)
+	srv, err := fakeupstream.New(fakeupstream.Options{})
+	assert.Nil(err)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	users := &fetcher.HTTPUserFetcher{Client: ts.Client(), BaseURL: ts.URL + "/users"}
+	posts := &fetcher.HTTPPostsFetcher{Client: ts.Client(), BaseURL: ts.URL + "/posts"}
+
+	user, err := users.Fetch(context.Background(), 3)
+	assert.Nil(err)
+	assert.Equal("Clementine Bauch", user.Name)
+
+	userPosts, err := posts.Fetch(context.Background(), 3)
+	assert.Nil(err)
+	assert.Len(userPosts, 10)
+
+	assert.Nil(srv.SetFaults("users", fakeupstream.Faults{ErrorRate: 1, ErrorStatus: http.StatusBadGateway}))
+	_, err = users.Fetch(context.Background(), 3)
+	assert.ErrorContains(err, "status code 502")
+	assert.Equal(int64(2+3), srv.Requests(), "the fetcher retries 3 times")
+}
@@ -0,0 +1,181 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a circuit breaker rejects requests.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker stops calling an upstream after Threshold consecutive failures
+// (transport errors or 5xx). Errors caused by the caller, such as a cancelled
+// or expired request context or an aborted limit wait, are not failures. After
+// Cooldown a single trial request is let through: success closes the circuit,
+// failure opens it again.
+type CircuitBreaker struct {
+	Name   string
+	Client HTTPClient
+	// Target is the base URL of the upstream, telling breakers of the same
+	// name apart when a rebuilt client points elsewhere.
+	Target string
+
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	forced   bool
+	trial    bool
+}
+
+// BreakerStatus is a snapshot of a circuit breaker.
+type BreakerStatus struct {
+	Name     string       `json:"name"`
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"openedAt,omitempty"`
+	Forced   bool         `json:"forced"`
+}
+
+// NewCircuitBreaker wraps client. A threshold of zero never opens automatically.
+func NewCircuitBreaker(name string, client HTTPClient, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Name: name, Client: client, threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Do performs the request unless the circuit is open.
+func (cb *CircuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	if err := cb.allow(); err != nil {
+		return nil, err
+	}
+
+	res, err := cb.Client.Do(req)
+	if err != nil && (req.Context().Err() != nil || errors.Is(err, ErrLimited)) {
+		cb.release()
+		return res, err
+	}
+	cb.record(err != nil || (res != nil && res.StatusCode >= http.StatusInternalServerError))
+	return res, err
+}
+
+// Open forces the circuit open until Close or Reset is called.
+func (cb *CircuitBreaker) Open() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.transition(BreakerOpen)
+	cb.forced = true
+}
+
+// Close forces the circuit closed, clearing the failure count.
+func (cb *CircuitBreaker) Close() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.transition(BreakerClosed)
+	cb.forced = false
+}
+
+// Restore resumes from the status of a breaker this one replaces, so a
+// rebuilt client keeps an open or forced circuit.
+func (cb *CircuitBreaker) Restore(s BreakerStatus) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state, cb.failures, cb.forced, cb.trial = s.State, s.Failures, s.Forced, false
+	if s.OpenedAt != nil {
+		cb.openedAt = *s.OpenedAt
+	}
+}
+
+// Status returns the current state.
+func (cb *CircuitBreaker) Status() BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	s := BreakerStatus{Name: cb.Name, State: cb.state, Failures: cb.failures, Forced: cb.forced}
+	if cb.state != BreakerClosed {
+		openedAt := cb.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if cb.forced || time.Since(cb.openedAt) < cb.cooldown {
+			return fmt.Errorf("upstream %s: %w", cb.Name, ErrCircuitOpen)
+		}
+		cb.transition(BreakerHalfOpen)
+		cb.trial = true
+	case BreakerHalfOpen:
+		if cb.trial {
+			return fmt.Errorf("upstream %s: %w", cb.Name, ErrCircuitOpen)
+		}
+		cb.trial = true
+	}
+	return nil
+}
+
+// release lets another trial request through after one ended without telling
+// whether the upstream recovered.
+func (cb *CircuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == BreakerHalfOpen {
+		cb.trial = false
+	}
+}
+
+func (cb *CircuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.forced {
+		return
+	}
+	if !failed {
+		if cb.state != BreakerClosed {
+			cb.transition(BreakerClosed)
+		}
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == BreakerHalfOpen || (cb.threshold > 0 && cb.failures >= cb.threshold) {
+		cb.transition(BreakerOpen)
+	}
+}
+
+// transition must be called with mu held.
+func (cb *CircuitBreaker) transition(to BreakerState) {
+	from := cb.state
+	cb.state = to
+	cb.trial = false
+	switch to {
+	case BreakerOpen:
+		cb.openedAt = time.Now()
+	case BreakerClosed:
+		cb.failures = 0
+	}
+	if from != to {
+		logger.Log.Warn("circuit breaker state changed", "upstream", cb.Name, "from", from, "to", to, "failures", cb.failures)
+	}
+}
@@ -0,0 +1,114 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doGet(client fetcher.HTTPClient) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/users/1", nil)
+	return client.Do(req)
+}
+
+func Test_CircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	assert := assert.New(t)
+	upstream := mock.NewMockHTTPClient("", http.StatusBadGateway, nil)
+	cb := fetcher.NewCircuitBreaker("users", upstream, 2, time.Minute)
+
+	doGet(cb)
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State)
+	doGet(cb)
+	assert.Equal(fetcher.BreakerOpen, cb.Status().State)
+
+	res, err := doGet(cb)
+	assert.Nil(res)
+	assert.True(errors.Is(err, fetcher.ErrCircuitOpen))
+}
+
+func Test_CircuitBreaker_HalfOpenTrial(t *testing.T) {
+	assert := assert.New(t)
+	upstream := mock.NewMockHTTPClient("", http.StatusInternalServerError, nil)
+	cb := fetcher.NewCircuitBreaker("posts", upstream, 1, 20*time.Millisecond)
+
+	doGet(cb)
+	assert.Equal(fetcher.BreakerOpen, cb.Status().State)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err := doGet(cb)
+	assert.Nil(err, "trial request reaches the upstream")
+	assert.Equal(fetcher.BreakerOpen, cb.Status().State, "failed trial opens the circuit again")
+
+	cb.Client = mock.NewMockHTTPClient("[]", http.StatusOK, nil)
+	time.Sleep(30 * time.Millisecond)
+	_, err = doGet(cb)
+	assert.Nil(err)
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State)
+}
+
+func Test_CircuitBreaker_ManualOpenClose(t *testing.T) {
+	assert := assert.New(t)
+	cb := fetcher.NewCircuitBreaker("users", mock.NewMockHTTPClient("{}", http.StatusOK, nil), 0, time.Millisecond)
+
+	cb.Open()
+	time.Sleep(5 * time.Millisecond)
+	_, err := doGet(cb)
+	assert.True(errors.Is(err, fetcher.ErrCircuitOpen), "forced open ignores the cooldown")
+	assert.True(cb.Status().Forced)
+
+	cb.Close()
+	_, err = doGet(cb)
+	assert.Nil(err)
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State)
+}
+
+func Test_CircuitBreaker_IgnoresCallerErrors(t *testing.T) {
+	assert := assert.New(t)
+	upstream := mock.NewMockHTTPClient("", 0, context.Canceled)
+	cb := fetcher.NewCircuitBreaker("users", upstream, 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/users/1", nil)
+	_, err := cb.Do(req)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State, "a cancelled request says nothing of the upstream")
+	assert.Equal(0, cb.Status().Failures)
+}
+
+func Test_CircuitBreaker_IgnoresLimitWaits(t *testing.T) {
+	assert := assert.New(t)
+	limited := fetcher.NewLimitedClient("users", mock.NewMockHTTPClient("{}", http.StatusOK, nil), 0.5, 0)
+	cb := fetcher.NewCircuitBreaker("users", limited, 1, time.Minute)
+
+	res, err := doGet(cb)
+	assert.Nil(err)
+	res.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/users/1", nil)
+	_, err = cb.Do(req)
+	assert.True(errors.Is(err, fetcher.ErrLimited))
+	assert.Equal(fetcher.BreakerClosed, cb.Status().State)
+}
+
+func Test_CircuitBreaker_Restore(t *testing.T) {
+	assert := assert.New(t)
+	client := mock.NewMockHTTPClient("{}", http.StatusOK, nil)
+	prev := fetcher.NewCircuitBreaker("users", client, 0, time.Minute)
+	prev.Open()
+
+	cb := fetcher.NewCircuitBreaker("users", client, 0, time.Minute)
+	cb.Restore(prev.Status())
+
+	_, err := doGet(cb)
+	assert.True(errors.Is(err, fetcher.ErrCircuitOpen))
+	assert.Equal(prev.Status(), cb.Status())
+}
@@ -0,0 +1,299 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnmatchedRequest is returned by a ReplayingClient for requests absent from its cassette.
+var ErrUnmatchedRequest = errors.New("no recorded interaction matches the request")
+
+// DefaultScrubHeaders are never written to cassettes.
+var DefaultScrubHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Proxy-Authorization"}
+
+// scrubbed replaces the values of scrubbed headers in cassettes.
+const scrubbed = "[SCRUBBED]"
+
+// Cassette is a recorded sequence of upstream interactions, stored as JSON.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response (or transport error) it got.
+type Interaction struct {
+	Request    RecordedRequest   `json:"request"`
+	Response   *RecordedResponse `json:"response,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	RecordedAt time.Time         `json:"recordedAt"`
+}
+
+// RecordedRequest is the recorded part of an upstream request.
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the recorded part of an upstream response.
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// matchHeaders are the request headers an interaction must share with a
+// replayed request, so revalidations get the recorded 304 and first fetches
+// the recorded 200.
+var matchHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// matches reports whether req is the recorded request: same method, URL, body
+// and matchHeaders.
+func (r RecordedRequest) matches(method, url, body string, header http.Header) bool {
+	if r.Method != method || r.URL != url || r.Body != body {
+		return false
+	}
+	for _, h := range matchHeaders {
+		if r.Headers.Get(h) != header.Get(h) {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("decoding cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path atomically.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cassette dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// CassettePath is the cassette file of the named upstream in dir.
+func CassettePath(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// RecordingClient performs requests with Client and appends every interaction
+// to the cassette at Path, with the Scrub headers masked.
+type RecordingClient struct {
+	Client HTTPClient
+	Path   string
+	Scrub  []string
+
+	mu       sync.Mutex
+	cassette Cassette
+
+	// saveMu serializes the writes of the cassette, made outside mu; saved is
+	// the number of interactions written so far.
+	saveMu sync.Mutex
+	saved  int
+}
+
+// NewRecordingClient records the requests made through client to path,
+// appending to the cassette already there, if any. DefaultScrubHeaders are
+// always scrubbed, plus scrub.
+func NewRecordingClient(client HTTPClient, path string, scrub ...string) (*RecordingClient, error) {
+	rc := &RecordingClient{Client: client, Path: path, Scrub: append(slices.Clone(DefaultScrubHeaders), scrub...)}
+	existing, err := LoadCassette(path)
+	switch {
+	case err == nil:
+		rc.cassette = *existing
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return rc, nil
+}
+
+// Do performs the request and records it. A failure to write the cassette is
+// logged but does not fail the request.
+func (rc *RecordingClient) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: rc.scrub(req.Header),
+			Body:    reqBody,
+		},
+		RecordedAt: time.Now().UTC(),
+	}
+
+	res, err := rc.Client.Do(req)
+	if err != nil {
+		interaction.Error = err.Error()
+	} else {
+		resBody, readErr := readBody(&res.Body)
+		if readErr != nil {
+			return nil, fmt.Errorf("reading response body: %w", readErr)
+		}
+		interaction.Response = &RecordedResponse{Status: res.StatusCode, Headers: rc.scrub(res.Header), Body: resBody}
+	}
+
+	rc.mu.Lock()
+	rc.cassette.Interactions = append(rc.cassette.Interactions, interaction)
+	rc.mu.Unlock()
+	if saveErr := rc.save(); saveErr != nil {
+		logger.Log.Error("recording upstream interaction failed", "path", rc.Path, "error", saveErr)
+	}
+
+	return res, err
+}
+
+// save writes the interactions recorded so far, unless a concurrent save
+// already did, without blocking the requests being recorded meanwhile.
+func (rc *RecordingClient) save() error {
+	rc.saveMu.Lock()
+	defer rc.saveMu.Unlock()
+
+	rc.mu.Lock()
+	if len(rc.cassette.Interactions) == rc.saved {
+		rc.mu.Unlock()
+		return nil
+	}
+	c := Cassette{Interactions: slices.Clone(rc.cassette.Interactions)}
+	rc.mu.Unlock()
+
+	if err := c.Save(rc.Path); err != nil {
+		return err
+	}
+	rc.saved = len(c.Interactions)
+	return nil
+}
+
+func (rc *RecordingClient) scrub(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := h.Clone()
+	for _, name := range rc.Scrub {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, scrubbed)
+		}
+	}
+	return out
+}
+
+// ReplayingClient answers requests from a cassette without any network access.
+// Interactions matching a request are replayed in recorded order, the last one
+// repeating once they run out; requests matching none fail with ErrUnmatchedRequest.
+type ReplayingClient struct {
+	Path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayingClient loads the cassette at path.
+func NewReplayingClient(path string) (*ReplayingClient, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayingClient{Path: path, cassette: c, used: make([]bool, len(c.Interactions))}, nil
+}
+
+// Do returns the recorded response, or error, of the next interaction matching req.
+func (rp *ReplayingClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	url := req.URL.String()
+
+	rp.mu.Lock()
+	last := -1
+	next := -1
+	for i, in := range rp.cassette.Interactions {
+		if !in.Request.matches(req.Method, url, body, req.Header) {
+			continue
+		}
+		last = i
+		if !rp.used[i] {
+			next = i
+			break
+		}
+	}
+	if next == -1 {
+		next = last
+	}
+	if next >= 0 {
+		rp.used[next] = true
+	}
+	rp.mu.Unlock()
+
+	if next == -1 {
+		return nil, fmt.Errorf("%w: %s %s (cassette %s)", ErrUnmatchedRequest, req.Method, url, rp.Path)
+	}
+
+	in := rp.cassette.Interactions[next]
+	if in.Response == nil {
+		return nil, fmt.Errorf("replayed error: %s", in.Error)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+		StatusCode:    in.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readBody reads *body and replaces it with an unread copy.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
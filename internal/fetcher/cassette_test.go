@@ -0,0 +1,144 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/fakeupstream"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cassette_RecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	path := filepath.Join(t.TempDir(), "cassettes", "users.json")
+
+	recorder, err := fetcher.NewRecordingClient(http.DefaultClient, path, "X-Tenant")
+	assert.Nil(err)
+	req, _ := http.NewRequest(http.MethodGet, upstream.UsersURL()+"/1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("X-Tenant", "acme")
+	res, err := recorder.Do(req)
+	assert.Nil(err)
+	recorded, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Contains(string(recorded), "Leanne Graham", "the caller still gets the body")
+
+	users := &fetcher.HTTPUserFetcher{Client: recorder, BaseURL: upstream.UsersURL()}
+	_, err = users.Fetch(context.Background(), 2)
+	assert.Nil(err)
+
+	raw, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.NotContains(string(raw), "s3cret")
+	assert.NotContains(string(raw), "acme")
+	assert.Contains(string(raw), "[SCRUBBED]")
+
+	replayer, err := fetcher.NewReplayingClient(path)
+	assert.Nil(err)
+	users = &fetcher.HTTPUserFetcher{Client: replayer, BaseURL: upstream.UsersURL()}
+	upstreamRequests := upstream.Requests()
+
+	user, err := users.Fetch(context.Background(), 1)
+	assert.Nil(err)
+	assert.Equal("Leanne Graham", user.Name)
+	user, err = users.Fetch(context.Background(), 2)
+	assert.Nil(err)
+	assert.Equal("Ervin Howell", user.Name)
+	assert.Equal(upstreamRequests, upstream.Requests(), "replay never hits the network")
+
+	req, _ = http.NewRequest(http.MethodGet, upstream.UsersURL()+"/3", nil)
+	_, err = replayer.Do(req)
+	assert.True(errors.Is(err, fetcher.ErrUnmatchedRequest))
+}
+
+func Test_Cassette_ReplaysInRecordedOrder(t *testing.T) {
+	assert := assert.New(t)
+	upstream := test.StartFakeUpstream(t)
+	path := filepath.Join(t.TempDir(), "posts.json")
+
+	recorder, err := fetcher.NewRecordingClient(http.DefaultClient, path)
+	assert.Nil(err)
+	do := func(client fetcher.HTTPClient) int {
+		req, _ := http.NewRequest(http.MethodGet, upstream.PostsURL()+"?userId=1", nil)
+		res, err := client.Do(req)
+		assert.Nil(err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Nil(upstream.SetFaults("posts", fakeupstream.Faults{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}))
+	assert.Equal(http.StatusServiceUnavailable, do(recorder))
+	assert.Nil(upstream.SetFaults("posts", fakeupstream.Faults{}))
+	assert.Equal(http.StatusOK, do(recorder))
+
+	// a new recorder appends to the existing cassette
+	recorder, err = fetcher.NewRecordingClient(http.DefaultClient, path)
+	assert.Nil(err)
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:1/posts", nil)
+	_, err = recorder.Do(req)
+	assert.NotNil(err)
+
+	replayer, err := fetcher.NewReplayingClient(path)
+	assert.Nil(err)
+	assert.Equal(http.StatusServiceUnavailable, do(replayer))
+	assert.Equal(http.StatusOK, do(replayer))
+	assert.Equal(http.StatusOK, do(replayer), "the last match repeats")
+
+	_, err = replayer.Do(req)
+	assert.ErrorContains(err, "replayed error")
+}
+
+func Test_Cassette_MissingFile(t *testing.T) {
+	_, err := fetcher.NewReplayingClient(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "reading cassette")
+}
+
+func Test_Cassette_MatchesConditionalHeaders(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":1}`)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	do := func(client fetcher.HTTPClient, etag string) int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/users/1", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := client.Do(req)
+		assert.Nil(err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	recorder, err := fetcher.NewRecordingClient(http.DefaultClient, path)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, do(recorder, ""))
+	assert.Equal(http.StatusNotModified, do(recorder, `"v1"`))
+
+	replayer, err := fetcher.NewReplayingClient(path)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, do(replayer, ""))
+	assert.Equal(http.StatusOK, do(replayer, ""), "a first fetch never gets the recorded 304")
+	assert.Equal(http.StatusNotModified, do(replayer, `"v1"`))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users/1", nil)
+	req.Header.Set("If-None-Match", `"v2"`)
+	_, err = replayer.Do(req)
+	assert.True(errors.Is(err, fetcher.ErrUnmatchedRequest))
+}
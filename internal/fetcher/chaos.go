@@ -0,0 +1,235 @@
+package fetcher
+
+import (
+	"bytes"
+	"fmt"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ChaosRules are the faults a ChaosClient injects, each into the given percent
+// (0 to 100) of requests. Delay is independent of the others; of reset, fail,
+// malformed and truncate at most one applies, checked in that order.
+type ChaosRules struct {
+	DelayPercent     float64       `json:"delayPercent"`
+	Delay            time.Duration `json:"delay"`
+	ResetPercent     float64       `json:"resetPercent"`
+	FailPercent      float64       `json:"failPercent"`
+	FailStatus       int           `json:"failStatus"`
+	MalformedPercent float64       `json:"malformedPercent"`
+	TruncatePercent  float64       `json:"truncatePercent"`
+}
+
+// Enabled reports whether the rules inject anything.
+func (r ChaosRules) Enabled() bool {
+	return r.DelayPercent > 0 || r.ResetPercent > 0 || r.FailPercent > 0 || r.MalformedPercent > 0 || r.TruncatePercent > 0
+}
+
+// Validate checks the percents and values of the rules.
+func (r ChaosRules) Validate() error {
+	for name, pct := range map[string]float64{
+		"delayPercent":     r.DelayPercent,
+		"resetPercent":     r.ResetPercent,
+		"failPercent":      r.FailPercent,
+		"malformedPercent": r.MalformedPercent,
+		"truncatePercent":  r.TruncatePercent,
+	} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("%s: must be between 0 and 100, got %v", name, pct)
+		}
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("delay: must not be negative, got %s", r.Delay)
+	}
+	if r.FailStatus != 0 && (r.FailStatus < 400 || r.FailStatus > 599) {
+		return fmt.Errorf("failStatus: must be 4xx or 5xx, got %d", r.FailStatus)
+	}
+	return nil
+}
+
+// ParseChaosRules reads rules from query values named after the JSON fields,
+// starting from base for the ones absent.
+func ParseChaosRules(base ChaosRules, values url.Values) (ChaosRules, error) {
+	r := base
+	for name, pct := range map[string]*float64{
+		"delayPercent":     &r.DelayPercent,
+		"resetPercent":     &r.ResetPercent,
+		"failPercent":      &r.FailPercent,
+		"malformedPercent": &r.MalformedPercent,
+		"truncatePercent":  &r.TruncatePercent,
+	} {
+		if v := values.Get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return base, fmt.Errorf("%s: %w", name, err)
+			}
+			*pct = f
+		}
+	}
+	if v := values.Get("delay"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return base, fmt.Errorf("delay: %w", err)
+		}
+		r.Delay = d
+	}
+	if v := values.Get("failStatus"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return base, fmt.Errorf("failStatus: %w", err)
+		}
+		r.FailStatus = status
+	}
+	return r, r.Validate()
+}
+
+// ChaosClient injects faults into the requests to an upstream, following rules
+// that can be changed at runtime. With no rules set it only passes requests through.
+type ChaosClient struct {
+	Name   string
+	Client HTTPClient
+
+	mu    sync.RWMutex
+	rules ChaosRules
+
+	delayed   atomic.Int64
+	reset     atomic.Int64
+	failed    atomic.Int64
+	malformed atomic.Int64
+	truncated atomic.Int64
+}
+
+// ChaosStatus is a snapshot of a ChaosClient's rules and injected faults.
+type ChaosStatus struct {
+	Name      string     `json:"name"`
+	Rules     ChaosRules `json:"rules"`
+	Delayed   int64      `json:"delayed"`
+	Reset     int64      `json:"reset"`
+	Failed    int64      `json:"failed"`
+	Malformed int64      `json:"malformed"`
+	Truncated int64      `json:"truncated"`
+}
+
+// NewChaosClient wraps client with no faults enabled.
+func NewChaosClient(name string, client HTTPClient) *ChaosClient {
+	return &ChaosClient{Name: name, Client: client}
+}
+
+// SetRules replaces the rules; the zero ChaosRules turns chaos off.
+func (cc *ChaosClient) SetRules(r ChaosRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	cc.mu.Lock()
+	cc.rules = r
+	cc.mu.Unlock()
+	return nil
+}
+
+// Rules returns the current rules.
+func (cc *ChaosClient) Rules() ChaosRules {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.rules
+}
+
+// Status returns the rules and the faults injected so far.
+func (cc *ChaosClient) Status() ChaosStatus {
+	return ChaosStatus{
+		Name:      cc.Name,
+		Rules:     cc.Rules(),
+		Delayed:   cc.delayed.Load(),
+		Reset:     cc.reset.Load(),
+		Failed:    cc.failed.Load(),
+		Malformed: cc.malformed.Load(),
+		Truncated: cc.truncated.Load(),
+	}
+}
+
+// Do performs the request, injecting the faults drawn for it.
+func (cc *ChaosClient) Do(req *http.Request) (*http.Response, error) {
+	r := cc.Rules()
+	if !r.Enabled() {
+		return cc.Client.Do(req)
+	}
+
+	if roll(r.DelayPercent) && r.Delay > 0 {
+		cc.delayed.Add(1)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(r.Delay):
+		}
+	}
+
+	switch {
+	case roll(r.ResetPercent):
+		cc.reset.Add(1)
+		logger.Log.Debug("chaos: connection reset", "upstream", cc.Name)
+		return nil, fmt.Errorf("upstream %s chaos: %w", cc.Name, syscall.ECONNRESET)
+	case roll(r.FailPercent):
+		cc.failed.Add(1)
+		status := r.FailStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		logger.Log.Debug("chaos: failed request", "upstream", cc.Name, "status", status)
+		return fakeResponse(req, status, `{"error":"chaos"}`), nil
+	case roll(r.MalformedPercent):
+		res, err := cc.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		cc.malformed.Add(1)
+		logger.Log.Debug("chaos: malformed body", "upstream", cc.Name)
+		malformed := fakeResponse(req, res.StatusCode, `{"id": 1, "name": "chaos`+"\x00"+`", ]`)
+		malformed.Header = res.Header
+		malformed.Header.Del("Content-Length")
+		return malformed, nil
+	case roll(r.TruncatePercent):
+		res, err := cc.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		cc.truncated.Add(1)
+		logger.Log.Debug("chaos: truncated body", "upstream", cc.Name, "bytes", len(body)/2)
+		res.Body = io.NopCloser(bytes.NewReader(body[:len(body)/2]))
+		res.ContentLength = int64(len(body) / 2)
+		res.Header.Del("Content-Length")
+		return res, nil
+	}
+	return cc.Client.Do(req)
+}
+
+// roll draws whether a fault with the given percent happens.
+func roll(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+func fakeResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,94 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newChaosFetchers(t *testing.T) (*fetcher.ChaosClient, *fetcher.HTTPUserFetcher, *test.FakeUpstream) {
+	upstream := test.StartFakeUpstream(t)
+	cc := fetcher.NewChaosClient("users", http.DefaultClient)
+	return cc, &fetcher.HTTPUserFetcher{Client: cc, BaseURL: upstream.UsersURL()}, upstream
+}
+
+func Test_ChaosClient_PassesThroughWithoutRules(t *testing.T) {
+	assert := assert.New(t)
+	_, users, _ := newChaosFetchers(t)
+
+	user, err := users.Fetch(context.Background(), 1)
+	assert.Nil(err)
+	assert.Equal("Leanne Graham", user.Name)
+}
+
+func Test_ChaosClient_FailAndResetAreRetried(t *testing.T) {
+	assert := assert.New(t)
+	cc, users, upstream := newChaosFetchers(t)
+
+	assert.Nil(cc.SetRules(fetcher.ChaosRules{FailPercent: 100, FailStatus: http.StatusServiceUnavailable}))
+	_, err := users.Fetch(context.Background(), 1)
+	assert.ErrorContains(err, "status code 503")
+	assert.Equal(int64(3), cc.Status().Failed, "every retry fails")
+	assert.Equal(int64(0), upstream.Requests(), "failed requests never reach the upstream")
+
+	assert.Nil(cc.SetRules(fetcher.ChaosRules{ResetPercent: 100}))
+	_, err = users.Fetch(context.Background(), 1)
+	assert.True(errors.Is(err, syscall.ECONNRESET))
+	assert.Equal(int64(3), cc.Status().Reset)
+}
+
+func Test_ChaosClient_CorruptsBodies(t *testing.T) {
+	assert := assert.New(t)
+	cc, users, _ := newChaosFetchers(t)
+
+	assert.Nil(cc.SetRules(fetcher.ChaosRules{MalformedPercent: 100}))
+	_, err := users.Fetch(context.Background(), 1)
+	assert.ErrorContains(err, "decoding user response")
+
+	assert.Nil(cc.SetRules(fetcher.ChaosRules{TruncatePercent: 100}))
+	_, err = users.Fetch(context.Background(), 1)
+	assert.ErrorContains(err, "decoding user response")
+
+	status := cc.Status()
+	assert.Equal(int64(1), status.Malformed, "decoding errors are not retried")
+	assert.Equal(int64(1), status.Truncated)
+}
+
+func Test_ChaosClient_DelayHonoursDeadline(t *testing.T) {
+	assert := assert.New(t)
+	cc, users, _ := newChaosFetchers(t)
+	assert.Nil(cc.SetRules(fetcher.ChaosRules{DelayPercent: 100, Delay: time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := users.Fetch(ctx, 1)
+
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Less(time.Since(start), 500*time.Millisecond)
+	assert.Equal(int64(1), cc.Status().Delayed)
+}
+
+func Test_ParseChaosRules(t *testing.T) {
+	assert := assert.New(t)
+	base := fetcher.ChaosRules{FailPercent: 10}
+
+	rules, err := fetcher.ParseChaosRules(base, url.Values{"delayPercent": {"50"}, "delay": {"200ms"}, "failStatus": {"502"}})
+	assert.Nil(err)
+	assert.Equal(fetcher.ChaosRules{DelayPercent: 50, Delay: 200 * time.Millisecond, FailPercent: 10, FailStatus: 502}, rules)
+
+	_, err = fetcher.ParseChaosRules(base, url.Values{"resetPercent": {"150"}})
+	assert.ErrorContains(err, "resetPercent")
+	_, err = fetcher.ParseChaosRules(base, url.Values{"failStatus": {"200"}})
+	assert.ErrorContains(err, "failStatus")
+	_, err = fetcher.ParseChaosRules(base, url.Values{"delay": {"soon"}})
+	assert.ErrorContains(err, "delay")
+}
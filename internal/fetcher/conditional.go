@@ -0,0 +1,276 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConditionalClient caches the successful GET responses of an upstream by URL.
+// A response is served from the cache while fresh per its Cache-Control
+// max-age (or Expires); afterwards, one with an ETag or Last-Modified is
+// revalidated with If-None-Match / If-Modified-Since, and a 304 Not Modified
+// serves the cached body again. no-store responses are never cached and
+// no-cache ones are always revalidated.
+type ConditionalClient struct {
+	Name   string
+	Client HTTPClient
+
+	entries     *cache.LRU[*cachedResponse]
+	now         func() time.Time
+	fresh       atomic.Int64
+	revalidated atomic.Int64
+	misses      atomic.Int64
+}
+
+var _ cache.Purgeable = &ConditionalClient{}
+
+// NewConditionalClient wraps client with a cache of up to size responses.
+func NewConditionalClient(name string, client HTTPClient, size int) *ConditionalClient {
+	return &ConditionalClient{
+		Name:    name,
+		Client:  client,
+		entries: cache.NewLRU[*cachedResponse](name, size),
+		now:     time.Now,
+	}
+}
+
+// cachedResponse is a cached 200 response. Its body is immutable; each 304
+// updates its headers and freshness.
+type cachedResponse struct {
+	body  []byte
+	evict func()
+
+	mu      sync.Mutex
+	header  http.Header
+	expires time.Time
+	decoded any
+}
+
+// Do serves req from the cache when possible, revalidating stale responses.
+func (c *ConditionalClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" ||
+		req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		return c.Client.Do(req)
+	}
+
+	key := req.URL.String()
+	ctx := req.Context()
+	entry, ok := c.entries.Get(ctx, key)
+	if ok && entry.freshAt(c.now()) {
+		c.fresh.Add(1)
+		return entry.response(req), nil
+	}
+
+	sent := req
+	if ok {
+		if etag, lastModified := entry.validators(); etag != "" || lastModified != "" {
+			// the fetchers reuse req across retries, so it must not be modified
+			sent = req.Clone(ctx)
+			if etag != "" {
+				sent.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				sent.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	res, err := c.Client.Do(sent)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotModified && sent != req {
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		entry.refresh(res.Header, c.now())
+		c.revalidated.Add(1)
+		logger.Log.Debug("upstream response not modified", "upstream", c.Name, "url", key)
+		return entry.response(req), nil
+	}
+
+	c.misses.Add(1)
+	if res.StatusCode != http.StatusOK {
+		return res, nil
+	}
+	expires, cacheable := freshness(res.Header, c.now())
+	if !cacheable {
+		c.entries.Remove(key)
+		return res, nil
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s response: %w", c.Name, err)
+	}
+	if !json.Valid(body) {
+		// a truncated or non-JSON body, such as an error page, would be
+		// served for the whole max-age
+		c.entries.Remove(key)
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return res, nil
+	}
+	entry = &cachedResponse{header: res.Header.Clone(), body: body, expires: expires}
+	entry.evict = func() {
+		if current, ok := c.entries.Peek(key); ok && current == entry {
+			c.entries.Remove(key)
+		}
+	}
+	c.entries.Add(ctx, key, entry)
+	return entry.response(req), nil
+}
+
+// Stats counts as hits the responses served fresh or revalidated, and as
+// misses those downloaded.
+func (c *ConditionalClient) Stats() cache.Stats {
+	s := c.entries.Stats()
+	s.Hits = c.fresh.Load() + c.revalidated.Load()
+	s.Misses = c.misses.Load()
+	return s
+}
+
+// Purge drops every cached response.
+func (c *ConditionalClient) Purge() {
+	c.entries.Purge()
+}
+
+func (e *cachedResponse) freshAt(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return now.Before(e.expires)
+}
+
+// validators returns the ETag and Last-Modified of the response.
+func (e *cachedResponse) validators() (etag, lastModified string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.header.Get("ETag"), e.header.Get("Last-Modified")
+}
+
+// refresh merges the headers of a 304 into the stored ones, as RFC 9111
+// requires, and recomputes the freshness from the result, so a 304 without
+// Cache-Control keeps the max-age of the stored response.
+func (e *cachedResponse) refresh(header http.Header, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	merged := e.header.Clone()
+	// the stored Age was the age at download
+	merged.Del("Age")
+	for name, values := range header {
+		if name != "Content-Length" {
+			merged[name] = values
+		}
+	}
+	e.header = merged
+	e.expires, _ = freshness(merged, now)
+}
+
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	e.mu.Lock()
+	header := e.header.Clone()
+	e.mu.Unlock()
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          &cachedBody{Reader: bytes.NewReader(e.body), entry: e},
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// freshness returns until when a response with header is fresh, and whether
+// it may be cached at all: it must not be no-store, and must be either fresh
+// for a while or carry a validator.
+func freshness(header http.Header, now time.Time) (time.Time, bool) {
+	var (
+		maxAge    = -1
+		noCache   bool
+		validated = header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+	)
+	for directive := range strings.SplitSeq(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return time.Time{}, false
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && n >= 0 {
+				maxAge = n
+			}
+		}
+	}
+
+	var expires time.Time
+	switch {
+	case noCache:
+	case maxAge >= 0:
+		age, _ := strconv.Atoi(header.Get("Age"))
+		expires = now.Add(time.Duration(maxAge-age) * time.Second)
+	case header.Get("Expires") != "":
+		// an invalid Expires, such as "0", means already expired
+		if at, err := http.ParseTime(header.Get("Expires")); err == nil {
+			if date, err := http.ParseTime(header.Get("Date")); err == nil {
+				expires = now.Add(at.Sub(date))
+			} else {
+				expires = at
+			}
+		}
+	}
+	return expires, validated || expires.After(now)
+}
+
+// cachedBody is the body of a cached response. It remembers the value the
+// fetchers decode from it so unchanged data is decoded once.
+type cachedBody struct {
+	*bytes.Reader
+	entry *cachedResponse
+}
+
+func (b *cachedBody) Close() error { return nil }
+
+// decodeJSON decodes body into v. For a cached body, the value decoded the
+// first time is reused, through clone so callers never share it; a body that
+// doesn't decode into v is evicted, so the next request downloads it again.
+func decodeJSON[T any](body io.Reader, v *T, clone func(T) T) error {
+	cached, ok := body.(*cachedBody)
+	if !ok {
+		return json.NewDecoder(body).Decode(v)
+	}
+
+	e := cached.entry
+	e.mu.Lock()
+	decoded, ok := e.decoded.(T)
+	e.mu.Unlock()
+	if ok {
+		*v = clone(decoded)
+		return nil
+	}
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		if e.evict != nil {
+			e.evict()
+		}
+		return err
+	}
+	e.mu.Lock()
+	e.decoded = clone(*v)
+	e.mu.Unlock()
+	return nil
+}
+
+// identity clones values without references.
+func identity[T any](v T) T { return v }
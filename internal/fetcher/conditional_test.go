@@ -0,0 +1,190 @@
+package fetcher_test
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/fetcher"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// etagServer serves body with an ETag and cacheControl, answering 304 to
+// requests holding the ETag. It counts the full and not modified responses.
+func etagServer(t *testing.T, body, cacheControl string) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"v1"`)
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		if r.Header.Get("If-None-Match") == `W/"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &full, &notModified
+}
+
+func get(t *testing.T, client fetcher.HTTPClient, url string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func Test_ConditionalClient_RevalidatesWithETag(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, notModified := etagServer(t, `{"id":1}`, "no-cache")
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+
+	for range 3 {
+		status, body := get(t, client, srv.URL+"/users/1")
+		assert.Equal(http.StatusOK, status)
+		assert.Equal(`{"id":1}`, body)
+	}
+
+	assert.Equal(int32(1), full.Load())
+	assert.Equal(int32(2), notModified.Load())
+	stats := client.Stats()
+	assert.Equal(1, stats.Len)
+	assert.Equal(int64(2), stats.Hits)
+	assert.Equal(int64(1), stats.Misses)
+}
+
+func Test_ConditionalClient_ServesFreshResponses(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, notModified := etagServer(t, `{"id":1}`, "public, max-age=60")
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+
+	get(t, client, srv.URL+"/users/1")
+	_, body := get(t, client, srv.URL+"/users/1")
+
+	assert.Equal(`{"id":1}`, body)
+	assert.Equal(int32(1), full.Load())
+	assert.Equal(int32(0), notModified.Load(), "fresh responses don't reach the upstream")
+
+	get(t, client, srv.URL+"/users/2")
+	assert.Equal(int32(2), full.Load(), "responses are cached by URL")
+
+	client.Purge()
+	get(t, client, srv.URL+"/users/1")
+	assert.Equal(int32(3), full.Load())
+}
+
+func Test_ConditionalClient_NoStore(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, notModified := etagServer(t, `{"id":1}`, "no-store")
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+
+	get(t, client, srv.URL)
+	get(t, client, srv.URL)
+
+	assert.Equal(int32(2), full.Load())
+	assert.Equal(int32(0), notModified.Load())
+	assert.Equal(0, client.Stats().Len)
+}
+
+func Test_ConditionalClient_ErrorsAreNotCached(t *testing.T) {
+	assert := assert.New(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+
+	status, _ := get(t, client, srv.URL)
+	get(t, client, srv.URL)
+
+	assert.Equal(http.StatusInternalServerError, status)
+	assert.Equal(int32(2), calls.Load())
+}
+
+func Test_ConditionalClient_ReusesDecodedPosts(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, notModified := etagServer(t, `[{"userId":1,"id":1,"title":"a"},{"userId":1,"id":2,"title":"b"}]`, "max-age=0")
+	posts := &fetcher.HTTPPostsFetcher{
+		Client:  fetcher.NewConditionalClient("posts", srv.Client(), 10),
+		BaseURL: srv.URL + "/posts",
+	}
+
+	first, err := posts.Fetch(context.Background(), 1)
+	assert.Nil(err)
+	first[0].Title = "changed by the caller"
+
+	second, err := posts.Fetch(context.Background(), 1)
+	assert.Nil(err)
+	assert.Equal([]fetcher.Post{{UserID: 1, ID: 1, Title: "a"}, {UserID: 1, ID: 2, Title: "b"}}, second)
+	assert.Equal(int32(1), full.Load())
+	assert.Equal(int32(1), notModified.Load())
+}
+
+func Test_ConditionalClient_InvalidBodiesAreNotCached(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, _ := etagServer(t, `[{"userId":1,"id":1`, "max-age=60")
+	client := fetcher.NewConditionalClient("posts", srv.Client(), 10)
+
+	_, body := get(t, client, srv.URL)
+	get(t, client, srv.URL)
+
+	assert.Equal(`[{"userId":1,"id":1`, body, "the body is still passed on")
+	assert.Equal(int32(2), full.Load())
+	assert.Equal(0, client.Stats().Len)
+}
+
+func Test_ConditionalClient_UndecodableBodiesAreEvicted(t *testing.T) {
+	assert := assert.New(t)
+	srv, full, _ := etagServer(t, `"maintenance"`, "max-age=60")
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+	users := &fetcher.HTTPUserFetcher{Client: client, BaseURL: srv.URL + "/users"}
+
+	for range 2 {
+		_, err := users.Fetch(context.Background(), 1)
+		assert.ErrorContains(err, "decoding user response")
+	}
+	assert.Equal(int32(2), full.Load(), "the failed body is not served from the cache")
+	assert.Equal(0, client.Stats().Len)
+}
+
+func Test_ConditionalClient_NotModifiedKeepsStoredMaxAge(t *testing.T) {
+	assert := assert.New(t)
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		// already stale when received
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Age", "60")
+		_, _ = io.WriteString(w, `{"id":1}`)
+	}))
+	defer srv.Close()
+	client := fetcher.NewConditionalClient("users", srv.Client(), 10)
+
+	for range 3 {
+		_, body := get(t, client, srv.URL)
+		assert.Equal(`{"id":1}`, body)
+	}
+
+	assert.Equal(int32(1), full.Load())
+	assert.Equal(int32(1), notModified.Load(), "the 304 made the response fresh for the stored max-age")
+}
@@ -13,6 +13,11 @@ type UserFetcher interface {
 	Fetch(ctx context.Context, userID int) (*User, error)
 }
 
+// UserLister lists every user, for bulk operations such as exports.
+type UserLister interface {
+	List(ctx context.Context) ([]User, error)
+}
+
 type PostsFetcher interface {
 	Fetch(ctx context.Context, userID int) ([]Post, error)
 }
@@ -24,5 +29,16 @@ type User struct {
 }
 
 type Post struct {
-	UserID int `json:"userId"`
+	UserID int    `json:"userId"`
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type Comment struct {
+	PostID int    `json:"postId"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Body   string `json:"body"`
 }
\ No newline at end of file
@@ -2,13 +2,26 @@ package fetcher
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 )
 
+// ErrNotFound is returned, without retrying, when the upstream answers 404.
+var ErrNotFound = errors.New("not found")
+
+// StatusError is an unexpected status answered by the upstream.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.Code)
+}
+
 // ---------------- USERS -------------------
 
 type HTTPUserFetcher struct {
@@ -29,15 +42,20 @@ func (fetcher *HTTPUserFetcher) Fetch(ctx context.Context, userID int) (*User, e
 		if err != nil {
 			lastErr = fmt.Errorf("doing user request: %w", err)
 		} else {
-			defer res.Body.Close()
 			if res.StatusCode == http.StatusOK {
+				defer res.Body.Close()
 				var user User
-				if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+				if err := decodeJSON(res.Body, &user, identity); err != nil {
 					return nil, fmt.Errorf("decoding user response: %w", err)
 				}
 				return &user, nil
 			}
-				lastErr = fmt.Errorf("fetching user: status code %d", res.StatusCode)
+			// release the connection (and any upstream in-flight slot) before retrying
+			res.Body.Close()
+			if res.StatusCode == http.StatusNotFound {
+				return nil, fmt.Errorf("fetching user: status code %d: %w", res.StatusCode, ErrNotFound)
+			}
+			lastErr = fmt.Errorf("fetching user: %w", &StatusError{Code: res.StatusCode})
 		}
 		wait := time.Duration(1<<attempt) * 100 * time.Millisecond
 		select {
@@ -50,6 +68,27 @@ func (fetcher *HTTPUserFetcher) Fetch(ctx context.Context, userID int) (*User, e
 	return nil, lastErr
 }
 
+// List fetches every user from BaseURL.
+func (fetcher *HTTPUserFetcher) List(ctx context.Context) ([]User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fetcher.BaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating users request: %w", err)
+	}
+	res, err := fetcher.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("doing users request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing users: %w", &StatusError{Code: res.StatusCode})
+	}
+	var users []User
+	if err := decodeJSON(res.Body, &users, slices.Clone); err != nil {
+		return nil, fmt.Errorf("decoding users response: %w", err)
+	}
+	return users, nil
+}
+
 // ---------------- POSTS -------------------
 
 type HTTPPostsFetcher struct {
@@ -80,16 +119,16 @@ func (fetcher *HTTPPostsFetcher) Fetch(ctx context.Context, userID int) ([]Post,
 			if err != nil {
 				lastErr = fmt.Errorf("doing posts request: %w", err)
 			} else {
-				defer res.Body.Close()
-
 				if res.StatusCode == http.StatusOK {
+					defer res.Body.Close()
 					var posts []Post
-					if err := json.NewDecoder(res.Body).Decode(&posts); err != nil {
+					if err := decodeJSON(res.Body, &posts, slices.Clone); err != nil {
 						return nil, fmt.Errorf("decoding posts response: %w", err)
 					}
 					return posts, nil
 				}
-				lastErr = fmt.Errorf("fetching posts: status code %d", res.StatusCode)
+				res.Body.Close()
+				lastErr = fmt.Errorf("fetching posts: %w", &StatusError{Code: res.StatusCode})
 			}
 		}
 		wait := time.Duration(1<<attempt) * 100 * time.Millisecond
@@ -43,6 +43,21 @@ func Test_HTTPUserFetcher_StatusError(t *testing.T){
 	assert.Contains(err.Error(), "fetching user: status code 500")
 }
 
+func Test_HTTPUserFetcher_NotFound(t *testing.T){
+	assert := assert.New(t)
+	mockHTTPClient := mock.NewMockHTTPClient("{}", http.StatusNotFound, nil)
+	mockUserFetcher := &fetcher.HTTPUserFetcher{
+		Client:  mockHTTPClient,
+		BaseURL: "http://example.com/users",
+	}
+
+	user, err := mockUserFetcher.Fetch(context.Background(), 42)
+
+	assert.Nil(user)
+	assert.ErrorIs(err, fetcher.ErrNotFound)
+	assert.Contains(err.Error(), "fetching user: status code 404")
+}
+
 func Test_HTTPUserFetcher_RequestError(t *testing.T){
 	assert := assert.New(t)
 	mockHTTPClient := mock.NewMockHTTPClient("", http.StatusInternalServerError, errors.New("network error"))
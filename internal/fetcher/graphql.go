@@ -0,0 +1,236 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserIDVariable is the variable value replaced by the ID of the user fetched.
+const UserIDVariable = "$userId"
+
+// GraphQLQuery reads records from a GraphQL API: Document is posted with
+// Variables, where the string "$userId" stands for the user ID, and the
+// records are read at the dot-separated Path of the response data, such as
+// "user.posts.data". Fields are decoded by name, so the document can alias
+// them to the names of User and Post; IDs may be numbers or numeric strings.
+type GraphQLQuery struct {
+	Document  string
+	Variables map[string]any
+	Path      string
+}
+
+// ---------------- USERS -------------------
+
+type GraphQLUserFetcher struct {
+	Client   HTTPClient
+	Endpoint string
+	Query    GraphQLQuery
+}
+
+// Fetch fetches user data by userID.
+func (fetcher *GraphQLUserFetcher) Fetch(ctx context.Context, userID int) (*User, error) {
+	raw, err := fetcher.Query.do(ctx, fetcher.Client, fetcher.Endpoint, userID, "user")
+	if err != nil {
+		return nil, err
+	}
+	var user graphQLUser
+	if err := json.Unmarshal(raw, &user); err != nil {
+		return nil, fmt.Errorf("decoding user response: %w", err)
+	}
+	return &User{ID: int(user.ID), Name: user.Name, Email: user.Email}, nil
+}
+
+// ---------------- POSTS -------------------
+
+type GraphQLPostsFetcher struct {
+	Client   HTTPClient
+	Endpoint string
+	Query    GraphQLQuery
+}
+
+// Fetch fetches posts by userID. Posts without a userId field get userID.
+func (fetcher *GraphQLPostsFetcher) Fetch(ctx context.Context, userID int) ([]Post, error) {
+	raw, err := fetcher.Query.do(ctx, fetcher.Client, fetcher.Endpoint, userID, "posts")
+	if err != nil {
+		return nil, err
+	}
+	var decoded []graphQLPost
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("decoding posts response: %w", err)
+	}
+	posts := make([]Post, len(decoded))
+	for i, p := range decoded {
+		posts[i] = Post{UserID: int(p.UserID), ID: int(p.ID), Title: p.Title, Body: p.Body}
+		if posts[i].UserID == 0 {
+			posts[i].UserID = userID
+		}
+	}
+	return posts, nil
+}
+
+type graphQLUser struct {
+	ID    flexInt `json:"id"`
+	Name  string  `json:"name"`
+	Email string  `json:"email"`
+}
+
+type graphQLPost struct {
+	UserID flexInt `json:"userId"`
+	ID     flexInt `json:"id"`
+	Title  string  `json:"title"`
+	Body   string  `json:"body"`
+}
+
+// flexInt decodes JSON numbers and numeric strings, as GraphQL IDs are
+// serialized as strings.
+type flexInt int
+
+func (n *flexInt) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" || s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid ID %s", b)
+	}
+	*n = flexInt(v)
+	return nil
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// do posts the query for userID and returns the value at q.Path. Failed
+// requests are retried like the REST fetchers do; GraphQL errors are not.
+// A 404 or a null value is ErrNotFound.
+func (q GraphQLQuery) do(ctx context.Context, client HTTPClient, endpoint string, userID int, what string) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]any{"query": q.Document, "variables": bindUserID(q.Variables, userID)})
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s query: %w", what, err)
+	}
+
+	var lastErr error
+	for attempt := range 3 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("creating %s request: %w", what, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("doing %s request: %w", what, err)
+		} else {
+			if res.StatusCode == http.StatusOK {
+				defer res.Body.Close()
+				var resp graphQLResponse
+				if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+					return nil, fmt.Errorf("decoding %s response: %w", what, err)
+				}
+				return resp.at(q.Path, what)
+			}
+			// release the connection (and any upstream in-flight slot) before retrying
+			res.Body.Close()
+			if res.StatusCode == http.StatusNotFound {
+				return nil, fmt.Errorf("fetching %s: status code %d: %w", what, res.StatusCode, ErrNotFound)
+			}
+			lastErr = fmt.Errorf("fetching %s: %w", what, &StatusError{Code: res.StatusCode})
+		}
+		wait := time.Duration(1<<attempt) * 100 * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil, lastErr
+}
+
+// at returns the value at the dot-separated path of the response data.
+func (resp *graphQLResponse) at(path, what string) (json.RawMessage, error) {
+	value := resp.Data
+	for key := range strings.SplitSeq(path, ".") {
+		if isNull(value) {
+			break
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(value, &obj); err != nil {
+			return nil, fmt.Errorf("decoding %s response: %q is not an object", what, key)
+		}
+		value = obj[key]
+	}
+	if !isNull(value) {
+		return value, nil
+	}
+
+	if len(resp.Errors) == 0 {
+		return nil, fmt.Errorf("fetching %s: null at %s: %w", what, path, ErrNotFound)
+	}
+	messages := make([]string, len(resp.Errors))
+	notFound := false
+	for i, e := range resp.Errors {
+		messages[i] = e.Message
+		notFound = notFound || e.Extensions["code"] == "NOT_FOUND"
+	}
+	err := fmt.Errorf("fetching %s: graphql errors: %s", what, strings.Join(messages, "; "))
+	if notFound {
+		err = fmt.Errorf("%w: %w", err, ErrNotFound)
+	}
+	return nil, err
+}
+
+func isNull(v json.RawMessage) bool {
+	return len(v) == 0 || bytes.Equal(v, []byte("null"))
+}
+
+// bindUserID returns a copy of vars with every "$userId" replaced by userID.
+func bindUserID(vars map[string]any, userID int) map[string]any {
+	bound := make(map[string]any, len(vars))
+	for k, v := range vars {
+		bound[k] = bindValue(v, userID)
+	}
+	return bound
+}
+
+func bindValue(v any, userID int) any {
+	switch v := v.(type) {
+	case string:
+		if v == UserIDVariable {
+			return userID
+		}
+	case map[string]any:
+		return bindUserID(v, userID)
+	case []any:
+		bound := make([]any, len(v))
+		for i, item := range v {
+			bound[i] = bindValue(item, userID)
+		}
+		return bound
+	}
+	return v
+}
+
+// ParseGraphQLVariables parses a JSON object of query variables.
+func ParseGraphQLVariables(s string) (map[string]any, error) {
+	vars := map[string]any{}
+	if strings.TrimSpace(s) == "" {
+		return vars, nil
+	}
+	if err := json.Unmarshal([]byte(s), &vars); err != nil {
+		return nil, errors.New("variables must be a JSON object")
+	}
+	return vars, nil
+}
@@ -0,0 +1,171 @@
+package fetcher_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/fetcher"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// graphQLServer answers with the status codes of fail first, then with the
+// response of respond for the decoded request.
+func graphQLServer(t *testing.T, respond func(req graphQLRequest) string, fail ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(fail) {
+			w.WriteHeader(fail[n-1])
+			return
+		}
+		var req graphQLRequest
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(respond(req)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func Test_GraphQLUserFetcher_Success(t *testing.T) {
+	assert := assert.New(t)
+	var got graphQLRequest
+	srv, _ := graphQLServer(t, func(req graphQLRequest) string {
+		got = req
+		return `{"data":{"user":{"id":"7","name":"John Doe","email":"john@example.com"}}}`
+	})
+	users := &fetcher.GraphQLUserFetcher{
+		Client:   srv.Client(),
+		Endpoint: srv.URL,
+		Query: fetcher.GraphQLQuery{
+			Document:  "query($id: ID!, $opts: Opts) { user(id: $id) { id name email } }",
+			Variables: map[string]any{"id": "$userId", "opts": map[string]any{"owner": "$userId", "tag": "x"}},
+			Path:      "user",
+		},
+	}
+
+	user, err := users.Fetch(context.Background(), 7)
+
+	assert.Nil(err)
+	assert.Equal(&fetcher.User{ID: 7, Name: "John Doe", Email: "john@example.com"}, user)
+	assert.Equal(users.Query.Document, got.Query)
+	assert.Equal(map[string]any{"id": float64(7), "opts": map[string]any{"owner": float64(7), "tag": "x"}}, got.Variables)
+	assert.Equal("$userId", users.Query.Variables["id"], "the configured variables are not modified")
+}
+
+func Test_GraphQLUserFetcher_RetriesServerErrors(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := graphQLServer(t, func(graphQLRequest) string {
+		return `{"data":{"user":{"id":1,"name":"John Doe"}}}`
+	}, http.StatusInternalServerError, http.StatusBadGateway)
+	users := &fetcher.GraphQLUserFetcher{Client: srv.Client(), Endpoint: srv.URL, Query: fetcher.GraphQLQuery{Path: "user"}}
+
+	user, err := users.Fetch(context.Background(), 1)
+
+	assert.Nil(err)
+	assert.Equal("John Doe", user.Name)
+	assert.Equal(int32(3), calls.Load())
+}
+
+func Test_GraphQLUserFetcher_StatusError(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := graphQLServer(t, nil, 500, 500, 500)
+	users := &fetcher.GraphQLUserFetcher{Client: srv.Client(), Endpoint: srv.URL, Query: fetcher.GraphQLQuery{Path: "user"}}
+
+	user, err := users.Fetch(context.Background(), 1)
+
+	assert.Nil(user)
+	assert.EqualError(err, "fetching user: status code 500")
+	assert.Equal(int32(3), calls.Load())
+}
+
+func Test_GraphQLUserFetcher_NotFound(t *testing.T) {
+	assert := assert.New(t)
+	for name, body := range map[string]string{
+		"null":             `{"data":{"user":null}}`,
+		"null parent":      `{"data":null}`,
+		"NOT_FOUND errors": `{"data":{"user":null},"errors":[{"message":"no user 42","extensions":{"code":"NOT_FOUND"}}]}`,
+	} {
+		srv, calls := graphQLServer(t, func(graphQLRequest) string { return body })
+		users := &fetcher.GraphQLUserFetcher{Client: srv.Client(), Endpoint: srv.URL, Query: fetcher.GraphQLQuery{Path: "user"}}
+
+		user, err := users.Fetch(context.Background(), 42)
+
+		assert.Nil(user, name)
+		assert.ErrorIs(err, fetcher.ErrNotFound, name)
+		assert.Equal(int32(1), calls.Load(), name)
+	}
+}
+
+func Test_GraphQLUserFetcher_GraphQLErrors(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := graphQLServer(t, func(graphQLRequest) string {
+		return `{"data":null,"errors":[{"message":"boom"},{"message":"bang"}]}`
+	})
+	users := &fetcher.GraphQLUserFetcher{Client: srv.Client(), Endpoint: srv.URL, Query: fetcher.GraphQLQuery{Path: "user"}}
+
+	_, err := users.Fetch(context.Background(), 1)
+
+	assert.EqualError(err, "fetching user: graphql errors: boom; bang")
+	assert.NotErrorIs(err, fetcher.ErrNotFound)
+	assert.Equal(int32(1), calls.Load(), "GraphQL errors are not retried")
+}
+
+func Test_GraphQLPostsFetcher_Success(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := graphQLServer(t, func(graphQLRequest) string {
+		return `{"data":{"user":{"posts":{"data":[{"id":"1","title":"a","body":"x"},{"id":2,"userId":"3","title":"b"}]}}}}`
+	})
+	posts := &fetcher.GraphQLPostsFetcher{
+		Client:   srv.Client(),
+		Endpoint: srv.URL,
+		Query:    fetcher.GraphQLQuery{Variables: map[string]any{"id": "$userId"}, Path: "user.posts.data"},
+	}
+
+	got, err := posts.Fetch(context.Background(), 3)
+
+	assert.Nil(err)
+	assert.Equal([]fetcher.Post{
+		{UserID: 3, ID: 1, Title: "a", Body: "x"},
+		{UserID: 3, ID: 2, Title: "b"},
+	}, got)
+}
+
+func Test_GraphQLPostsFetcher_InvalidPath(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := graphQLServer(t, func(graphQLRequest) string {
+		return `{"data":{"user":[]}}`
+	})
+	posts := &fetcher.GraphQLPostsFetcher{Client: srv.Client(), Endpoint: srv.URL, Query: fetcher.GraphQLQuery{Path: "user.posts"}}
+
+	_, err := posts.Fetch(context.Background(), 1)
+
+	assert.EqualError(err, `decoding posts response: "posts" is not an object`)
+}
+
+func Test_ParseGraphQLVariables(t *testing.T) {
+	assert := assert.New(t)
+
+	vars, err := fetcher.ParseGraphQLVariables(`{"id": "$userId"}`)
+	assert.Nil(err)
+	assert.Equal(map[string]any{"id": "$userId"}, vars)
+
+	vars, err = fetcher.ParseGraphQLVariables(" ")
+	assert.Nil(err)
+	assert.Empty(vars)
+
+	_, err = fetcher.ParseGraphQLVariables(`["$userId"]`)
+	assert.NotNil(err)
+}
@@ -0,0 +1,148 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/ratelimit"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLimited is returned when a request gives up waiting for a rate token or
+// an in-flight slot. It is never caused by the upstream itself.
+var ErrLimited = errors.New("limit wait aborted")
+
+// LimitedClient caps outbound requests to an upstream by rate and by the number
+// of requests in flight. Waiting honours the request context deadline.
+type LimitedClient struct {
+	Name   string
+	Client HTTPClient
+
+	bucket *ratelimit.Bucket
+	sem    chan struct{}
+
+	requests  atomic.Int64
+	throttled atomic.Int64
+	queued    atomic.Int64
+	rejected  atomic.Int64
+	inFlight  atomic.Int64
+	waitNanos atomic.Int64
+}
+
+// LimiterStats is a snapshot of a LimitedClient's counters.
+type LimiterStats struct {
+	Name      string        `json:"name"`
+	Requests  int64         `json:"requests"`
+	Throttled int64         `json:"throttled"`
+	Queued    int64         `json:"queued"`
+	Rejected  int64         `json:"rejected"`
+	InFlight  int64         `json:"inFlight"`
+	WaitTotal time.Duration `json:"waitTotal"`
+}
+
+// NewLimitedClient wraps client with a requests-per-second limit and a max
+// in-flight cap. A zero rps or maxInFlight disables that limit.
+func NewLimitedClient(name string, client HTTPClient, rps float64, maxInFlight int) *LimitedClient {
+	lc := &LimitedClient{Name: name, Client: client}
+	if rps > 0 {
+		lc.bucket = ratelimit.NewBucket(ratelimit.Limit{Rate: rps, Burst: int(math.Max(1, math.Ceil(rps)))})
+	}
+	if maxInFlight > 0 {
+		lc.sem = make(chan struct{}, maxInFlight)
+	}
+	return lc
+}
+
+// Do waits for a rate token and an in-flight slot, then performs the request.
+// The slot is released when the response body is closed.
+func (lc *LimitedClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	lc.requests.Add(1)
+
+	if lc.bucket != nil {
+		waited, err := lc.bucket.Wait(ctx)
+		if err != nil {
+			lc.rejected.Add(1)
+			logger.Log.Info("upstream rate limit wait aborted", "upstream", lc.Name, "error", err)
+			return nil, fmt.Errorf("upstream %s rate limited: %w: %w", lc.Name, ErrLimited, err)
+		}
+		if waited > 0 {
+			lc.throttled.Add(1)
+			lc.waitNanos.Add(int64(waited))
+			logger.Log.Info("upstream rate limit throttled request", "upstream", lc.Name, "wait_ms", waited.Milliseconds())
+		}
+	}
+
+	release, err := lc.acquire(ctx)
+	if err != nil {
+		lc.rejected.Add(1)
+		logger.Log.Info("upstream concurrency wait aborted", "upstream", lc.Name, "error", err)
+		return nil, fmt.Errorf("upstream %s saturated: %w: %w", lc.Name, ErrLimited, err)
+	}
+
+	res, err := lc.Client.Do(req)
+	if err != nil || res == nil || res.Body == nil {
+		release()
+		return res, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// Stats returns the current counters.
+func (lc *LimitedClient) Stats() LimiterStats {
+	return LimiterStats{
+		Name:      lc.Name,
+		Requests:  lc.requests.Load(),
+		Throttled: lc.throttled.Load(),
+		Queued:    lc.queued.Load(),
+		Rejected:  lc.rejected.Load(),
+		InFlight:  lc.inFlight.Load(),
+		WaitTotal: time.Duration(lc.waitNanos.Load()),
+	}
+}
+
+func (lc *LimitedClient) acquire(ctx context.Context) (func(), error) {
+	if lc.sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case lc.sem <- struct{}{}:
+	default:
+		lc.queued.Add(1)
+		logger.Log.Info("upstream concurrency saturated, queuing request", "upstream", lc.Name, "max_in_flight", cap(lc.sem))
+		start := time.Now()
+		select {
+		case lc.sem <- struct{}{}:
+			lc.waitNanos.Add(int64(time.Since(start)))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	lc.inFlight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lc.inFlight.Add(-1)
+			<-lc.sem
+		})
+	}, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
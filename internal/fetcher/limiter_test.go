@@ -0,0 +1,101 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+type slowHTTPClient struct {
+	delay   time.Duration
+	current atomic.Int64
+	max     atomic.Int64
+}
+
+func (c *slowHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	n := c.current.Add(1)
+	defer c.current.Add(-1)
+	for {
+		m := c.max.Load()
+		if n <= m || c.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(c.delay)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("[]"))}, nil
+}
+
+func Test_LimitedClient_CapsInFlight(t *testing.T) {
+	assert := assert.New(t)
+	slow := &slowHTTPClient{delay: 20 * time.Millisecond}
+	client := fetcher.NewLimitedClient("posts", slow, 0, 2)
+	postsFetcher := &fetcher.HTTPPostsFetcher{Client: client, BaseURL: "http://example.com/posts"}
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := postsFetcher.Fetch(context.Background(), 1)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	stats := client.Stats()
+	assert.LessOrEqual(slow.max.Load(), int64(2))
+	assert.Equal(int64(6), stats.Requests)
+	assert.Greater(stats.Queued, int64(0))
+	assert.Equal(int64(0), stats.InFlight)
+}
+
+func Test_LimitedClient_RateWaitRespectsDeadline(t *testing.T) {
+	assert := assert.New(t)
+	client := fetcher.NewLimitedClient("users", mock.NewMockHTTPClient("{}", http.StatusOK, nil), 0.5, 0)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/users/1", nil)
+	res, err := client.Do(req)
+	assert.Nil(err)
+	res.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/users/1", nil)
+	res, err = client.Do(req)
+
+	assert.Nil(res)
+	assert.NotNil(err)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(err.Error(), "upstream users rate limited")
+	assert.Equal(int64(1), client.Stats().Rejected)
+}
+
+func Test_LimitedClient_RetriesReleaseSlots(t *testing.T) {
+	assert := assert.New(t)
+	client := fetcher.NewLimitedClient("users", mock.NewMockHTTPClient("", http.StatusInternalServerError, nil), 0, 1)
+	userFetcher := &fetcher.HTTPUserFetcher{Client: client, BaseURL: "http://example.com/users"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	user, err := userFetcher.Fetch(ctx, 1)
+
+	assert.Nil(user)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "fetching user: status code 500")
+	assert.Equal(int64(0), client.Stats().InFlight)
+}
@@ -0,0 +1,112 @@
+package fetcher
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Registry tracks the circuit breakers, limiters and chaos clients of each
+// upstream so they can be inspected and operated at runtime.
+type Registry struct {
+	mu       sync.RWMutex
+	breakers map[string]*CircuitBreaker
+	limiters map[string]*LimitedClient
+	chaos    map[string]*ChaosClient
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		breakers: map[string]*CircuitBreaker{},
+		limiters: map[string]*LimitedClient{},
+		chaos:    map[string]*ChaosClient{},
+	}
+}
+
+// AddBreaker registers cb under its name, replacing any previous one.
+func (r *Registry) AddBreaker(cb *CircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers[cb.Name] = cb
+}
+
+// AddLimiter registers lc under its name, replacing any previous one.
+func (r *Registry) AddLimiter(lc *LimitedClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiters[lc.Name] = lc
+}
+
+// Replace makes the breakers, limiters and chaos clients of r those of other,
+// dropping the rest.
+func (r *Registry) Replace(other *Registry) {
+	other.mu.RLock()
+	breakers, limiters, chaos := maps.Clone(other.breakers), maps.Clone(other.limiters), maps.Clone(other.chaos)
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers, r.limiters, r.chaos = breakers, limiters, chaos
+}
+
+// Breaker returns the named circuit breaker, or nil.
+func (r *Registry) Breaker(name string) *CircuitBreaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breakers[name]
+}
+
+// BreakerStatuses returns the status of every circuit breaker, sorted by name.
+func (r *Registry) BreakerStatuses() []BreakerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]BreakerStatus, 0, len(r.breakers))
+	for _, cb := range r.breakers {
+		statuses = append(statuses, cb.Status())
+	}
+	slices.SortFunc(statuses, func(a, b BreakerStatus) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+// LimiterStats returns the counters of every limiter, sorted by name.
+func (r *Registry) LimiterStats() []LimiterStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]LimiterStats, 0, len(r.limiters))
+	for _, lc := range r.limiters {
+		stats = append(stats, lc.Stats())
+	}
+	slices.SortFunc(stats, func(a, b LimiterStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// AddChaos registers cc under its name, replacing any previous one.
+func (r *Registry) AddChaos(cc *ChaosClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chaos[cc.Name] = cc
+}
+
+// Chaos returns the named chaos client, or nil.
+func (r *Registry) Chaos(name string) *ChaosClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.chaos[name]
+}
+
+// ChaosStatuses returns the status of every chaos client, sorted by name.
+func (r *Registry) ChaosStatuses() []ChaosStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]ChaosStatus, 0, len(r.chaos))
+	for _, cc := range r.chaos {
+		statuses = append(statuses, cc.Status())
+	}
+	slices.SortFunc(statuses, func(a, b ChaosStatus) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
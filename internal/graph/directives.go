@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// NewConfig builds the executable schema config with resolvers and directives wired.
+func NewConfig(resolver *Resolver) Config {
+	return Config{
+		Resolvers: resolver,
+		Directives: DirectiveRoot{
+			Auth:     authDirective,
+			HasScope: hasScopeDirective,
+		},
+	}
+}
+
+// authDirective implements @auth(requires: Role), rejecting callers without the role.
+func authDirective(ctx context.Context, obj any, next graphql.Resolver, requires *model.Role) (any, error) {
+	role := model.RoleUser
+	if requires != nil {
+		role = *requires
+	}
+
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+	if !principal.HasRole(role.String()) {
+		return nil, forbiddenError(ctx, fmt.Sprintf("requires role %s", role))
+	}
+	return next(ctx)
+}
+
+// hasScopeDirective implements @hasScope(scope: String!), rejecting callers without the scope.
+func hasScopeDirective(ctx context.Context, obj any, next graphql.Resolver, scope string) (any, error) {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+	if !principal.HasScope(scope) {
+		return nil, forbiddenError(ctx, fmt.Sprintf("requires scope %s", scope))
+	}
+	return next(ctx)
+}
+
+func unauthenticatedError(ctx context.Context) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    "authentication required",
+		Extensions: map[string]any{"code": apierror.CodeUnauthenticated},
+	}
+}
+
+func forbiddenError(ctx context.Context, reason string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    "forbidden: " + reason,
+		Extensions: map[string]any{"code": apierror.CodeForbidden},
+	}
+}
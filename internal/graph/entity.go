@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/graph/model"
+	"slices"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"golang.org/x/sync/errgroup"
+)
+
+// entityConcurrency bounds the users fetched at once for an _entities query.
+const entityConcurrency = 8
+
+// Entity returns EntityResolver implementation.
+func (r *Resolver) Entity() EntityResolver { return &entityResolver{r} }
+
+type entityResolver struct{ *Resolver }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type userResolver struct{ *Resolver }
+
+// FindManyUserByIDs resolves every User representation of an _entities query
+// in one batch: each distinct id is fetched once through the user fetcher.
+// Unknown users resolve to null; users that fail to be fetched resolve to
+// null too, with an error at the path of their representation, so one failed
+// user doesn't fail the batch.
+func (r *entityResolver) FindManyUserByIDs(ctx context.Context, reps []*model.UserByIDsInput) ([]*model.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	type fetched struct {
+		id   int32
+		user *fetcher.User
+		err  error
+	}
+	ids := make(map[int32]bool, len(reps))
+	for _, rep := range reps {
+		ids[rep.ID] = true
+	}
+
+	results := make(chan fetched, len(ids))
+	var g errgroup.Group
+	g.SetLimit(entityConcurrency)
+	for id := range ids {
+		g.Go(func() error {
+			user, err := r.Aggregator.UserFetcher.Fetch(ctx, int(id))
+			results <- fetched{id: id, user: user, err: err}
+			return nil
+		})
+	}
+	g.Wait()
+	close(results)
+
+	users := make(map[int32]*model.User, len(ids))
+	errs := make(map[int32]error)
+	for f := range results {
+		switch {
+		case errors.Is(f.err, fetcher.ErrNotFound):
+		case f.err != nil:
+			errs[f.id] = f.err
+		default:
+			users[f.id] = &model.User{ID: int32(f.user.ID), Name: f.user.Name, Email: &f.user.Email}
+		}
+	}
+
+	indexes := representationIndexes(ctx, "User", len(reps))
+	res := make([]*model.User, len(reps))
+	for i, rep := range reps {
+		res[i] = users[rep.ID]
+		if err, failed := errs[rep.ID]; failed {
+			graphql.AddError(ctx, &gqlerror.Error{
+				Path:       append(slices.Clone(graphql.GetPath(ctx)), ast.PathIndex(indexes[i])),
+				Message:    err.Error(),
+				Extensions: map[string]any{"code": apierror.Code(err)},
+			})
+		}
+	}
+	return res, nil
+}
+
+// representationIndexes returns the index in the representations of the
+// _entities query of ctx of each of the n representations of typeName, in
+// the order they are passed to its resolver.
+func representationIndexes(ctx context.Context, typeName string, n int) []int {
+	indexes := make([]int, 0, n)
+	if fc := graphql.GetFieldContext(ctx); fc != nil {
+		reps, _ := fc.Args["representations"].([]map[string]any)
+		for i, rep := range reps {
+			if rep["__typename"] == typeName {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	if len(indexes) != n {
+		// not called for an _entities query
+		indexes = indexes[:0]
+		for i := range n {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// PostCount resolves the posts of a user only when asked for, so gateways
+// fetching other fields don't call the posts API.
+func (r *userResolver) PostCount(ctx context.Context, obj *model.User) (int32, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	posts, err := r.Aggregator.PostsFetcher.Fetch(ctx, int(obj.ID))
+	if err != nil {
+		return 0, err
+	}
+	return int32(len(posts)), nil
+}
+
+// withTimeout bounds ctx by the aggregation timeout, if set.
+func (r *Resolver) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Aggregator.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.Aggregator.Timeout)
+}
@@ -2,13 +2,19 @@ package graph_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/fetcher"
 	"go-graphql-aggregator/internal/graph"
 	"go-graphql-aggregator/internal/test"
 	"go-graphql-aggregator/internal/test/mock"
+	"go-graphql-aggregator/internal/webhook"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -29,12 +35,13 @@ func Test_UserSummaryQuery_Success(t *testing.T) {
 	}
 
 	resolver := &graph.Resolver{Aggregator: mockAgg}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 	srv.AddTransport(transport.POST{})
 
 	body := `{"query": "query { userSummary(userId: 1) { name email postCount } }"}`
 	req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "tester", Scopes: []string{"read:pii"}}))
 	w := httptest.NewRecorder()
 
 	srv.ServeHTTP(w, req)
@@ -68,7 +75,7 @@ func Test_UserSummaryQuery_UserFetcherError(t *testing.T) {
 	}
 
 	resolver := &graph.Resolver{Aggregator: mockAgg}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 	srv.AddTransport(transport.POST{})
 
 	body := `{"query": "query { userSummary(userId: 1) { name email postCount } }"}`
@@ -102,7 +109,7 @@ func Test_UserSummaryQuery_PostsFetcherError(t *testing.T) {
 	}
 
 	resolver := &graph.Resolver{Aggregator: mockAgg}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 	srv.AddTransport(transport.POST{})
 
 	body := `{"query": "query { userSummary(userId: 1) { name email postCount } }"}`
@@ -135,7 +142,7 @@ func Test_UserSummaryQuery_InvalidUserID(t *testing.T) {
 	}
 
 	resolver := &graph.Resolver{Aggregator: mockAgg}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 	srv.AddTransport(transport.POST{})
 
 	body := `{"query": "query { userSummary(userId: -1) { name email postCount } }"}`
@@ -169,7 +176,7 @@ func Test_UserSummaryQuery_MalformedRequest(t *testing.T) {
 	}
 
 	resolver := &graph.Resolver{Aggregator: mockAgg}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 	srv.AddTransport(transport.POST{})
 
 	body := `{"query": "query { userSummary(userId: ) { name email postCount } }"}`
@@ -192,3 +199,240 @@ func Test_UserSummaryQuery_MalformedRequest(t *testing.T) {
 	assert.Len(resp.Errors, 1)
 	assert.Nil(resp.Data["userSummary"])
 }
+
+func Test_UserSummaryQuery_EmailRequiresScope(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAgg := &aggregator.Aggregator{
+		UserFetcher: &mock.MockUserFetcher{User: mock.UserMock},
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+	}
+
+	resolver := &graph.Resolver{Aggregator: mockAgg}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		code      string
+	}{
+		{name: "anonymous", principal: nil, code: "UNAUTHENTICATED"},
+		{name: "missing scope", principal: &auth.Principal{Subject: "tester", Roles: []string{"USER"}}, code: "FORBIDDEN"},
+	}
+
+	for _, tc := range cases {
+		body := `{"query": "query { userSummary(userId: 1) { name email postCount } }"}`
+		req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+		}
+		w := httptest.NewRecorder()
+
+		srv.ServeHTTP(w, req)
+
+		var resp struct {
+			Data struct {
+				UserSummary struct {
+					Name      string
+					Email     *string
+					PostCount int32
+				}
+			}
+			Errors []struct {
+				Message    string
+				Path       []any
+				Extensions map[string]any
+			}
+		}
+		err := json.NewDecoder(w.Body).Decode(&resp)
+		assert.Nil(err, tc.name)
+		assert.Equal("John Doe", resp.Data.UserSummary.Name, tc.name)
+		assert.Nil(resp.Data.UserSummary.Email, tc.name)
+		assert.Equal(int32(2), resp.Data.UserSummary.PostCount, tc.name)
+		if assert.Len(resp.Errors, 1, tc.name) {
+			assert.Equal(tc.code, resp.Errors[0].Extensions["code"], tc.name)
+			assert.Equal([]any{"userSummary", "email"}, resp.Errors[0].Path, tc.name)
+		}
+	}
+}
+
+func Test_CreateWebhook_RequiresAdmin(t *testing.T) {
+	assert := assert.New(t)
+
+	manager := webhook.NewManager(webhook.Options{}, nil)
+	defer manager.Close()
+	resolver := &graph.Resolver{WebhookManager: manager}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		code      string
+	}{
+		{name: "anonymous", principal: nil, code: "UNAUTHENTICATED"},
+		{name: "user", principal: &auth.Principal{Subject: "tester", Roles: []string{"USER"}}, code: "FORBIDDEN"},
+		{name: "admin", principal: &auth.Principal{Subject: "ops", Roles: []string{"ADMIN"}}},
+	}
+
+	for _, tc := range cases {
+		body := `{"query": "mutation { createWebhook(url: \"https://hooks.example/summary\", events: [POST_COUNT_CHANGED], secret: \"s3cret\") { url events fromConfig secret } }"}`
+		req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+		}
+		w := httptest.NewRecorder()
+
+		srv.ServeHTTP(w, req)
+
+		var resp struct {
+			Data struct {
+				CreateWebhook *struct {
+					URL        string
+					Events     []string
+					FromConfig bool
+					Secret     string
+				}
+			}
+			Errors []struct {
+				Extensions map[string]any
+			}
+		}
+		err := json.NewDecoder(w.Body).Decode(&resp)
+		assert.Nil(err, tc.name)
+		if tc.code != "" {
+			assert.Nil(resp.Data.CreateWebhook, tc.name)
+			if assert.Len(resp.Errors, 1, tc.name) {
+				assert.Equal(tc.code, resp.Errors[0].Extensions["code"], tc.name)
+			}
+			continue
+		}
+		assert.Empty(resp.Errors, tc.name)
+		if assert.NotNil(resp.Data.CreateWebhook, tc.name) {
+			assert.Equal("https://hooks.example/summary", resp.Data.CreateWebhook.URL)
+			assert.Equal([]string{"POST_COUNT_CHANGED"}, resp.Data.CreateWebhook.Events)
+			assert.Equal("s3cret", resp.Data.CreateWebhook.Secret)
+		}
+	}
+	assert.Len(manager.Subscriptions(), 1)
+}
+
+// countingUserFetcher serves users 1 to 10 and counts the fetches; user 99
+// fails as an upstream error.
+type countingUserFetcher struct {
+	mu    sync.Mutex
+	calls map[int]int
+}
+
+func (f *countingUserFetcher) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	f.mu.Lock()
+	f.calls[userID]++
+	f.mu.Unlock()
+	if userID == 99 {
+		return nil, errors.New("users API returned 500")
+	}
+	if userID > 10 {
+		return nil, fmt.Errorf("fetching user: %w", fetcher.ErrNotFound)
+	}
+	return &fetcher.User{ID: userID, Name: fmt.Sprintf("User %d", userID), Email: "user@example.com"}, nil
+}
+
+func Test_Entities_BatchesUserLookups(t *testing.T) {
+	assert := assert.New(t)
+
+	users := &countingUserFetcher{calls: map[int]int{}}
+	resolver := &graph.Resolver{Aggregator: &aggregator.Aggregator{
+		UserFetcher:  users,
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+	}}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+
+	body := `{
+		"query": "query($r: [_Any!]!) { _entities(representations: $r) { ... on User { id name postCount } } }",
+		"variables": {"r": [{"__typename": "User", "id": 2}, {"__typename": "User", "id": 1}, {"__typename": "User", "id": 2}, {"__typename": "User", "id": 42}]}
+	}`
+	req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	assert.JSONEq(`{"data":{"_entities":[
+		{"id":2,"name":"User 2","postCount":2},
+		{"id":1,"name":"User 1","postCount":2},
+		{"id":2,"name":"User 2","postCount":2},
+		null
+	]}}`, w.Body.String())
+	assert.Equal(map[int]int{1: 1, 2: 1, 42: 1}, users.calls, "each user is fetched once")
+}
+
+func Test_Entities_ReportsFailedUsersAtTheirPath(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := &graph.Resolver{Aggregator: &aggregator.Aggregator{
+		UserFetcher:  &countingUserFetcher{calls: map[int]int{}},
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+	}}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+
+	body := `{
+		"query": "query($r: [_Any!]!) { _entities(representations: $r) { ... on User { id name } } }",
+		"variables": {"r": [{"id": 3}, {"__typename": "User", "id": 1}, {"__typename": "User", "id": 99}, {"__typename": "User", "id": 2}]}
+	}`
+	req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp struct {
+		Data struct {
+			Entities []*struct{ ID int } `json:"_entities"`
+		}
+		Errors []struct {
+			Message    string
+			Path       []any
+			Extensions map[string]any
+		}
+	}
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(resp.Data.Entities, 4, w.Body.String()) {
+		assert.Nil(resp.Data.Entities[0])
+		assert.Equal(1, resp.Data.Entities[1].ID)
+		assert.Nil(resp.Data.Entities[2], "the failed user resolves to null")
+		assert.Equal(2, resp.Data.Entities[3].ID, "the other users still resolve")
+	}
+	var failed bool
+	for _, e := range resp.Errors {
+		if e.Message == "users API returned 500" {
+			failed = true
+			assert.Equal([]any{"_entities", float64(2)}, e.Path)
+			assert.Equal("UPSTREAM_ERROR", e.Extensions["code"])
+		}
+	}
+	assert.True(failed, w.Body.String())
+}
+
+func Test_Service_SDLDeclaresUserEntity(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := &graph.Resolver{}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+
+	req := httptest.NewRequest("POST", "/query", bytes.NewBufferString(`{"query": "{ _service { sdl } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp struct {
+		Data struct {
+			Service struct{ SDL string } `json:"_service"`
+		}
+	}
+	assert.Nil(json.NewDecoder(w.Body).Decode(&resp))
+	assert.Contains(resp.Data.Service.SDL, `type User @key(fields: "id")`)
+}
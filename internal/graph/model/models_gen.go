@@ -2,11 +2,389 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A background batch of user summaries. Results and errors are partial until the job finishes.
+type Job struct {
+	ID        string    `json:"id"`
+	Status    JobStatus `json:"status"`
+	Total     int32     `json:"total"`
+	Processed int32     `json:"processed"`
+	// Fraction of the users processed, from 0 to 1.
+	Progress float64 `json:"progress"`
+	// RFC 3339 times; expiresAt is when a finished job is evicted.
+	CreatedAt  string       `json:"createdAt"`
+	StartedAt  *string      `json:"startedAt,omitempty"`
+	FinishedAt *string      `json:"finishedAt,omitempty"`
+	ExpiresAt  *string      `json:"expiresAt,omitempty"`
+	Results    []*JobResult `json:"results"`
+	Errors     []*JobError  `json:"errors"`
+}
+
+type JobError struct {
+	UserID  int32  `json:"userId"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type JobResult struct {
+	UserID  int32        `json:"userId"`
+	Summary *UserSummary `json:"summary"`
+}
+
+type Mutation struct {
+}
+
 type Query struct {
 }
 
+// Syncs of the local store. Counts and stats are those of the last successful sync.
+type SyncStatus struct {
+	// RFC 3339 time of the last successful sync, null before the first one.
+	LastSuccessAt  *string `json:"lastSuccessAt,omitempty"`
+	LastAttemptAt  *string `json:"lastAttemptAt,omitempty"`
+	LastError      *string `json:"lastError,omitempty"`
+	LastDurationMs int32   `json:"lastDurationMs"`
+	// Age of the data served from the store, null before the first successful sync.
+	AgeSeconds      *int32  `json:"ageSeconds,omitempty"`
+	Users           int32   `json:"users"`
+	Posts           int32   `json:"posts"`
+	Summaries       int32   `json:"summaries"`
+	AvgPostsPerUser float64 `json:"avgPostsPerUser"`
+	MaxPostsPerUser int32   `json:"maxPostsPerUser"`
+}
+
+// A user, contributed to the federated graph as an entity so other subgraphs can extend it.
+type User struct {
+	ID        int32   `json:"id"`
+	Name      string  `json:"name"`
+	Email     *string `json:"email,omitempty"`
+	PostCount int32   `json:"postCount"`
+}
+
+func (User) IsEntity() {}
+
+type UserByIDsInput struct {
+	ID int32 `json:"ID"`
+}
+
 type UserSummary struct {
-	Name      string `json:"name"`
-	Email     string `json:"email"`
-	PostCount int32  `json:"postCount"`
+	Name      string  `json:"name"`
+	Email     *string `json:"email,omitempty"`
+	PostCount int32   `json:"postCount"`
+}
+
+type Webhook struct {
+	ID     string         `json:"id"`
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	// Webhooks of the config can't be deleted.
+	FromConfig bool   `json:"fromConfig"`
+	CreatedAt  string `json:"createdAt"`
+	// The signing secret, only returned by createWebhook.
+	Secret *string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID        string                `json:"id"`
+	WebhookID string                `json:"webhookId"`
+	EventID   string                `json:"eventId"`
+	Event     WebhookEvent          `json:"event"`
+	UserID    int32                 `json:"userId"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int32                 `json:"attempts"`
+	// Status code of the last attempt, null when it got no response.
+	LastStatusCode *int32  `json:"lastStatusCode,omitempty"`
+	LastError      *string `json:"lastError,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+	DeliveredAt    *string `json:"deliveredAt,omitempty"`
+	NextAttemptAt  *string `json:"nextAttemptAt,omitempty"`
+}
+
+type CacheControlScope string
+
+const (
+	CacheControlScopePublic  CacheControlScope = "PUBLIC"
+	CacheControlScopePrivate CacheControlScope = "PRIVATE"
+)
+
+var AllCacheControlScope = []CacheControlScope{
+	CacheControlScopePublic,
+	CacheControlScopePrivate,
+}
+
+func (e CacheControlScope) IsValid() bool {
+	switch e {
+	case CacheControlScopePublic, CacheControlScopePrivate:
+		return true
+	}
+	return false
+}
+
+func (e CacheControlScope) String() string {
+	return string(e)
+}
+
+func (e *CacheControlScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CacheControlScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CacheControlScope", str)
+	}
+	return nil
+}
+
+func (e CacheControlScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CacheControlScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CacheControlScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "QUEUED"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusCompleted JobStatus = "COMPLETED"
+	JobStatusCancelled JobStatus = "CANCELLED"
+)
+
+var AllJobStatus = []JobStatus{
+	JobStatusQueued,
+	JobStatusRunning,
+	JobStatusCompleted,
+	JobStatusCancelled,
+}
+
+func (e JobStatus) IsValid() bool {
+	switch e {
+	case JobStatusQueued, JobStatusRunning, JobStatusCompleted, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e JobStatus) String() string {
+	return string(e)
+}
+
+func (e *JobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobStatus", str)
+	}
+	return nil
+}
+
+func (e JobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *JobStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e JobStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleAdmin Role = "ADMIN"
+	RoleUser  Role = "USER"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleUser,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleUser:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "DEAD"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusDead,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookDeliveryStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookEvent string
+
+const (
+	WebhookEventPostCountChanged WebhookEvent = "POST_COUNT_CHANGED"
+	WebhookEventNameChanged      WebhookEvent = "NAME_CHANGED"
+	WebhookEventEmailChanged     WebhookEvent = "EMAIL_CHANGED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventPostCountChanged,
+	WebhookEventNameChanged,
+	WebhookEventEmailChanged,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventPostCountChanged, WebhookEventNameChanged, WebhookEventEmailChanged:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookEvent) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookEvent) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
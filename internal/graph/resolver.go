@@ -2,8 +2,14 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/auth"
 	"go-graphql-aggregator/internal/graph/model"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/store"
+	"go-graphql-aggregator/internal/webhook"
+	"time"
 )
 
 // This file will not be regenerated automatically.
@@ -12,10 +18,23 @@ import (
 
 type Resolver struct{
 	Aggregator *aggregator.Aggregator
+	// Store is the local store, nil when disabled.
+	Store *store.Store
+	// Jobs runs the summary jobs, nil when they are unavailable.
+	Jobs *jobs.Manager
+	// WebhookManager holds the webhook subscriptions, nil when unavailable.
+	WebhookManager *webhook.Manager
 }
 
 type queryResolver struct{ *Resolver }
 
+type mutationResolver struct{ *Resolver }
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver {
+	return &mutationResolver{r}
+}
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver {
     return &queryResolver{r}
@@ -30,9 +49,121 @@ func (r *queryResolver) UserSummary(ctx context.Context, userID int32) (*model.U
 
 	modelSummary := &model.UserSummary{
 		Name:      aggSummary.Name,
-		Email:     aggSummary.Email,
+		Email:     &aggSummary.Email,
 		PostCount: int32(aggSummary.PostCount),
 	}
 	return modelSummary, nil
 }
 
+// SyncStatus resolves the syncStatus query from the local store, if enabled.
+func (r *queryResolver) SyncStatus(ctx context.Context) (*model.SyncStatus, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+	status, err := r.Store.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.SyncStatus{
+		LastDurationMs:  int32(status.LastDuration.Milliseconds()),
+		Users:           int32(status.Users),
+		Posts:           int32(status.Posts),
+		Summaries:       int32(status.Summaries),
+		AvgPostsPerUser: status.AvgPostsPerUser,
+		MaxPostsPerUser: int32(status.MaxPostsPerUser),
+	}
+	if !status.LastSuccess.IsZero() {
+		at := status.LastSuccess.Format(time.RFC3339)
+		age := int32(time.Since(status.LastSuccess).Seconds())
+		res.LastSuccessAt, res.AgeSeconds = &at, &age
+	}
+	if !status.LastAttempt.IsZero() {
+		at := status.LastAttempt.Format(time.RFC3339)
+		res.LastAttemptAt = &at
+	}
+	if status.LastError != "" {
+		res.LastError = &status.LastError
+	}
+	return res, nil
+}
+
+var errJobsUnavailable = errors.New("summary jobs are only available on the server")
+
+// Job resolves the job query, hiding the jobs of other principals.
+func (r *queryResolver) Job(ctx context.Context, id string) (*model.Job, error) {
+	if r.Jobs == nil {
+		return nil, errJobsUnavailable
+	}
+	job, ok := r.Jobs.Get(id)
+	if !ok || !job.VisibleTo(auth.FromContext(ctx)) {
+		return nil, nil
+	}
+	return r.jobModel(job), nil
+}
+
+// StartSummaryJob queues a summary job owned by the caller.
+func (r *mutationResolver) StartSummaryJob(ctx context.Context, userIds []int32) (*model.Job, error) {
+	if r.Jobs == nil {
+		return nil, errJobsUnavailable
+	}
+	ids := make([]int, len(userIds))
+	for i, id := range userIds {
+		ids[i] = int(id)
+	}
+	var owner string
+	if p := auth.FromContext(ctx); p != nil {
+		owner = p.Subject
+	}
+	job, err := r.Jobs.Submit(ids, owner)
+	if err != nil {
+		return nil, err
+	}
+	return r.jobModel(job), nil
+}
+
+// CancelJob cancels a job visible to the caller.
+func (r *mutationResolver) CancelJob(ctx context.Context, id string) (*model.Job, error) {
+	if r.Jobs == nil {
+		return nil, errJobsUnavailable
+	}
+	if job, ok := r.Jobs.Get(id); !ok || !job.VisibleTo(auth.FromContext(ctx)) {
+		return nil, jobs.ErrNotFound
+	}
+	job, err := r.Jobs.Cancel(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.jobModel(job), nil
+}
+
+func (r *Resolver) jobModel(job *jobs.Job) *model.Job {
+	res := &model.Job{
+		ID:        job.ID,
+		Status:    model.JobStatus(job.Status),
+		Total:     int32(len(job.UserIDs)),
+		Processed: int32(job.Processed()),
+		Progress:  job.Progress(),
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+		Results:   make([]*model.JobResult, len(job.Results)),
+		Errors:    make([]*model.JobError, len(job.Errors)),
+	}
+	if !job.StartedAt.IsZero() {
+		at := job.StartedAt.Format(time.RFC3339)
+		res.StartedAt = &at
+	}
+	if job.Finished() {
+		finished, expires := job.FinishedAt.Format(time.RFC3339), r.Jobs.ExpiresAt(job).Format(time.RFC3339)
+		res.FinishedAt, res.ExpiresAt = &finished, &expires
+	}
+	for i, result := range job.Results {
+		res.Results[i] = &model.JobResult{
+			UserID:  int32(result.UserID),
+			Summary: &model.UserSummary{Name: result.Name, Email: &result.Email, PostCount: int32(result.PostCount)},
+		}
+	}
+	for i, e := range job.Errors {
+		res.Errors[i] = &model.JobError{UserID: int32(e.UserID), Code: e.Code, Message: e.Message}
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/graph/model"
+	"go-graphql-aggregator/internal/webhook"
+	"time"
+)
+
+var errWebhooksUnavailable = errors.New("webhooks are only available on the server")
+
+// Webhooks lists the webhook subscriptions.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	if r.WebhookManager == nil {
+		return nil, errWebhooksUnavailable
+	}
+	subs := r.WebhookManager.Subscriptions()
+	res := make([]*model.Webhook, len(subs))
+	for i := range subs {
+		res[i] = webhookModel(&subs[i])
+	}
+	return res, nil
+}
+
+// WebhookDeliveries lists the recent webhook deliveries.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID *string, status *model.WebhookDeliveryStatus, limit *int32) ([]*model.WebhookDelivery, error) {
+	if r.WebhookManager == nil {
+		return nil, errWebhooksUnavailable
+	}
+	var (
+		id string
+		st webhook.DeliveryStatus
+		n  int
+	)
+	if webhookID != nil {
+		id = *webhookID
+	}
+	if status != nil {
+		st = webhook.DeliveryStatus(*status)
+	}
+	if limit != nil {
+		n = int(*limit)
+	}
+	deliveries := r.WebhookManager.Deliveries(id, st, n)
+	res := make([]*model.WebhookDelivery, len(deliveries))
+	for i := range deliveries {
+		res[i] = deliveryModel(&deliveries[i])
+	}
+	return res, nil
+}
+
+// CreateWebhook subscribes a URL, returning its secret this once.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, url string, events []model.WebhookEvent, secret *string) (*model.Webhook, error) {
+	if r.WebhookManager == nil {
+		return nil, errWebhooksUnavailable
+	}
+	types := make([]webhook.EventType, len(events))
+	for i, e := range events {
+		types[i] = webhook.EventType(e)
+	}
+	var s string
+	if secret != nil {
+		s = *secret
+	}
+	sub, err := r.WebhookManager.Subscribe(url, types, s)
+	if err != nil {
+		return nil, err
+	}
+	res := webhookModel(sub)
+	res.Secret = &sub.Secret
+	return res, nil
+}
+
+// DeleteWebhook deletes a webhook created through the API.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if r.WebhookManager == nil {
+		return false, errWebhooksUnavailable
+	}
+	if err := r.WebhookManager.Unsubscribe(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RedeliverWebhook sends a dead letter again.
+func (r *mutationResolver) RedeliverWebhook(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	if r.WebhookManager == nil {
+		return nil, errWebhooksUnavailable
+	}
+	d, err := r.WebhookManager.Redeliver(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return deliveryModel(d), nil
+}
+
+func webhookModel(sub *webhook.Subscription) *model.Webhook {
+	res := &model.Webhook{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		Events:     make([]model.WebhookEvent, len(sub.Events)),
+		FromConfig: sub.FromConfig,
+		CreatedAt:  sub.CreatedAt.Format(time.RFC3339),
+	}
+	for i, e := range sub.Events {
+		res.Events[i] = model.WebhookEvent(e)
+	}
+	return res
+}
+
+func deliveryModel(d *webhook.Delivery) *model.WebhookDelivery {
+	res := &model.WebhookDelivery{
+		ID:        d.ID,
+		WebhookID: d.SubscriptionID,
+		EventID:   d.Event.ID,
+		Event:     model.WebhookEvent(d.Event.Type),
+		UserID:    int32(d.Event.UserID),
+		Status:    model.WebhookDeliveryStatus(d.Status),
+		Attempts:  int32(d.Attempts),
+		CreatedAt: d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.LastStatusCode != 0 {
+		code := int32(d.LastStatusCode)
+		res.LastStatusCode = &code
+	}
+	if d.LastError != "" {
+		res.LastError = &d.LastError
+	}
+	if !d.DeliveredAt.IsZero() {
+		at := d.DeliveredAt.Format(time.RFC3339)
+		res.DeliveredAt = &at
+	}
+	if !d.NextAttemptAt.IsZero() {
+		at := d.NextAttemptAt.Format(time.RFC3339)
+		res.NextAttemptAt = &at
+	}
+	return res
+}
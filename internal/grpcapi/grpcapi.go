@@ -0,0 +1,143 @@
+// Package grpcapi serves the aggregator over gRPC, implementing the
+// AggregatorService of proto/aggregator/v1.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	aggregatorv1 "go-graphql-aggregator/proto/aggregator/v1"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// MaxBatchSize caps the user IDs of a BatchGetUserSummaries call.
+const MaxBatchSize = 100
+
+// batchConcurrency is how many summaries of a batch are aggregated at once.
+const batchConcurrency = 4
+
+// Options wires the gRPC service to the running server.
+type Options struct {
+	// Aggregator returns the aggregator of the current server.
+	Aggregator func() *aggregator.Aggregator
+	Tokens     auth.Tokens
+	// Limiter, when set, limits the calls of each client with the buckets of
+	// the HTTP endpoints.
+	Limiter *middleware.RateLimiter
+}
+
+// NewServer returns a gRPC server with the AggregatorService, the standard
+// health service and server reflection registered.
+func NewServer(opts Options) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryLoggingAndRecovery, unaryAuth(opts.Tokens, opts.Limiter), unaryRateLimit(opts.Limiter)),
+		grpc.ChainStreamInterceptor(streamLoggingAndRecovery, streamAuth(opts.Tokens, opts.Limiter), streamRateLimit(opts.Limiter)),
+	)
+	aggregatorv1.RegisterAggregatorServiceServer(srv, &service{aggregator: opts.Aggregator})
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	return srv
+}
+
+type service struct {
+	aggregatorv1.UnimplementedAggregatorServiceServer
+	aggregator func() *aggregator.Aggregator
+}
+
+// GetUserSummary aggregates within the call deadline, further capped by the
+// aggregator timeout.
+func (s *service) GetUserSummary(ctx context.Context, req *aggregatorv1.GetUserSummaryRequest) (*aggregatorv1.GetUserSummaryResponse, error) {
+	summary, err := s.summary(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	return &aggregatorv1.GetUserSummaryResponse{Summary: summary}, nil
+}
+
+func (s *service) BatchGetUserSummaries(req *aggregatorv1.BatchGetUserSummariesRequest, stream grpc.ServerStreamingServer[aggregatorv1.BatchGetUserSummariesResponse]) error {
+	ids := slices.Clone(req.GetUserIds())
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) > MaxBatchSize {
+		return status.Errorf(codes.InvalidArgument, "at most %d user IDs per batch, got %d", MaxBatchSize, len(ids))
+	}
+
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(stream.Context())
+	g.SetLimit(batchConcurrency)
+	for _, id := range ids {
+		g.Go(func() error {
+			res := &aggregatorv1.BatchGetUserSummariesResponse{UserId: id}
+			summary, err := s.summary(ctx, id)
+			if err != nil {
+				st := status.Convert(err)
+				res.Result = &aggregatorv1.BatchGetUserSummariesResponse_Error{Error: &aggregatorv1.Error{Code: codeName(st.Code()), Message: st.Message()}}
+			} else {
+				res.Result = &aggregatorv1.BatchGetUserSummariesResponse_Summary{Summary: summary}
+			}
+			// Send is not safe for concurrent use
+			mu.Lock()
+			defer mu.Unlock()
+			return stream.Send(res)
+		})
+	}
+	return g.Wait()
+}
+
+func (s *service) summary(ctx context.Context, userID int32) (*aggregatorv1.UserSummary, error) {
+	if userID <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid user ID: %d", userID)
+	}
+	summary, err := s.aggregator().GetUserSummary(ctx, int(userID))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	res := &aggregatorv1.UserSummary{UserId: userID, Name: summary.Name, PostCount: int32(summary.PostCount)}
+	if auth.FromContext(ctx).HasScope("read:pii") {
+		res.Email = &summary.Email
+	}
+	return res, nil
+}
+
+// statusError maps an aggregator error to the gRPC status of its code.
+func statusError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	switch apierror.Code(err) {
+	case apierror.CodeNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case apierror.CodeTimeout:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case apierror.CodeUnavailable:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	logger.Log.Error("grpc request failed", "error", err)
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// codeName renders a code like the proto enum, e.g. NOT_FOUND.
+func codeName(c codes.Code) string {
+	name := c.String()
+	var out []byte
+	for i := range len(name) {
+		if i > 0 && name[i] >= 'A' && name[i] <= 'Z' {
+			out = append(out, '_')
+		}
+		out = append(out, name[i]&^0x20)
+	}
+	return string(out)
+}
@@ -0,0 +1,201 @@
+package grpcapi_test
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/grpcapi"
+	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/ratelimit"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	aggregatorv1 "go-graphql-aggregator/proto/aggregator/v1"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func newClient(t *testing.T, agg *aggregator.Aggregator) aggregatorv1.AggregatorServiceClient {
+	return newClientWith(t, grpcapi.Options{Aggregator: func() *aggregator.Aggregator { return agg }})
+}
+
+func newClientWith(t *testing.T, opts grpcapi.Options) aggregatorv1.AggregatorServiceClient {
+	ln := bufconn.Listen(1 << 20)
+	opts.Tokens = auth.Tokens{"s3cret": {Subject: "ops", Roles: []string{"USER"}, Scopes: []string{"read:pii"}}}
+	srv := grpcapi.NewServer(opts)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return aggregatorv1.NewAggregatorServiceClient(conn)
+}
+
+func newUpstreamAggregator(t *testing.T) *aggregator.Aggregator {
+	upstream := test.StartFakeUpstream(t)
+	return aggregator.NewAggregator(
+		&fetcher.HTTPUserFetcher{Client: http.DefaultClient, BaseURL: upstream.UsersURL()},
+		&fetcher.HTTPPostsFetcher{Client: http.DefaultClient, BaseURL: upstream.PostsURL()},
+		2*time.Second,
+	)
+}
+
+func Test_GetUserSummary_Success(t *testing.T) {
+	assert := assert.New(t)
+	client := newClient(t, newUpstreamAggregator(t))
+
+	res, err := client.GetUserSummary(context.Background(), &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Nil(err)
+	assert.Equal("Leanne Graham", res.GetSummary().GetName())
+	assert.Equal(int32(10), res.GetSummary().GetPostCount())
+	assert.Nil(res.GetSummary().Email, "email needs the read:pii scope")
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	res, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Nil(err)
+	assert.Equal("Sincere@april.biz", res.GetSummary().GetEmail())
+}
+
+func Test_GetUserSummary_ErrorCodes(t *testing.T) {
+	assert := assert.New(t)
+	client := newClient(t, newUpstreamAggregator(t))
+
+	_, err := client.GetUserSummary(context.Background(), &aggregatorv1.GetUserSummaryRequest{UserId: 42})
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	_, err = client.GetUserSummary(context.Background(), &aggregatorv1.GetUserSummaryRequest{UserId: 0})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong")
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func Test_GetUserSummary_DeadlineExceeded(t *testing.T) {
+	assert := assert.New(t)
+	slow := &mock.MockPostsFetcher{Posts: mock.PostsMock, Delay: time.Second}
+	client := newClient(t, aggregator.NewAggregator(&mock.MockUserFetcher{User: mock.UserMock}, slow, 50*time.Millisecond))
+
+	_, err := client.GetUserSummary(context.Background(), &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Equal(codes.DeadlineExceeded, status.Code(err), "aggregator timeout")
+
+	client = newClient(t, aggregator.NewAggregator(&mock.MockUserFetcher{User: mock.UserMock}, slow, 5*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Equal(codes.DeadlineExceeded, status.Code(err), "call deadline")
+}
+
+func Test_BatchGetUserSummaries(t *testing.T) {
+	assert := assert.New(t)
+	client := newClient(t, newUpstreamAggregator(t))
+
+	stream, err := client.BatchGetUserSummaries(context.Background(), &aggregatorv1.BatchGetUserSummariesRequest{UserIds: []int32{1, 2, 2, 42}})
+	assert.Nil(err)
+
+	names, errs := map[int32]string{}, map[int32]string{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(err) {
+			return
+		}
+		if res.GetError() != nil {
+			errs[res.GetUserId()] = res.GetError().GetCode()
+			continue
+		}
+		names[res.GetUserId()] = res.GetSummary().GetName()
+	}
+	assert.Equal(map[int32]string{1: "Leanne Graham", 2: "Ervin Howell"}, names)
+	assert.Equal(map[int32]string{42: "NOT_FOUND"}, errs)
+}
+
+func Test_BatchGetUserSummaries_TooManyIDs(t *testing.T) {
+	assert := assert.New(t)
+	client := newClient(t, newUpstreamAggregator(t))
+
+	ids := make([]int32, grpcapi.MaxBatchSize+1)
+	for i := range ids {
+		ids[i] = int32(i + 1)
+	}
+	stream, err := client.BatchGetUserSummaries(context.Background(), &aggregatorv1.BatchGetUserSummariesRequest{UserIds: ids})
+	assert.Nil(err)
+	_, err = stream.Recv()
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func Test_RateLimit_SharesHTTPBuckets(t *testing.T) {
+	assert := assert.New(t)
+	limiter, err := middleware.NewRateLimiter("api_key", map[string]ratelimit.Limit{middleware.DefaultTier: {Rate: 0.01, Burst: 2}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := aggregator.NewAggregator(&mock.MockUserFetcher{User: mock.UserMock}, &mock.MockPostsFetcher{Posts: mock.PostsMock}, time.Second)
+	client := newClientWith(t, grpcapi.Options{Aggregator: func() *aggregator.Aggregator { return agg }, Limiter: limiter})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Nil(err)
+	stream, err := client.BatchGetUserSummaries(ctx, &aggregatorv1.BatchGetUserSummariesRequest{UserIds: []int32{1}})
+	assert.Nil(err)
+	_, err = stream.Recv()
+	assert.Nil(err)
+
+	var header metadata.MD
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1}, grpc.Header(&header))
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(header.Get("retry-after"))
+
+	stream, err = client.BatchGetUserSummaries(ctx, &aggregatorv1.BatchGetUserSummariesRequest{UserIds: []int32{1}})
+	assert.Nil(err)
+	_, err = stream.Recv()
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "ops"}))
+	res, _, _, _ := limiter.Take(req.Context(), req.Header.Get, req.RemoteAddr)
+	assert.False(res.Allowed, "the gRPC calls drained the bucket of the HTTP endpoints")
+
+	_, err = client.GetUserSummary(context.Background(), &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Nil(err, "anonymous calls are keyed by address")
+}
+
+func Test_RateLimit_ThrottlesUnknownCredentials(t *testing.T) {
+	assert := assert.New(t)
+	limiter, err := middleware.NewRateLimiter("api_key", map[string]ratelimit.Limit{middleware.DefaultTier: {Rate: 0.01, Burst: 1}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := aggregator.NewAggregator(&mock.MockUserFetcher{User: mock.UserMock}, &mock.MockPostsFetcher{Posts: mock.PostsMock}, time.Second)
+	client := newClientWith(t, grpcapi.Options{Aggregator: func() *aggregator.Aggregator { return agg }, Limiter: limiter})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong")
+
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.GetUserSummary(ctx, &aggregatorv1.GetUserSummaryRequest{UserId: 1})
+	assert.Equal(codes.ResourceExhausted, status.Code(err), "unknown credentials are limited by address")
+}
@@ -0,0 +1,172 @@
+package grpcapi
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/ratelimit"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// unaryLoggingAndRecovery logs each call like the HTTP logging middleware and
+// turns panics into INTERNAL errors.
+func unaryLoggingAndRecovery(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.Log.Error("panic recovered", "error", rec, "method", info.FullMethod)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+		logCall(ctx, info.FullMethod, err, start)
+	}()
+	return handler(ctx, req)
+}
+
+func streamLoggingAndRecovery(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.Log.Error("panic recovered", "error", rec, "method", info.FullMethod)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+		logCall(ss.Context(), info.FullMethod, err, start)
+	}()
+	return handler(srv, ss)
+}
+
+func logCall(ctx context.Context, method string, err error, start time.Time) {
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	logger.Log.Info("grpc request",
+		"method", method,
+		"code", status.Code(err).String(),
+		"remote_addr", remote,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// unaryAuth resolves the call principal from the "authorization: Bearer"
+// or "x-api-key" metadata, like middleware.AuthMiddleware: calls without
+// credentials continue as anonymous, unknown credentials are rejected, once
+// throttled per IP in limiter, if any.
+func unaryAuth(tokens auth.Tokens, limiter *middleware.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, tokens, limiter, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(tokens auth.Tokens, limiter *middleware.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), tokens, limiter, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, tokens auth.Tokens, limiter *middleware.RateLimiter, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := ""
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+		token = strings.TrimSpace(token)
+	} else if v := md.Get("x-api-key"); len(v) > 0 {
+		token = v[0]
+	}
+	if token == "" {
+		return ctx, nil
+	}
+	principal, ok := tokens[token]
+	if !ok {
+		if limiter != nil {
+			res, key, enabled := limiter.TakeUnauthenticated(remoteAddr(ctx))
+			if enabled && !res.Allowed {
+				return nil, exhausted(ctx, res, key, middleware.DefaultTier, method)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// unaryRateLimit takes a token from the bucket of the caller in limiter, the
+// one middleware.RateLimitMiddleware uses, rejecting calls over the limit with
+// RESOURCE_EXHAUSTED. It must run after unaryAuth, which sets the principal.
+func unaryRateLimit(limiter *middleware.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := rateLimit(ctx, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamRateLimit(limiter *middleware.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := rateLimit(ss.Context(), limiter, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func rateLimit(ctx context.Context, limiter *middleware.RateLimiter, method string) error {
+	if limiter == nil {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := func(name string) string {
+		if v := md.Get(name); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	res, key, tier, enabled := limiter.Take(ctx, header, remoteAddr(ctx))
+	if !enabled || res.Allowed {
+		return nil
+	}
+	return exhausted(ctx, res, key, tier, method)
+}
+
+// exhausted rejects a call over the limit with RESOURCE_EXHAUSTED and its
+// retry-after header.
+func exhausted(ctx context.Context, res ratelimit.Result, key, tier, method string) error {
+	logger.Log.Info("rate limit exceeded", "key", key, "tier", tier, "method", method)
+	retryAfter := strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+	return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+}
+
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// contextStream overrides the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
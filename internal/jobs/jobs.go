@@ -0,0 +1,474 @@
+// Package jobs aggregates batches of user summaries in the background, for
+// batches too large to finish within a request. Jobs are polled by id until
+// they finish, and are kept for a TTL afterwards.
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "QUEUED"
+	StatusRunning   Status = "RUNNING"
+	StatusCompleted Status = "COMPLETED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+var (
+	ErrQueueFull = errors.New("too many queued jobs, retry later")
+	ErrNotFound  = errors.New("job not found")
+)
+
+// Result is the summary of a user of a job.
+type Result struct {
+	UserID    int    `json:"userId"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	PostCount int    `json:"postCount"`
+}
+
+// Error is a user whose summary failed.
+type Error struct {
+	UserID  int    `json:"userId"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Job is a batch of summaries. Results and Errors are sorted by user ID and
+// grow as the job runs; the users in neither are still to be processed.
+type Job struct {
+	ID string `json:"id"`
+	// Owner is the subject of the principal that started the job, empty for
+	// anonymous callers.
+	Owner      string    `json:"owner,omitempty"`
+	Status     Status    `json:"status"`
+	UserIDs    []int     `json:"userIds"`
+	Results    []Result  `json:"results"`
+	Errors     []Error   `json:"errors"`
+	CreatedAt  time.Time `json:"createdAt"`
+	StartedAt  time.Time `json:"startedAt,omitzero"`
+	FinishedAt time.Time `json:"finishedAt,omitzero"`
+}
+
+// Processed is the number of users done, successfully or not.
+func (j *Job) Processed() int {
+	return len(j.Results) + len(j.Errors)
+}
+
+// Progress is the fraction of users processed, from 0 to 1.
+func (j *Job) Progress() float64 {
+	if len(j.UserIDs) == 0 {
+		return 1
+	}
+	return float64(j.Processed()) / float64(len(j.UserIDs))
+}
+
+// Finished reports whether the job completed or was cancelled.
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusCancelled
+}
+
+// VisibleTo reports whether p may see and cancel the job: anonymous jobs are
+// visible to everyone, the others to their owner and to admins.
+func (j *Job) VisibleTo(p *auth.Principal) bool {
+	if j.Owner == "" {
+		return true
+	}
+	return p != nil && (p.Subject == j.Owner || p.HasRole("ADMIN"))
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	c.UserIDs = slices.Clone(j.UserIDs)
+	c.Results = slices.Clone(j.Results)
+	c.Errors = slices.Clone(j.Errors)
+	return &c
+}
+
+// Persister stores encoded jobs across restarts; store.Store implements it.
+type Persister interface {
+	SaveJob(id string, data []byte) error
+	DeleteJob(id string) error
+	Jobs() ([][]byte, error)
+}
+
+// Options configures a Manager.
+type Options struct {
+	// Aggregator returns the aggregator a job uses when it starts.
+	Aggregator func() *aggregator.Aggregator
+	// Workers jobs run at once, each aggregating up to Concurrency summaries
+	// at a time.
+	Workers     int
+	Concurrency int
+	// MaxUsers caps the users of a job and MaxQueued the jobs waiting for a
+	// worker.
+	MaxUsers  int
+	MaxQueued int
+	// TTL is how long finished jobs are kept.
+	TTL time.Duration
+	// Persister, if set, keeps the jobs across restarts: unfinished jobs
+	// resume where they stopped.
+	Persister Persister
+}
+
+// saveEvery throttles the saves of a running job.
+const saveEvery = time.Second
+
+// Manager queues and runs jobs. It is safe for concurrent use.
+type Manager struct {
+	opts  Options
+	queue chan string
+
+	mu   sync.Mutex
+	jobs map[string]*entry
+
+	// saveMu serializes the writes to the persister, made outside mu.
+	saveMu sync.Mutex
+}
+
+type entry struct {
+	job      *Job
+	cancel   context.CancelFunc
+	lastSave time.Time
+	// version counts the states taken by snapshot, under Manager.mu; saved is
+	// the last one written and deleted tells the job was evicted, under
+	// Manager.saveMu.
+	version int
+	saved   int
+	deleted bool
+}
+
+// NewManager returns a manager for opts, with the jobs of opts.Persister
+// loaded. Call Run to start processing them.
+func NewManager(opts Options) (*Manager, error) {
+	opts.Workers = max(opts.Workers, 1)
+	opts.Concurrency = max(opts.Concurrency, 1)
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = 100
+	}
+	m := &Manager{opts: opts, jobs: map[string]*entry{}}
+
+	var pending []*Job
+	if opts.Persister != nil {
+		stored, err := opts.Persister.Jobs()
+		if err != nil {
+			return nil, fmt.Errorf("loading jobs: %w", err)
+		}
+		for _, data := range stored {
+			job := &Job{}
+			if err := json.Unmarshal(data, job); err != nil {
+				return nil, fmt.Errorf("loading jobs: %w", err)
+			}
+			if !job.Finished() {
+				// interrupted by a restart: resume with the users left
+				job.Status = StatusQueued
+				pending = append(pending, job)
+			}
+			m.jobs[job.ID] = &entry{job: job}
+		}
+	}
+	slices.SortFunc(pending, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	m.queue = make(chan string, max(opts.MaxQueued, len(pending)))
+	for _, job := range pending {
+		m.queue <- job.ID
+	}
+	if len(pending) > 0 {
+		logger.Log.Info("resuming jobs", "count", len(pending))
+	}
+	return m, nil
+}
+
+// Run processes the queued jobs and evicts the expired ones until ctx is done,
+// then waits for the running jobs to stop. Jobs stopped this way are queued
+// again, to resume after a restart when persisted.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range m.opts.Workers {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-m.queue:
+					m.process(ctx, id)
+				}
+			}
+		})
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			m.Evict()
+		}
+	}
+}
+
+// Submit queues a job for userIDs, deduplicated and sorted, started by owner.
+func (m *Manager) Submit(userIDs []int, owner string) (*Job, error) {
+	if len(userIDs) == 0 {
+		return nil, errors.New("no user IDs")
+	}
+	ids := slices.Clone(userIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if ids[0] <= 0 {
+		return nil, fmt.Errorf("invalid user ID %d", ids[0])
+	}
+	if m.opts.MaxUsers > 0 && len(ids) > m.opts.MaxUsers {
+		return nil, fmt.Errorf("too many users: %d, at most %d per job", len(ids), m.opts.MaxUsers)
+	}
+
+	job := &Job{
+		ID:        rand.Text(),
+		Owner:     owner,
+		Status:    StatusQueued,
+		UserIDs:   ids,
+		Results:   []Result{},
+		Errors:    []Error{},
+		CreatedAt: time.Now().UTC(),
+	}
+	m.mu.Lock()
+	select {
+	case m.queue <- job.ID:
+	default:
+		m.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	e := &entry{job: job}
+	m.jobs[job.ID] = e
+	state := m.snapshot(e)
+	queued := job.clone()
+	m.mu.Unlock()
+
+	m.persist(state)
+	logger.Log.Info("job queued", "job", job.ID, "users", len(ids))
+	return queued, nil
+}
+
+// Get returns a copy of the job id.
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return e.job.clone(), true
+}
+
+// Cancel cancels the job id, keeping the results so far. Cancelling a
+// finished job does nothing.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	e, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	var state *jobState
+	if !e.job.Finished() {
+		e.job.Status, e.job.FinishedAt = StatusCancelled, time.Now().UTC()
+		if e.cancel != nil {
+			e.cancel()
+		}
+		state = m.snapshot(e)
+		logger.Log.Info("job cancelled", "job", id, "processed", e.job.Processed())
+	}
+	job := e.job.clone()
+	m.mu.Unlock()
+
+	m.persist(state)
+	return job, nil
+}
+
+// Evict removes the jobs finished more than the TTL ago.
+func (m *Manager) Evict() {
+	m.mu.Lock()
+	var evicted []*entry
+	for id, e := range m.jobs {
+		if e.job.Finished() && time.Since(e.job.FinishedAt) > m.opts.TTL {
+			delete(m.jobs, id)
+			evicted = append(evicted, e)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.opts.Persister == nil {
+		return
+	}
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	for _, e := range evicted {
+		e.deleted = true
+		if err := m.opts.Persister.DeleteJob(e.job.ID); err != nil {
+			logger.Log.Error("deleting job failed", "job", e.job.ID, "error", err)
+		}
+	}
+}
+
+// ExpiresAt is when a finished job is evicted.
+func (m *Manager) ExpiresAt(job *Job) time.Time {
+	return job.FinishedAt.Add(m.opts.TTL)
+}
+
+func (m *Manager) process(ctx context.Context, id string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.mu.Lock()
+	e, ok := m.jobs[id]
+	if !ok || e.job.Status != StatusQueued {
+		// cancelled while queued
+		m.mu.Unlock()
+		return
+	}
+	e.job.Status, e.cancel = StatusRunning, cancel
+	if e.job.StartedAt.IsZero() {
+		e.job.StartedAt = time.Now().UTC()
+	}
+	done := make(map[int]bool, e.job.Processed())
+	for _, r := range e.job.Results {
+		done[r.UserID] = true
+	}
+	for _, f := range e.job.Errors {
+		done[f.UserID] = true
+	}
+	var todo []int
+	for _, userID := range e.job.UserIDs {
+		if !done[userID] {
+			todo = append(todo, userID)
+		}
+	}
+	state := m.snapshot(e)
+	m.mu.Unlock()
+	m.persist(state)
+
+	logger.Log.Info("job started", "job", id, "users", len(todo))
+	agg := m.opts.Aggregator()
+	sem := make(chan struct{}, m.opts.Concurrency)
+	var wg sync.WaitGroup
+loop:
+	for _, userID := range todo {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			summary, err := agg.GetUserSummary(ctx, userID)
+			if ctx.Err() != nil {
+				// cancelled or shutting down: the user stays unprocessed
+				return
+			}
+			m.record(e, userID, summary, err)
+		})
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	e.cancel = nil
+	switch {
+	case e.job.Status == StatusCancelled:
+	case ctx.Err() != nil:
+		// shutting down: queue it again, for a restart to resume it
+		e.job.Status = StatusQueued
+	default:
+		e.job.Status, e.job.FinishedAt = StatusCompleted, time.Now().UTC()
+		logger.Log.Info("job completed", "job", id, "succeeded", len(e.job.Results), "failed", len(e.job.Errors),
+			"elapsed_ms", e.job.FinishedAt.Sub(e.job.StartedAt).Milliseconds())
+	}
+	state = m.snapshot(e)
+	m.mu.Unlock()
+	m.persist(state)
+}
+
+func (m *Manager) record(e *entry, userID int, summary *aggregator.UserSummary, err error) {
+	m.mu.Lock()
+	if e.job.Status != StatusRunning {
+		m.mu.Unlock()
+		return
+	}
+	if err != nil {
+		e.job.Errors = insertSorted(e.job.Errors, Error{UserID: userID, Code: apierror.Code(err), Message: err.Error()},
+			func(f Error) int { return f.UserID })
+	} else {
+		e.job.Results = insertSorted(e.job.Results, Result{UserID: userID, Name: summary.Name, Email: summary.Email, PostCount: summary.PostCount},
+			func(r Result) int { return r.UserID })
+	}
+	var state *jobState
+	if time.Since(e.lastSave) >= saveEvery {
+		state = m.snapshot(e)
+	}
+	m.mu.Unlock()
+	m.persist(state)
+}
+
+// jobState is a state of a job to persist, taken under Manager.mu by
+// snapshot and written outside it by persist.
+type jobState struct {
+	e       *entry
+	version int
+	data    []byte
+}
+
+// snapshot encodes the job of e, or returns nil without a persister. Callers
+// hold m.mu.
+func (m *Manager) snapshot(e *entry) *jobState {
+	if m.opts.Persister == nil {
+		return nil
+	}
+	e.lastSave = time.Now()
+	e.version++
+	data, err := json.Marshal(e.job)
+	if err != nil {
+		logger.Log.Error("saving job failed", "job", e.job.ID, "error", err)
+		return nil
+	}
+	return &jobState{e: e, version: e.version, data: data}
+}
+
+// persist writes state, unless a later state of the job was already written
+// or the job was evicted, so concurrent saves never go back in time.
+func (m *Manager) persist(state *jobState) {
+	if state == nil {
+		return
+	}
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	e := state.e
+	if e.deleted || state.version <= e.saved {
+		return
+	}
+	if err := m.opts.Persister.SaveJob(e.job.ID, state.data); err != nil {
+		logger.Log.Error("saving job failed", "job", e.job.ID, "error", err)
+		return
+	}
+	e.saved = state.version
+}
+
+func insertSorted[T any](s []T, v T, key func(T) int) []T {
+	i, _ := slices.BinarySearchFunc(s, key(v), func(e T, k int) int { return cmp.Compare(key(e), k) })
+	return slices.Insert(s, i, v)
+}
@@ -0,0 +1,250 @@
+package jobs_test
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/store"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func upstreamAggregator(t *testing.T) *aggregator.Aggregator {
+	upstream := test.StartFakeUpstream(t)
+	return aggregator.NewAggregator(
+		&fetcher.HTTPUserFetcher{Client: http.DefaultClient, BaseURL: upstream.UsersURL()},
+		&fetcher.HTTPPostsFetcher{Client: http.DefaultClient, BaseURL: upstream.PostsURL()},
+		2*time.Second,
+	)
+}
+
+// blockingAggregator never finishes a summary before its context is done.
+func blockingAggregator() *aggregator.Aggregator {
+	return aggregator.NewAggregator(
+		&mock.MockUserFetcher{User: mock.UserMock},
+		&mock.MockPostsFetcher{Posts: mock.PostsMock, Delay: time.Hour},
+		time.Hour,
+	)
+}
+
+func startManager(t *testing.T, opts jobs.Options) (*jobs.Manager, context.CancelFunc) {
+	m, err := jobs.NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return m, stop
+}
+
+func waitFor(t *testing.T, m *jobs.Manager, id string, cond func(*jobs.Job) bool) *jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := m.Get(id)
+		if ok && cond(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s: condition not met, last state %+v", id, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_Manager_CompletesWithResultsAndErrors(t *testing.T) {
+	assert := assert.New(t)
+	agg := upstreamAggregator(t)
+	m, _ := startManager(t, jobs.Options{
+		Aggregator:  func() *aggregator.Aggregator { return agg },
+		Concurrency: 3,
+		TTL:         time.Hour,
+	})
+
+	job, err := m.Submit([]int{3, 1, 42, 2, 1}, "ops")
+	assert.Nil(err)
+	assert.Equal(jobs.StatusQueued, job.Status)
+	assert.Equal([]int{1, 2, 3, 42}, job.UserIDs)
+	assert.Equal("ops", job.Owner)
+
+	job = waitFor(t, m, job.ID, (*jobs.Job).Finished)
+	assert.Equal(jobs.StatusCompleted, job.Status)
+	assert.Equal(4, job.Processed())
+	assert.Equal(1.0, job.Progress())
+	if assert.Len(job.Results, 3) {
+		assert.Equal(jobs.Result{UserID: 1, Name: "Leanne Graham", Email: "Sincere@april.biz", PostCount: 10}, job.Results[0])
+		assert.Equal(3, job.Results[2].UserID)
+	}
+	if assert.Len(job.Errors, 1) {
+		assert.Equal(42, job.Errors[0].UserID)
+		assert.Equal(apierror.CodeNotFound, job.Errors[0].Code)
+	}
+	assert.False(job.StartedAt.IsZero())
+	assert.Equal(job.FinishedAt.Add(time.Hour), m.ExpiresAt(job))
+}
+
+func Test_Manager_SubmitValidates(t *testing.T) {
+	assert := assert.New(t)
+	m, err := jobs.NewManager(jobs.Options{MaxUsers: 2, MaxQueued: 1})
+	assert.Nil(err)
+
+	for _, ids := range [][]int{nil, {0, 1}, {1, 2, 3}} {
+		_, err := m.Submit(ids, "")
+		assert.NotNil(err, ids)
+	}
+
+	// nothing runs the queue
+	_, err = m.Submit([]int{1}, "")
+	assert.Nil(err)
+	_, err = m.Submit([]int{2}, "")
+	assert.ErrorIs(err, jobs.ErrQueueFull)
+}
+
+func Test_Manager_CancelKeepsPartialResults(t *testing.T) {
+	assert := assert.New(t)
+	agg := blockingAggregator()
+	m, _ := startManager(t, jobs.Options{
+		Aggregator:  func() *aggregator.Aggregator { return agg },
+		Concurrency: 2,
+		TTL:         time.Hour,
+	})
+
+	job, err := m.Submit([]int{1, 2, 3}, "")
+	assert.Nil(err)
+	waitFor(t, m, job.ID, func(j *jobs.Job) bool { return j.Status == jobs.StatusRunning })
+
+	job, err = m.Cancel(job.ID)
+	assert.Nil(err)
+	assert.Equal(jobs.StatusCancelled, job.Status)
+	assert.False(job.FinishedAt.IsZero())
+	assert.Equal(0, job.Processed(), "interrupted summaries are not errors")
+
+	// cancelling again does nothing
+	again, err := m.Cancel(job.ID)
+	assert.Nil(err)
+	assert.Equal(job.FinishedAt, again.FinishedAt)
+
+	_, err = m.Cancel("nope")
+	assert.ErrorIs(err, jobs.ErrNotFound)
+}
+
+func Test_Manager_EvictsExpiredJobs(t *testing.T) {
+	assert := assert.New(t)
+	m, err := jobs.NewManager(jobs.Options{TTL: time.Nanosecond})
+	assert.Nil(err)
+
+	queued, _ := m.Submit([]int{1}, "")
+	cancelled, _ := m.Submit([]int{2}, "")
+	_, err = m.Cancel(cancelled.ID)
+	assert.Nil(err)
+	time.Sleep(time.Millisecond)
+
+	m.Evict()
+	_, ok := m.Get(queued.ID)
+	assert.True(ok, "unfinished jobs are kept")
+	_, ok = m.Get(cancelled.ID)
+	assert.False(ok)
+}
+
+func Test_Manager_ResumesPersistedJobs(t *testing.T) {
+	assert := assert.New(t)
+	st, err := store.Open(filepath.Join(t.TempDir(), "store.db"))
+	assert.Nil(err)
+	defer st.Close()
+
+	blocking := blockingAggregator()
+	m, stop := startManager(t, jobs.Options{
+		Aggregator: func() *aggregator.Aggregator { return blocking },
+		TTL:        time.Hour,
+		Persister:  st,
+	})
+	job, err := m.Submit([]int{1, 2}, "ops")
+	assert.Nil(err)
+	waitFor(t, m, job.ID, func(j *jobs.Job) bool { return j.Status == jobs.StatusRunning })
+	stop()
+
+	agg := upstreamAggregator(t)
+	m, _ = startManager(t, jobs.Options{
+		Aggregator: func() *aggregator.Aggregator { return agg },
+		TTL:        time.Hour,
+		Persister:  st,
+	})
+	resumed := waitFor(t, m, job.ID, (*jobs.Job).Finished)
+	assert.Equal(jobs.StatusCompleted, resumed.Status)
+	assert.Equal(job.CreatedAt, resumed.CreatedAt)
+	assert.Len(resumed.Results, 2)
+}
+
+// slowPersister blocks its saves until release is closed.
+type slowPersister struct {
+	saving  chan string
+	release chan struct{}
+}
+
+func (p slowPersister) SaveJob(id string, data []byte) error {
+	p.saving <- id
+	<-p.release
+	return nil
+}
+
+func (p slowPersister) DeleteJob(id string) error { return nil }
+
+func (p slowPersister) Jobs() ([][]byte, error) { return nil, nil }
+
+func Test_Manager_SavesOutsideTheLock(t *testing.T) {
+	assert := assert.New(t)
+	persister := slowPersister{saving: make(chan string, 1), release: make(chan struct{})}
+	m, err := jobs.NewManager(jobs.Options{TTL: time.Hour, Persister: persister})
+	assert.Nil(err)
+
+	go m.Submit([]int{1}, "")
+	id := <-persister.saving
+
+	got := make(chan bool)
+	go func() {
+		_, ok := m.Get(id)
+		got <- ok
+	}()
+	select {
+	case ok := <-got:
+		assert.True(ok, "the job is known while it's being saved")
+	case <-time.After(time.Second):
+		t.Error("Get waited for the save")
+	}
+	close(persister.release)
+}
+
+func Test_Job_VisibleTo(t *testing.T) {
+	assert := assert.New(t)
+
+	anonymous := &jobs.Job{}
+	assert.True(anonymous.VisibleTo(nil))
+
+	owned := &jobs.Job{Owner: "ops"}
+	assert.False(owned.VisibleTo(nil))
+	assert.True(owned.VisibleTo(&auth.Principal{Subject: "ops"}))
+	assert.False(owned.VisibleTo(&auth.Principal{Subject: "other", Roles: []string{"USER"}}))
+	assert.True(owned.VisibleTo(&auth.Principal{Subject: "other", Roles: []string{"ADMIN"}}))
+}
@@ -0,0 +1,268 @@
+// Package loadtest drives a weighted mix of GraphQL operations against a
+// server and reports latency percentiles, errors by code and throughput.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operation is a GraphQL request of the mix, picked with probability
+// proportional to Weight.
+type Operation struct {
+	Name          string         `yaml:"name" json:"name"`
+	Query         string         `yaml:"query" json:"query"`
+	OperationName string         `yaml:"operationName" json:"operationName,omitempty"`
+	Variables     map[string]any `yaml:"variables" json:"variables,omitempty"`
+	Weight        int            `yaml:"weight" json:"weight"`
+}
+
+// LoadMix reads operations from a YAML (or JSON) file:
+//
+//   - name: summary
+//     weight: 3
+//     query: "query($id: Int!) { userSummary(userId: $id) { name postCount } }"
+//     variables: {id: 1}
+func LoadMix(path string) ([]Operation, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading operation mix: %w", err)
+	}
+	var ops []Operation
+	if err := yaml.Unmarshal(b, &ops); err != nil {
+		return nil, fmt.Errorf("decoding operation mix %s: %w", path, err)
+	}
+	return ops, nil
+}
+
+// Options configures a run. Rate > 0 sends requests at that fixed rate (an
+// open model, Concurrency capping the requests in flight); otherwise
+// Concurrency workers send requests back to back.
+type Options struct {
+	URL         string
+	Mix         []Operation
+	Headers     http.Header
+	Rate        float64
+	Concurrency int
+	Warmup      time.Duration
+	Duration    time.Duration
+	Timeout     time.Duration
+
+	// Client defaults to an http.Client with Timeout.
+	Client *http.Client
+}
+
+func (o *Options) validate() error {
+	var errs []error
+	if o.URL == "" {
+		errs = append(errs, errors.New("url is required"))
+	}
+	if len(o.Mix) == 0 {
+		errs = append(errs, errors.New("the operation mix is empty"))
+	}
+	for i, op := range o.Mix {
+		if op.Query == "" {
+			errs = append(errs, fmt.Errorf("operation %d (%s): query is required", i, op.Name))
+		}
+		if op.Weight < 0 {
+			errs = append(errs, fmt.Errorf("operation %d (%s): weight must not be negative", i, op.Name))
+		}
+	}
+	if o.Rate < 0 {
+		errs = append(errs, fmt.Errorf("rate must not be negative, got %v", o.Rate))
+	}
+	if o.Concurrency <= 0 {
+		errs = append(errs, fmt.Errorf("concurrency must be positive, got %d", o.Concurrency))
+	}
+	if o.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("duration must be positive, got %s", o.Duration))
+	}
+	if o.Warmup < 0 {
+		errs = append(errs, fmt.Errorf("warmup must not be negative, got %s", o.Warmup))
+	}
+	return errors.Join(errs...)
+}
+
+// Error codes recorded for failures without a GraphQL extensions.code.
+const (
+	CodeTransport = "TRANSPORT_ERROR"
+	CodeTimeout   = "TIMEOUT"
+	CodeDecode    = "INVALID_RESPONSE"
+	CodeUnknown   = "UNKNOWN"
+)
+
+// Run sends requests for opts.Warmup plus opts.Duration, or until ctx is done,
+// and reports on the ones sent after the warmup.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Concurrency == 0 {
+		opts.Concurrency = 1
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	pick := picker(opts.Mix)
+
+	results := newTally()
+	measureFrom := time.Now().Add(opts.Warmup)
+	ctx, cancel := context.WithDeadline(ctx, measureFrom.Add(opts.Duration))
+	defer cancel()
+
+	send := func() {
+		op := pick()
+		sent := time.Now()
+		codes := do(ctx, opts, op)
+		if sent.Before(measureFrom) || (ctx.Err() != nil && slices.Contains(codes, CodeTimeout)) {
+			// warmup requests, and the ones cut by the end of the run, are not measured
+			return
+		}
+		results.add(op.Name, time.Since(sent), codes)
+	}
+
+	var wg sync.WaitGroup
+	if opts.Rate > 0 {
+		sem := make(chan struct{}, opts.Concurrency)
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		dropped := 0
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-ticker.C:
+				select {
+				case sem <- struct{}{}:
+				default:
+					// every slot busy: the target can't keep up with the rate
+					if time.Now().After(measureFrom) {
+						dropped++
+					}
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					send()
+				}()
+			}
+		}
+		wg.Wait()
+		report := newReport(opts, results, time.Since(measureFrom))
+		report.Dropped = dropped
+		return report, nil
+	}
+
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				send()
+			}
+		}()
+	}
+	wg.Wait()
+	return newReport(opts, results, time.Since(measureFrom)), nil
+}
+
+// picker returns a function choosing operations by weight; a mix whose
+// weights are all zero is picked uniformly.
+func picker(mix []Operation) func() Operation {
+	total := 0
+	for _, op := range mix {
+		total += op.Weight
+	}
+	if total == 0 {
+		return func() Operation { return mix[rand.IntN(len(mix))] }
+	}
+	return func() Operation {
+		n := rand.IntN(total)
+		for _, op := range mix {
+			if n < op.Weight {
+				return op
+			}
+			n -= op.Weight
+		}
+		return mix[len(mix)-1]
+	}
+}
+
+// do sends op and returns the error codes of the response, none on success.
+func do(ctx context.Context, opts Options, op Operation) []string {
+	body, err := json.Marshal(map[string]any{
+		"query":         op.Query,
+		"operationName": op.OperationName,
+		"variables":     op.Variables,
+	})
+	if err != nil {
+		return []string{CodeUnknown}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return []string{CodeTransport}
+	}
+	for k, v := range opts.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := opts.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || isTimeout(err) {
+			return []string{CodeTimeout}
+		}
+		return []string{CodeTransport}
+	}
+	defer res.Body.Close()
+
+	var gqlRes struct {
+		Errors []struct {
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return []string{CodeTransport}
+	}
+	if err := json.Unmarshal(raw, &gqlRes); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return []string{fmt.Sprintf("HTTP_%d", res.StatusCode)}
+		}
+		return []string{CodeDecode}
+	}
+
+	var codes []string
+	for _, e := range gqlRes.Errors {
+		code, _ := e.Extensions["code"].(string)
+		if code == "" {
+			code = CodeUnknown
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 && res.StatusCode != http.StatusOK {
+		codes = append(codes, fmt.Sprintf("HTTP_%d", res.StatusCode))
+	}
+	return codes
+}
+
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
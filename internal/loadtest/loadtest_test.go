@@ -0,0 +1,168 @@
+package loadtest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/loadtest"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTarget answers "ok" queries with data, "forbidden" ones with a FORBIDDEN
+// error and anything else with a 503.
+func newTarget(t *testing.T, requests *atomic.Int64) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var body struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case strings.Contains(body.Query, "ok") && body.Variables["id"] == 1.0:
+			w.Write([]byte(`{"data":{"ok":true}}`))
+		case strings.Contains(body.Query, "forbidden"):
+			w.Write([]byte(`{"errors":[{"message":"no","extensions":{"code":"FORBIDDEN"}}],"data":null}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("unavailable"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func Test_Run_ReportsErrorsByCode(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int64
+	url := newTarget(t, &requests)
+
+	report, err := loadtest.Run(context.Background(), loadtest.Options{
+		URL: url,
+		Mix: []loadtest.Operation{
+			{Name: "ok", Query: "{ ok }", Variables: map[string]any{"id": 1}, Weight: 2},
+			{Name: "forbidden", Query: "{ forbidden }", Weight: 1},
+			{Name: "down", Query: "{ down }", Weight: 1},
+		},
+		Concurrency: 4,
+		Warmup:      50 * time.Millisecond,
+		Duration:    200 * time.Millisecond,
+	})
+
+	assert.Nil(err)
+	assert.Greater(report.Requests, 20)
+	assert.Less(int64(report.Requests), requests.Load(), "warmup requests are not reported")
+	assert.Equal(report.Requests, report.Succeeded+report.Failed)
+	assert.Equal(report.Operations["ok"].Requests, report.Succeeded)
+	assert.Equal(report.Operations["forbidden"].Requests, report.Errors["FORBIDDEN"])
+	assert.Equal(report.Operations["down"].Requests, report.Errors["HTTP_503"])
+	assert.InDelta(0.5, report.ErrorRate, 0.2)
+	assert.Greater(report.Throughput, 0.0)
+	assert.LessOrEqual(report.Latency.Min, report.Latency.P50)
+	assert.LessOrEqual(report.Latency.P50, report.Latency.P99)
+	assert.LessOrEqual(report.Latency.P99, report.Latency.Max)
+	assert.Equal(report.Requests, report.Operations["ok"].Requests+report.Operations["forbidden"].Requests+report.Operations["down"].Requests)
+}
+
+func Test_Run_FixedRate(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int64
+	url := newTarget(t, &requests)
+
+	report, err := loadtest.Run(context.Background(), loadtest.Options{
+		URL:         url,
+		Mix:         []loadtest.Operation{{Name: "ok", Query: "{ ok }", Variables: map[string]any{"id": 1}}},
+		Rate:        100,
+		Concurrency: 10,
+		Duration:    300 * time.Millisecond,
+	})
+
+	assert.Nil(err)
+	assert.InDelta(30, report.Requests, 8)
+	assert.Equal(0, report.Failed)
+}
+
+func Test_Run_TransportErrorsAndTimeouts(t *testing.T) {
+	assert := assert.New(t)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	report, err := loadtest.Run(context.Background(), loadtest.Options{
+		URL:         slow.URL,
+		Mix:         []loadtest.Operation{{Query: "{ a }"}},
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+		Timeout:     20 * time.Millisecond,
+	})
+	assert.Nil(err)
+	assert.Equal(report.Requests, report.Errors[loadtest.CodeTimeout])
+
+	report, err = loadtest.Run(context.Background(), loadtest.Options{
+		URL:         "http://127.0.0.1:1/query",
+		Mix:         []loadtest.Operation{{Query: "{ a }"}},
+		Concurrency: 1,
+		Duration:    50 * time.Millisecond,
+	})
+	assert.Nil(err)
+	assert.Equal(report.Requests, report.Errors[loadtest.CodeTransport])
+}
+
+func Test_Run_InvalidOptions(t *testing.T) {
+	_, err := loadtest.Run(context.Background(), loadtest.Options{Mix: []loadtest.Operation{{Weight: -1}}, Rate: -1})
+
+	assert.ErrorContains(t, err, "url is required")
+	assert.ErrorContains(t, err, "query is required")
+	assert.ErrorContains(t, err, "weight must not be negative")
+	assert.ErrorContains(t, err, "rate must not be negative")
+	assert.ErrorContains(t, err, "duration must be positive")
+}
+
+func Test_Report_SaveLoadAndCompare(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int64
+	url := newTarget(t, &requests)
+
+	report, err := loadtest.Run(context.Background(), loadtest.Options{
+		URL:         url,
+		Mix:         []loadtest.Operation{{Name: "ok", Query: "{ ok }", Variables: map[string]any{"id": 1}}},
+		Concurrency: 1,
+		Duration:    50 * time.Millisecond,
+	})
+	assert.Nil(err)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	assert.Nil(report.Save(path))
+	loaded, err := loadtest.LoadReport(path)
+	assert.Nil(err)
+	assert.Equal(report.Requests, loaded.Requests)
+	assert.Equal(report.Latency, loaded.Latency)
+
+	var out bytes.Buffer
+	report.Print(&out)
+	report.PrintComparison(&out, loaded)
+	assert.Contains(out.String(), "throughput:")
+	assert.Contains(out.String(), "p99 (ms)")
+	assert.Contains(out.String(), "+0.0%")
+}
+
+func Test_LoadMix(t *testing.T) {
+	assert := assert.New(t)
+
+	ops, err := loadtest.LoadMix("testdata/mix.yaml")
+
+	assert.Nil(err)
+	assert.Len(ops, 2)
+	assert.Equal("summary", ops[0].Name)
+	assert.Equal(3, ops[0].Weight)
+	assert.Equal(map[string]any{"id": 1}, ops[0].Variables)
+}
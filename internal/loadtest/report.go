@@ -0,0 +1,292 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarizes a run. Latencies are in milliseconds so reports of
+// different runs can be compared as plain JSON.
+type Report struct {
+	Meta       Meta                       `json:"meta"`
+	Requests   int                        `json:"requests"`
+	Succeeded  int                        `json:"succeeded"`
+	Failed     int                        `json:"failed"`
+	Dropped    int                        `json:"dropped"`
+	Throughput float64                    `json:"throughput"`
+	ErrorRate  float64                    `json:"errorRate"`
+	Latency    Latency                    `json:"latency"`
+	Errors     map[string]int             `json:"errors"`
+	Operations map[string]OperationReport `json:"operations"`
+}
+
+// Meta identifies a run: what was tested, how, and from which build.
+type Meta struct {
+	URL         string    `json:"url"`
+	StartedAt   time.Time `json:"startedAt"`
+	Duration    string    `json:"duration"`
+	Warmup      string    `json:"warmup"`
+	Rate        float64   `json:"rate,omitempty"`
+	Concurrency int       `json:"concurrency"`
+	Revision    string    `json:"revision,omitempty"`
+	GoVersion   string    `json:"goVersion,omitempty"`
+}
+
+// OperationReport is the share of a run of one operation of the mix.
+type OperationReport struct {
+	Requests int     `json:"requests"`
+	Failed   int     `json:"failed"`
+	Latency  Latency `json:"latency"`
+}
+
+// Latency holds latency statistics in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func newReport(opts Options, results *tally, measured time.Duration) *Report {
+	measured = max(measured, 0)
+	r := &Report{
+		Meta: Meta{
+			URL:         opts.URL,
+			StartedAt:   time.Now().Add(-measured - opts.Warmup).UTC(),
+			Duration:    measured.Round(time.Millisecond).String(),
+			Warmup:      opts.Warmup.String(),
+			Rate:        opts.Rate,
+			Concurrency: opts.Concurrency,
+		},
+		Errors:     map[string]int{},
+		Operations: map[string]OperationReport{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.Meta.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				r.Meta.Revision = s.Value
+			}
+		}
+	}
+
+	results.mu.Lock()
+	defer results.mu.Unlock()
+	r.Requests, r.Failed = results.all.count, results.failed
+	r.Succeeded = r.Requests - r.Failed
+	maps.Copy(r.Errors, results.errors)
+	r.Latency = results.all.latency()
+	for name, op := range results.ops {
+		r.Operations[name] = OperationReport{Requests: op.latencies.count, Failed: op.failed, Latency: op.latencies.latency()}
+	}
+	if measured > 0 {
+		r.Throughput = float64(r.Requests) / measured.Seconds()
+	}
+	if r.Requests > 0 {
+		r.ErrorRate = float64(r.Failed) / float64(r.Requests)
+	}
+	return r
+}
+
+// tally aggregates the results of a run as they arrive, so its memory
+// doesn't grow with the requests sent.
+type tally struct {
+	mu     sync.Mutex
+	all    histogram
+	failed int
+	errors map[string]int
+	ops    map[string]*opTally
+}
+
+type opTally struct {
+	latencies histogram
+	failed    int
+}
+
+func newTally() *tally {
+	return &tally{errors: map[string]int{}, ops: map[string]*opTally{}}
+}
+
+// add records a request of op, failed when it got error codes.
+func (t *tally) add(op string, latency time.Duration, codes []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.ops[op]
+	if !ok {
+		o = &opTally{}
+		t.ops[op] = o
+	}
+	t.all.add(latency)
+	o.latencies.add(latency)
+	if len(codes) > 0 {
+		t.failed++
+		o.failed++
+		for _, code := range codes {
+			t.errors[code]++
+		}
+	}
+}
+
+// bucketGrowth is the ratio between the bounds of consecutive histogram
+// buckets: percentiles are within 1% of the exact ones.
+const bucketGrowth = 1.01
+
+// histogram counts latencies in log-scaled buckets, bucket i > 0 holding
+// those from bucketGrowth^(i-1) to bucketGrowth^i microseconds, and bucket 0
+// those under a microsecond.
+type histogram struct {
+	buckets  map[int]int
+	count    int
+	total    time.Duration
+	min, max time.Duration
+}
+
+func (h *histogram) add(d time.Duration) {
+	if h.buckets == nil {
+		h.buckets = map[int]int{}
+	}
+	i := 0
+	if d >= time.Microsecond {
+		i = int(math.Log(float64(d/time.Microsecond))/math.Log(bucketGrowth)) + 1
+	}
+	h.buckets[i]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	h.max = max(h.max, d)
+	h.count++
+	h.total += d
+}
+
+func (h *histogram) latency() Latency {
+	if h.count == 0 {
+		return Latency{}
+	}
+	return Latency{
+		Min:  ms(h.min),
+		Mean: ms(h.total / time.Duration(h.count)),
+		P50:  ms(h.percentile(50)),
+		P90:  ms(h.percentile(90)),
+		P95:  ms(h.percentile(95)),
+		P99:  ms(h.percentile(99)),
+		Max:  ms(h.max),
+	}
+}
+
+// percentile uses the nearest-rank method, answering the upper bound of the
+// bucket holding that rank, at most the largest latency.
+func (h *histogram) percentile(p float64) time.Duration {
+	rank := min(max(int(p/100*float64(h.count)+0.5), 1), h.count)
+	seen := 0
+	for _, i := range slices.Sorted(maps.Keys(h.buckets)) {
+		seen += h.buckets[i]
+		if seen >= rank {
+			bound := time.Duration(math.Pow(bucketGrowth, float64(i)) * float64(time.Microsecond))
+			return min(bound, h.max)
+		}
+	}
+	return h.max
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// LoadReport reads a report saved with Save.
+func LoadReport(path string) (*Report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("decoding report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Save writes the report as indented JSON.
+func (r *Report) Save(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Print writes the report as text.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "target:      %s\n", r.Meta.URL)
+	if r.Meta.Rate > 0 {
+		fmt.Fprintf(w, "load:        %.1f req/s, up to %d in flight\n", r.Meta.Rate, r.Meta.Concurrency)
+	} else {
+		fmt.Fprintf(w, "load:        %d workers\n", r.Meta.Concurrency)
+	}
+	fmt.Fprintf(w, "duration:    %s (after %s warmup)\n", r.Meta.Duration, r.Meta.Warmup)
+	fmt.Fprintf(w, "requests:    %d (%d ok, %d failed, %.2f%% errors)\n", r.Requests, r.Succeeded, r.Failed, r.ErrorRate*100)
+	if r.Dropped > 0 {
+		fmt.Fprintf(w, "dropped:     %d (all slots busy, target slower than the rate)\n", r.Dropped)
+	}
+	fmt.Fprintf(w, "throughput:  %.1f req/s\n\n", r.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\tfailed\tmin\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	printLatency := func(name string, requests, failed int, l Latency) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			name, requests, failed, l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Operations)) {
+		op := r.Operations[name]
+		printLatency(name, op.Requests, op.Failed, op.Latency)
+	}
+	printLatency("all", r.Requests, r.Failed, r.Latency)
+	tw.Flush()
+	fmt.Fprintln(w, "(latencies in ms)")
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w, "\nerrors by code:")
+		for _, code := range slices.Sorted(maps.Keys(r.Errors)) {
+			fmt.Fprintf(w, "  %-24s %d\n", code, r.Errors[code])
+		}
+	}
+}
+
+// PrintComparison writes how r changed relative to base, e.g. a run on the
+// previous commit.
+func (r *Report) PrintComparison(w io.Writer, base *Report) {
+	fmt.Fprintf(w, "compared to %s", base.Meta.StartedAt.Format(time.RFC3339))
+	if base.Meta.Revision != "" {
+		fmt.Fprintf(w, " (revision %.12s)", base.Meta.Revision)
+	}
+	fmt.Fprintln(w, ":")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "metric\tbase\tcurrent\tchange\t")
+	row := func(name string, before, after float64, format string) {
+		change := "-"
+		if before != 0 {
+			change = fmt.Sprintf("%+.1f%%", (after-before)/before*100)
+		}
+		fmt.Fprintf(tw, "%s\t"+format+"\t"+format+"\t%s\t\n", name, before, after, change)
+	}
+	row("throughput (req/s)", base.Throughput, r.Throughput, "%.1f")
+	row("error rate (%)", base.ErrorRate*100, r.ErrorRate*100, "%.2f")
+	row("p50 (ms)", base.Latency.P50, r.Latency.P50, "%.1f")
+	row("p90 (ms)", base.Latency.P90, r.Latency.P90, "%.1f")
+	row("p95 (ms)", base.Latency.P95, r.Latency.P95, "%.1f")
+	row("p99 (ms)", base.Latency.P99, r.Latency.P99, "%.1f")
+	row("max (ms)", base.Latency.Max, r.Latency.Max, "%.1f")
+	tw.Flush()
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// LevelHandler exposes the log level: GET returns it, PUT/POST with a
+// "level" query parameter or JSON body {"level": "debug"} changes it.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			raw := r.URL.Query().Get("level")
+			if raw == "" {
+				var body struct {
+					Level string `json:"level"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					http.Error(w, "expected ?level= or JSON body {\"level\": ...}", http.StatusBadRequest)
+					return
+				}
+				raw = body.Level
+			}
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(raw)); err != nil {
+				http.Error(w, "invalid level: "+raw, http.StatusBadRequest)
+				return
+			}
+			old := Level()
+			SetLevel(l)
+			Log.Warn("log level changed", "old", old, "new", l, "remote_addr", r.RemoteAddr)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"level": Level().String()})
+	})
+}
@@ -1,31 +1,116 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"time"
 )
 
 var Log *slog.Logger
 
+// level é compartilhado por todos os handlers, permitindo trocar o nível em runtime.
+var level = new(slog.LevelVar)
+
+// output guarda o arquivo de log aberto, para fechá-lo em um novo Init ou no Close.
+var output *RotatingFile
+
+// Options configura o logger global.
+type Options struct {
+	// Mode: "text" (padrão), "json" ou "silent".
+	Mode  string
+	Level slog.Level
+
+	// File, se definido, recebe os logs além do stdout, com rotação por
+	// tamanho (MaxSizeMB) e idade (MaxAge), mantendo até MaxBackups arquivos antigos.
+	File       string
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+
+	// SampleFirst > 0 ativa a amostragem de logs info/debug: por mensagem e por
+	// segundo, os primeiros SampleFirst são emitidos e depois um a cada SampleThereafter.
+	SampleFirst      int
+	SampleThereafter int
+
+	// Redact, se definido, remove PII de todos os registros antes de escrevê-los.
+	Redact *RedactRules
+
+	// Writer substitui o stdout como saída, ex.: stderr em comandos de CLI
+	// cujo stdout é o resultado.
+	Writer io.Writer
+}
+
 // Init inicializa o logger global.
 // Modo padrão: texto no stdout.
-// Modo json: se LOG_MODE=json.
-// Modo teste: silencioso.
-func Init() {
-	mode := os.Getenv("LOG_MODE")
+// Modo json: se mode=json.
+// Modo teste: silent.
+func Init(opts Options) error {
+	level.Set(opts.Level)
+
+	var w io.Writer = os.Stdout
+	if opts.Writer != nil {
+		w = opts.Writer
+	}
+	if output != nil {
+		output.Close()
+		output = nil
+	}
+	if opts.File != "" && opts.Mode != "silent" {
+		f, err := OpenRotatingFile(opts.File, int64(opts.MaxSizeMB)*1024*1024, opts.MaxAge, opts.MaxBackups)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		output = f
+		w = io.MultiWriter(w, f)
+	}
 
+	handler := NewHandler(w, opts)
+	Log = slog.New(handler)
+	return nil
+}
 
+// NewHandler monta a cadeia de handlers descrita por opts escrevendo em w:
+// formato, redação de PII e amostragem. O nível é o global, ajustável via SetLevel.
+func NewHandler(w io.Writer, opts Options) slog.Handler {
 	var handler slog.Handler
+	handlerOpts := &slog.HandlerOptions{Level: level}
 
-	switch mode{
+	switch opts.Mode{
 	case "json":
-		handler = slog.NewJSONHandler(os.Stdout, nil)
+		handler = slog.NewJSONHandler(w, handlerOpts)
 	case "silent":
-		handler = slog.NewTextHandler(io.Discard, nil)
+		handler = slog.NewTextHandler(io.Discard, handlerOpts)
 	default:
-		handler = slog.NewTextHandler(os.Stdout, nil)
+		handler = slog.NewTextHandler(w, handlerOpts)
 	}
 
-	Log = slog.New(handler)
+	if opts.Redact != nil {
+		handler = NewRedactingHandler(handler, *opts.Redact)
+	}
+	if opts.SampleFirst > 0 {
+		handler = NewSamplingHandler(handler, opts.SampleFirst, opts.SampleThereafter, time.Second)
+	}
+	return handler
+}
+
+// Close fecha o arquivo de log, se houver.
+func Close() error {
+	if output == nil {
+		return nil
+	}
+	err := output.Close()
+	output = nil
+	return err
+}
+
+// SetLevel altera o nível mínimo de log de todos os handlers.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Level retorna o nível mínimo de log atual.
+func Level() slog.Level {
+	return level.Level()
 }
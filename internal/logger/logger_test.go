@@ -0,0 +1,215 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RotatingFile_RotatesBySizeAndPrunes(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	f, err := logger.OpenRotatingFile(path, 10, 0, 2)
+	assert.Nil(err)
+	defer f.Close()
+
+	for range 5 {
+		_, err := f.Write([]byte("0123456789"))
+		assert.Nil(err)
+		time.Sleep(time.Millisecond)
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	assert.Len(backups, 2, "only maxBackups rotated files are kept")
+	current, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("0123456789", string(current))
+}
+
+func Test_RotatingFile_RotatesByAge(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f, err := logger.OpenRotatingFile(path, 0, 10*time.Millisecond, 0)
+	assert.Nil(err)
+	defer f.Close()
+
+	f.Write([]byte("first\n"))
+	time.Sleep(20 * time.Millisecond)
+	f.Write([]byte("second\n"))
+
+	backups, _ := filepath.Glob(path + ".*")
+	assert.Len(backups, 1)
+	current, _ := os.ReadFile(path)
+	assert.Equal("second\n", string(current))
+}
+
+func Test_RotatingFile_PrunesOnlyExpiredBackups(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	old := path + "." + time.Now().Add(-2*time.Hour).Format("20060102T150405.000000")
+	for _, name := range []string{old, path + ".lock", path + ".20200101"} {
+		assert.Nil(os.WriteFile(name, nil, 0o644))
+	}
+
+	f, err := logger.OpenRotatingFile(path, 10, time.Hour, 5)
+	assert.Nil(err)
+	defer f.Close()
+	f.Write([]byte("0123456789"))
+	f.Write([]byte("0123456789"))
+
+	assert.NoFileExists(old, "backups older than maxAge are removed")
+	assert.FileExists(path + ".lock")
+	assert.FileExists(path + ".20200101")
+	backups, _ := filepath.Glob(path + ".*T*")
+	assert.Len(backups, 1)
+}
+
+func Test_SamplingHandler_SamplesInfoButNotWarnings(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	log := slog.New(logger.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 2, 3, time.Minute))
+
+	for range 8 {
+		log.Info("fetch user done")
+	}
+	for range 3 {
+		log.Warn("upstream slow")
+	}
+	log.Info("other message")
+
+	out := buf.String()
+	// records 1, 2, then every 3rd after the first two: 5 and 8
+	assert.Equal(4, strings.Count(out, "fetch user done"))
+	assert.Equal(3, strings.Count(out, "upstream slow"))
+	assert.Equal(1, strings.Count(out, "other message"))
+}
+
+func Test_SamplingHandler_ResetsEachWindow(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	h := logger.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 1, 0, 10*time.Millisecond)
+
+	for range 3 {
+		h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "tick", 0))
+	}
+	time.Sleep(15 * time.Millisecond)
+	h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "tick", 0))
+
+	assert.Equal(2, strings.Count(buf.String(), "tick"))
+}
+
+func Test_SamplingHandler_ForgetsEndedWindows(t *testing.T) {
+	assert := assert.New(t)
+	h := logger.NewSamplingHandler(slog.NewTextHandler(io.Discard, nil), 1, 0, 10*time.Millisecond)
+	tracked := h.(interface{ Len() int })
+
+	start := time.Now()
+	for i := range 100 {
+		h.Handle(context.Background(), slog.NewRecord(start, slog.LevelInfo, fmt.Sprintf("user %d not found", i), 0))
+	}
+	assert.Equal(100, tracked.Len())
+
+	h.Handle(context.Background(), slog.NewRecord(start.Add(20*time.Millisecond), slog.LevelInfo, "tick", 0))
+	assert.Equal(1, tracked.Len(), "only the message of the current window is kept")
+}
+
+func Test_Init_WritesToFile(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	err := logger.Init(logger.Options{Mode: "json", Level: slog.LevelWarn, File: path})
+	assert.Nil(err)
+	logger.Log.Info("not written")
+	logger.Log.Warn("written to file")
+	assert.Nil(logger.Close())
+
+	content, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Contains(string(content), `"msg":"written to file"`)
+	assert.NotContains(string(content), "not written")
+
+	assert.Nil(logger.Init(logger.Options{Mode: "silent"}))
+}
+
+func Test_LevelHandler(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(logger.Init(logger.Options{Mode: "silent"}))
+	h := logger.LevelHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/log-level?level=debug", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"level":"DEBUG"}`, w.Body.String())
+	assert.Equal(slog.LevelDebug, logger.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/log-level", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/log-level", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+
+	logger.SetLevel(slog.LevelInfo)
+}
+
+func Test_RedactingHandler_MasksFieldsAndPatterns(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	log := slog.New(logger.NewRedactingHandler(slog.NewJSONHandler(&buf, nil), logger.DefaultRedactRules()))
+
+	log.With("email", "john@example.com").Info("user loaded for john@example.com",
+		"name", "John Doe",
+		"userId", 1,
+		slog.Group("contact", "phone", "1-770-736-8031 x56442", "city", "Gwenborough"),
+		"url", "https://example.com/users?email=john@example.com",
+		"error", errors.New("lookup 010-692-6593 failed"),
+	)
+
+	out := buf.String()
+	assert.NotContains(out, "john@example.com")
+	assert.NotContains(out, "John Doe")
+	assert.NotContains(out, "770-736")
+	assert.NotContains(out, "692-6593")
+	assert.Contains(out, `"msg":"user loaded for [REDACTED]"`)
+	assert.Contains(out, `"userId":1`)
+	assert.Contains(out, `"city":"Gwenborough"`)
+	assert.Contains(out, `"url":"https://example.com/users?email=[REDACTED]"`)
+	assert.Contains(out, `"error":"lookup [REDACTED] failed"`)
+}
+
+func Test_RedactingHandler_Hash(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	rules := logger.DefaultRedactRules()
+	rules.Hash = true
+	rules.Patterns = append(rules.Patterns, regexp.MustCompile(`tok_[a-z0-9]+`))
+	log := slog.New(logger.NewRedactingHandler(slog.NewTextHandler(&buf, nil), rules))
+
+	log.Info("first", "email", "john@example.com")
+	log.Info("second", "email", "john@example.com", "token", "tok_abc123")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 2)
+	hash := lines[0][strings.Index(lines[0], "email=")+len("email="):]
+	assert.True(strings.HasPrefix(hash, "sha256:"))
+	assert.Contains(lines[1], "email="+hash, "same value hashes the same")
+	assert.NotContains(buf.String(), "tok_abc123")
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+var (
+	// EmailPattern matches e-mail addresses.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// PhonePattern matches phone numbers such as "1-770-736-8031 x56442" or "+55 (19) 99999-9999".
+	PhonePattern = regexp.MustCompile(`\+?\(?\d{1,4}\)?[\s.\-]?\(?\d{2,4}\)?[\s.\-]?\d{3,5}[\s.\-]\d{4}\b(\s*x\d+)?`)
+)
+
+// RedactRules configures which log data is considered PII and how it is hidden.
+type RedactRules struct {
+	// Fields are attribute keys (case-insensitive) whose values are always redacted.
+	Fields []string
+	// Patterns are redacted wherever they appear in the message or string values.
+	Patterns []*regexp.Regexp
+	// Hash replaces PII with a short SHA-256 digest instead of a fixed mask, so
+	// records about the same value can still be correlated.
+	Hash bool
+}
+
+// DefaultRedactRules redacts name, email and phone fields plus any e-mail or phone number.
+func DefaultRedactRules() RedactRules {
+	return RedactRules{
+		Fields:   []string{"name", "email", "phone"},
+		Patterns: []*regexp.Regexp{EmailPattern, PhonePattern},
+	}
+}
+
+// redactingHandler rewrites records so PII never reaches the wrapped handler.
+type redactingHandler struct {
+	next   slog.Handler
+	rules  RedactRules
+	fields map[string]bool
+}
+
+// NewRedactingHandler wraps next, redacting PII according to rules.
+func NewRedactingHandler(next slog.Handler, rules RedactRules) slog.Handler {
+	fields := make(map[string]bool, len(rules.Fields))
+	for _, f := range rules.Fields {
+		fields[strings.ToLower(f)] = true
+	}
+	return &redactingHandler{next: next, rules: rules, fields: fields}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), rules: h.rules, fields: h.fields}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), rules: h.rules, fields: h.fields}
+}
+
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+
+	if h.fields[strings.ToLower(a.Key)] && v.Kind() != slog.KindGroup {
+		return slog.String(a.Key, h.replace(v.String()))
+	}
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]any, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindString:
+		return slog.String(a.Key, h.redactString(v.String()))
+	case slog.KindAny:
+		// errors and Stringers often embed upstream URLs or payloads
+		switch x := v.Any().(type) {
+		case error:
+			if s := x.Error(); h.redactString(s) != s {
+				return slog.String(a.Key, h.redactString(s))
+			}
+		case fmt.Stringer:
+			if s := x.String(); h.redactString(s) != s {
+				return slog.String(a.Key, h.redactString(s))
+			}
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (h *redactingHandler) redactString(s string) string {
+	for _, p := range h.rules.Patterns {
+		s = p.ReplaceAllStringFunc(s, h.replace)
+	}
+	return s
+}
+
+func (h *redactingHandler) replace(s string) string {
+	if !h.rules.Hash {
+		return redactedValue
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// samplingHandler drops repetitive info/debug records: for each message, the
+// first `first` records of every window pass, then one of every `thereafter`.
+// Warnings and errors always pass. Messages are tracked until their window
+// ends, so messages built from request data don't pile up.
+type samplingHandler struct {
+	next       slog.Handler
+	first      int
+	thereafter int
+	window     time.Duration
+	counters   *sampleCounters
+}
+
+type sampleCounters struct {
+	mu        sync.Mutex
+	counts    map[string]*sampleCount
+	lastSweep time.Time
+}
+
+type sampleCount struct {
+	windowStart time.Time
+	n           int
+}
+
+// NewSamplingHandler wraps next with per-message sampling.
+func NewSamplingHandler(next slog.Handler, first, thereafter int, window time.Duration) slog.Handler {
+	return &samplingHandler{
+		next:       next,
+		first:      first,
+		thereafter: thereafter,
+		window:     window,
+		counters:   &sampleCounters{counts: map[string]*sampleCount{}},
+	}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn || h.keep(r.Message, r.Time) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	return &clone
+}
+
+// Len returns the number of messages tracked.
+func (h *samplingHandler) Len() int {
+	h.counters.mu.Lock()
+	defer h.counters.mu.Unlock()
+	return len(h.counters.counts)
+}
+
+func (h *samplingHandler) keep(msg string, now time.Time) bool {
+	h.counters.mu.Lock()
+	defer h.counters.mu.Unlock()
+
+	// a message whose window ended starts over anyway: forget those once a window
+	if now.Sub(h.counters.lastSweep) >= h.window {
+		for m, c := range h.counters.counts {
+			if now.Sub(c.windowStart) >= h.window {
+				delete(h.counters.counts, m)
+			}
+		}
+		h.counters.lastSweep = now
+	}
+
+	c, ok := h.counters.counts[msg]
+	if !ok || now.Sub(c.windowStart) >= h.window {
+		c = &sampleCount{windowStart: now}
+		h.counters.counts[msg] = c
+	}
+	c.n++
+
+	if c.n <= h.first {
+		return true
+	}
+	return h.thereafter > 0 && (c.n-h.first)%h.thereafter == 0
+}
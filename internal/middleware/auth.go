@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"net/http"
+	"strings"
+)
+
+// AuthMiddleware resolves the request principal from a bearer token or an
+// X-Api-Key header. Requests without credentials continue as anonymous;
+// requests with unknown credentials are rejected, after taking a token from
+// the bucket of their IP in limiter, if any, which answers 429 once empty.
+func AuthMiddleware(tokens auth.Tokens, limiter *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, ok := tokens[token]
+		if !ok {
+			if limiter != nil {
+				if res, key, enabled := limiter.TakeUnauthenticated(r.RemoteAddr); enabled && writeRateLimit(w, res) {
+					logger.Log.Info("rate limit exceeded", "key", key, "tier", DefaultTier, "path", r.URL.Path)
+					return
+				}
+			}
+			logger.Log.Info("rejected unknown credentials", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthenticated, "invalid credentials")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if token, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.Header.Get("X-Api-Key")
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTier is the tier applied to anonymous callers and to principals
+// without a tier named after one of their roles.
+const DefaultTier = "default"
+
+// RateLimiter limits requests per client with one token bucket per key.
+type RateLimiter struct {
+	store *ratelimit.Store
+
+	mu    sync.RWMutex
+	keyBy string
+	tiers map[string]ratelimit.Limit
+}
+
+// NewRateLimiter creates a limiter keyed by "api_key", "ip" or "header:<Name>".
+// Tiers must contain DefaultTier; no tiers at all disables limiting.
+func NewRateLimiter(keyBy string, tiers map[string]ratelimit.Limit, idleTTL time.Duration) (*RateLimiter, error) {
+	rl := &RateLimiter{store: ratelimit.NewStore(idleTTL)}
+	if err := rl.Update(keyBy, tiers); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// Update swaps the key strategy and tier limits used for new requests.
+func (rl *RateLimiter) Update(keyBy string, tiers map[string]ratelimit.Limit) error {
+	if keyBy != "api_key" && keyBy != "ip" && !strings.HasPrefix(keyBy, "header:") {
+		return fmt.Errorf("invalid rate limit key %q: expected api_key, ip or header:<Name>", keyBy)
+	}
+	if _, ok := tiers[DefaultTier]; !ok && len(tiers) > 0 {
+		return fmt.Errorf("rate limit tiers must define %q", DefaultTier)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.keyBy = keyBy
+	rl.tiers = tiers
+	return nil
+}
+
+// Store exposes the bucket store, e.g. to run idle key eviction.
+func (rl *RateLimiter) Store() *ratelimit.Store {
+	return rl.store
+}
+
+// RateLimitMiddleware rejects requests over the client's tier limit with a
+// GraphQL-formatted 429 and reports the bucket state in RateLimit-* headers.
+func RateLimitMiddleware(rl *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, key, tier, enabled := rl.Take(r.Context(), r.Header.Get, r.RemoteAddr)
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if writeRateLimit(w, res) {
+			logger.Log.Info("rate limit exceeded", "key", key, "tier", tier, "path", r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimit reports res in RateLimit-* headers and answers a request
+// over the limit with a 429, reporting whether it did.
+func writeRateLimit(w http.ResponseWriter, res ratelimit.Result) bool {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if res.Allowed {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	apierror.Write(w, http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded")
+	return true
+}
+
+// Take takes a token from the bucket of the client of a request, identified
+// per the key strategy by the principal of ctx, the header looked up with
+// header or remoteAddr, so other transports share the buckets of
+// RateLimitMiddleware. enabled is false when limiting is disabled.
+func (rl *RateLimiter) Take(ctx context.Context, header func(string) string, remoteAddr string) (res ratelimit.Result, key, tier string, enabled bool) {
+	key, tier, limit, enabled := rl.classify(ctx, header, remoteAddr)
+	if !enabled {
+		return ratelimit.Result{}, "", "", false
+	}
+	return rl.store.Bucket(key, limit).Take(), key, tier, true
+}
+
+// TakeUnauthenticated takes a token from the bucket of the IP in remoteAddr,
+// at the default tier, for requests with unknown credentials: they count as
+// anonymous requests before being rejected, so guessing credentials is
+// throttled. enabled is false when limiting is disabled.
+func (rl *RateLimiter) TakeUnauthenticated(remoteAddr string) (res ratelimit.Result, key string, enabled bool) {
+	rl.mu.RLock()
+	limit, enabled := rl.tiers[DefaultTier]
+	rl.mu.RUnlock()
+	if !enabled {
+		return ratelimit.Result{}, "", false
+	}
+	key = "ip:" + clientIP(remoteAddr)
+	return rl.store.Bucket(key, limit).Take(), key, true
+}
+
+func (rl *RateLimiter) classify(ctx context.Context, header func(string) string, remoteAddr string) (key, tier string, limit ratelimit.Limit, enabled bool) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	if len(rl.tiers) == 0 {
+		return "", "", ratelimit.Limit{}, false
+	}
+
+	principal := auth.FromContext(ctx)
+
+	tier = DefaultTier
+	if principal != nil {
+		for _, role := range principal.Roles {
+			if _, ok := rl.tiers[role]; ok {
+				tier = role
+				break
+			}
+		}
+	}
+
+	switch {
+	case rl.keyBy == "api_key" && principal != nil:
+		key = "principal:" + principal.Subject
+	case strings.HasPrefix(rl.keyBy, "header:"):
+		if v := header(strings.TrimPrefix(rl.keyBy, "header:")); v != "" {
+			key = "header:" + v
+		}
+	}
+	if key == "" {
+		key = "ip:" + clientIP(remoteAddr)
+	}
+	return key, tier, rl.tiers[tier], true
+}
+
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
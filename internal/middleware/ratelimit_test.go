@@ -0,0 +1,113 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/middleware"
+	"go-graphql-aggregator/internal/ratelimit"
+	"go-graphql-aggregator/internal/test"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func Test_RateLimitMiddleware_RejectsOverLimit(t *testing.T) {
+	assert := assert.New(t)
+	tiers := map[string]ratelimit.Limit{middleware.DefaultTier: {Rate: 0.01, Burst: 1}}
+	limiter, err := middleware.NewRateLimiter("ip", tiers, time.Minute)
+	assert.Nil(err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := middleware.RateLimitMiddleware(limiter, next)
+
+	req := httptest.NewRequest("POST", "/query", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal("0", w.Header().Get("RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(w.Header().Get("Retry-After"))
+
+	var resp struct {
+		Errors []struct {
+			Message    string
+			Extensions map[string]any
+		}
+	}
+	assert.Nil(json.NewDecoder(w.Body).Decode(&resp))
+	if assert.Len(resp.Errors, 1) {
+		assert.Equal("RATE_LIMITED", resp.Errors[0].Extensions["code"])
+	}
+}
+
+func Test_RateLimitMiddleware_TierByRole(t *testing.T) {
+	assert := assert.New(t)
+	tiers := map[string]ratelimit.Limit{
+		middleware.DefaultTier: {Rate: 0.01, Burst: 1},
+		"ADMIN":                {Rate: 10, Burst: 50},
+	}
+	limiter, err := middleware.NewRateLimiter("api_key", tiers, time.Minute)
+	assert.Nil(err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := middleware.RateLimitMiddleware(limiter, next)
+
+	req := httptest.NewRequest("POST", "/query", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "alice", Roles: []string{"ADMIN"}}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("50", w.Header().Get("RateLimit-Limit"))
+	assert.Equal("49", w.Header().Get("RateLimit-Remaining"))
+}
+
+func Test_AuthMiddleware_ThrottlesUnknownCredentials(t *testing.T) {
+	assert := assert.New(t)
+	tiers := map[string]ratelimit.Limit{middleware.DefaultTier: {Rate: 0.01, Burst: 1}}
+	limiter, err := middleware.NewRateLimiter("api_key", tiers, time.Minute)
+	assert.Nil(err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := middleware.AuthMiddleware(auth.Tokens{"s3cret": {Subject: "ops"}}, limiter, middleware.RateLimitMiddleware(limiter, next))
+
+	req := httptest.NewRequest("POST", "/query", nil)
+	req.Header.Set("X-Api-Key", "wrong")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusTooManyRequests, w.Code, "unknown credentials are limited by IP")
+	assert.NotEmpty(w.Header().Get("Retry-After"))
+
+	req.Header.Set("X-Api-Key", "s3cret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusOK, w.Code, "known credentials have their own bucket")
+}
+
+func Test_NewRateLimiter_InvalidConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := middleware.NewRateLimiter("cookie", map[string]ratelimit.Limit{middleware.DefaultTier: {Rate: 1, Burst: 1}}, time.Minute)
+	assert.NotNil(err)
+
+	_, err = middleware.NewRateLimiter("ip", map[string]ratelimit.Limit{"USER": {Rate: 1, Burst: 1}}, time.Minute)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "default")
+}
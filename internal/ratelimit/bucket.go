@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second, up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result describes the bucket state after a Take.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next token, zero when allowed
+}
+
+// Bucket is a thread-safe token bucket.
+type Bucket struct {
+	mu       sync.Mutex
+	limit    Limit
+	tokens   float64
+	last     time.Time
+	lastUsed time.Time
+}
+
+// NewBucket creates a full bucket for the given limit.
+func NewBucket(limit Limit) *Bucket {
+	now := time.Now()
+	return &Bucket{limit: limit, tokens: float64(limit.Burst), last: now, lastUsed: now}
+}
+
+// SetLimit changes the bucket parameters, keeping the tokens already accumulated.
+func (b *Bucket) SetLimit(limit Limit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.limit = limit
+	b.tokens = math.Min(b.tokens, float64(limit.Burst))
+}
+
+// Take consumes one token if available.
+func (b *Bucket) Take() Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refill(now)
+	b.lastUsed = now
+
+	res := Result{Limit: b.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = b.durationFor(1 - b.tokens)
+	}
+	res.Remaining = int(math.Max(0, math.Floor(b.tokens)))
+	res.Reset = b.durationFor(float64(b.limit.Burst) - b.tokens)
+	return res
+}
+
+// Wait blocks until a token is available or ctx is done. It fails fast when the
+// wait would outlast the context deadline. It returns how long it waited.
+func (b *Bucket) Wait(ctx context.Context) (time.Duration, error) {
+	b.mu.Lock()
+	now := time.Now()
+	b.refill(now)
+	b.lastUsed = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return 0, nil
+	}
+
+	wait := b.durationFor(1 - b.tokens)
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("rate limit wait of %s exceeds context deadline: %w", wait, context.DeadlineExceeded)
+	}
+	b.tokens--
+	b.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// give back the reserved token, without overfilling the bucket
+		b.mu.Lock()
+		b.refill(time.Now())
+		b.tokens = math.Min(b.tokens+1, float64(b.limit.Burst))
+		b.mu.Unlock()
+		return 0, ctx.Err()
+	case <-timer.C:
+		return wait, nil
+	}
+}
+
+func (b *Bucket) idleSince() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastUsed
+}
+
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+}
+
+func (b *Bucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if b.limit.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / b.limit.Rate * float64(time.Second))
+}
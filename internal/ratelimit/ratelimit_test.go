@@ -0,0 +1,102 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bucket_TakeUntilEmpty(t *testing.T) {
+	assert := assert.New(t)
+	b := ratelimit.NewBucket(ratelimit.Limit{Rate: 1, Burst: 2})
+
+	first := b.Take()
+	second := b.Take()
+	third := b.Take()
+
+	assert.True(first.Allowed)
+	assert.Equal(1, first.Remaining)
+	assert.True(second.Allowed)
+	assert.Equal(0, second.Remaining)
+	assert.False(third.Allowed)
+	assert.Equal(2, third.Limit)
+	assert.Greater(third.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(third.RetryAfter, time.Second)
+}
+
+func Test_Bucket_WaitRefills(t *testing.T) {
+	assert := assert.New(t)
+	b := ratelimit.NewBucket(ratelimit.Limit{Rate: 50, Burst: 1})
+	b.Take()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	waited, err := b.Wait(ctx)
+
+	assert.Nil(err)
+	assert.Greater(waited, time.Duration(0))
+}
+
+func Test_Bucket_WaitExceedsDeadline(t *testing.T) {
+	assert := assert.New(t)
+	b := ratelimit.NewBucket(ratelimit.Limit{Rate: 0.1, Burst: 1})
+	b.Take()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := b.Wait(ctx)
+
+	assert.NotNil(err)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Less(time.Since(start), 50*time.Millisecond, "should fail fast instead of waiting for the deadline")
+}
+
+func Test_Bucket_CancelledWaitGivesTokenBack(t *testing.T) {
+	assert := assert.New(t)
+	b := ratelimit.NewBucket(ratelimit.Limit{Rate: 1, Burst: 1})
+	b.Take()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := b.Wait(ctx)
+	assert.ErrorIs(err, context.Canceled)
+
+	res := b.Take()
+	assert.False(res.Allowed)
+	assert.Less(res.RetryAfter, time.Second, "the reserved token was given back")
+
+	b.SetLimit(ratelimit.Limit{Rate: 1000, Burst: 1})
+	time.Sleep(10 * time.Millisecond)
+	assert.True(b.Take().Allowed)
+	assert.False(b.Take().Allowed, "never more than Burst tokens")
+}
+
+func Test_Store_EvictsIdleKeys(t *testing.T) {
+	assert := assert.New(t)
+	s := ratelimit.NewStore(10 * time.Millisecond)
+	s.Bucket("a", ratelimit.Limit{Rate: 1, Burst: 1})
+
+	time.Sleep(20 * time.Millisecond)
+	s.Bucket("b", ratelimit.Limit{Rate: 1, Burst: 1}).Take()
+
+	assert.Equal(1, s.Evict())
+	assert.Equal(1, s.Len())
+}
+
+func Test_ParseTiers(t *testing.T) {
+	assert := assert.New(t)
+
+	tiers, err := ratelimit.ParseTiers("default:5:10, ADMIN:100:200")
+	assert.Nil(err)
+	assert.Equal(ratelimit.Limit{Rate: 5, Burst: 10}, tiers["default"])
+	assert.Equal(ratelimit.Limit{Rate: 100, Burst: 200}, tiers["ADMIN"])
+
+	_, err = ratelimit.ParseTiers("default:fast:10")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid rate")
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps one bucket per key in memory and evicts keys that stay idle.
+type Store struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	idleTTL time.Duration
+}
+
+// NewStore creates a store evicting buckets idle for longer than idleTTL.
+func NewStore(idleTTL time.Duration) *Store {
+	return &Store{buckets: map[string]*Bucket{}, idleTTL: idleTTL}
+}
+
+// Bucket returns the bucket for key, creating it with limit when missing.
+// An existing bucket is updated when its limit differs.
+func (s *Store) Bucket(key string, limit Limit) *Bucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = NewBucket(limit)
+		s.buckets[key] = b
+		return b
+	}
+	b.mu.Lock()
+	changed := b.limit != limit
+	b.mu.Unlock()
+	if changed {
+		b.SetLimit(limit)
+	}
+	return b
+}
+
+// Len returns the number of tracked keys.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// Evict removes buckets idle for longer than the store TTL and returns how many were removed.
+func (s *Store) Evict() int {
+	cutoff := time.Now().Add(-s.idleTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, b := range s.buckets {
+		if b.idleSince().Before(cutoff) {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Run evicts idle buckets every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Evict()
+		}
+	}
+}
+
+// ParseTiers parses a tier spec in the form "name:rate:burst,name2:rate:burst".
+func ParseTiers(spec string) (map[string]Limit, error) {
+	tiers := map[string]Limit{}
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid tier %q: expected name:rate:burst", entry)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate for tier %q: %s", parts[0], parts[1])
+		}
+		burst, err := strconv.Atoi(parts[2])
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("invalid burst for tier %q: %s", parts[0], parts[2])
+		}
+		tiers[parts[0]] = Limit{Rate: rate, Burst: burst}
+	}
+	return tiers, nil
+}
@@ -0,0 +1,283 @@
+package responsecache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/cache"
+	"go-graphql-aggregator/internal/cachecontrol"
+	"go-graphql-aggregator/internal/logger"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+// Cache serves whole query responses from memory. Responses are keyed by the
+// normalized query document, operation name and variables, plus the roles
+// and scopes of the caller, and the caller itself for private responses.
+// They are kept for the maxAge of their cachecontrol policy, so only those
+// without errors and with a positive maxAge are cached; the
+// cachecontrol.Extension must be used on the same server.
+//
+// A mutation invalidates the responses holding objects of the types its
+// fields return; Invalidate does the same for other changes. Requests with
+// "Cache-Control: no-cache" skip the cache, storing the fresh response, and
+// those with "no-store" don't store it either.
+type Cache struct {
+	entries *cache.LRU[*entry]
+	now     func() time.Time
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+	cache.Purgeable
+} = &Cache{}
+
+// New creates a cache of up to size responses.
+func New(size int) *Cache {
+	return &Cache{entries: cache.NewLRU[*entry]("response", size), now: time.Now}
+}
+
+type entry struct {
+	resp    *graphql.Response
+	policy  cachecontrol.Policy
+	expires time.Time
+	types   map[string]bool
+}
+
+func (e *entry) response() *graphql.Response {
+	resp := *e.resp
+	resp.Extensions = maps.Clone(e.resp.Extensions)
+	return &resp
+}
+
+func (c *Cache) ExtensionName() string {
+	return "ResponseCache"
+}
+
+func (c *Cache) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (c *Cache) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	switch {
+	case oc.Operation == nil:
+		return next(ctx)
+	case oc.Operation.Operation == ast.Mutation:
+		return c.invalidating(ctx, oc, next)
+	case oc.Operation.Operation != ast.Query:
+		return next(ctx)
+	}
+
+	noCache, noStore := requestDirectives(oc)
+	principal := auth.FromContext(ctx)
+	key, err := operationKey(oc)
+	if err != nil {
+		logger.Log.Warn("response cache key failed", "error", err)
+		return next(ctx)
+	}
+	publicKey, privateKey := scopedKey(key, principal, false), scopedKey(key, principal, true)
+
+	if !noCache {
+		if e, ok := c.lookup(ctx, publicKey, privateKey); ok {
+			c.hits.Add(1)
+			policy := e.policy
+			policy.MaxAge = int(e.expires.Sub(c.now()).Seconds())
+			cachecontrol.Report(ctx, policy)
+			return graphql.OneShot(e.response())
+		}
+	}
+	c.misses.Add(1)
+
+	ctx, policyOf := cachecontrol.Record(ctx)
+	types := &resolvedTypes{types: map[string]bool{}}
+	handler := next(context.WithValue(ctx, typesKey{}, types))
+	first := true
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		if !first {
+			return resp
+		}
+		first = false
+		if noStore || resp == nil || len(resp.Errors) > 0 {
+			return resp
+		}
+		policy, ok := policyOf()
+		if !ok || !policy.Cacheable() {
+			return resp
+		}
+
+		stored := *resp
+		stored.Extensions = maps.Clone(resp.Extensions)
+		e := &entry{
+			resp:    &stored,
+			policy:  policy,
+			expires: c.now().Add(time.Duration(policy.MaxAge) * time.Second),
+			types:   types.get(),
+		}
+		if policy.Scope == cachecontrol.Private {
+			c.entries.Add(ctx, privateKey, e)
+		} else {
+			c.entries.Add(ctx, publicKey, e)
+		}
+		return resp
+	}
+}
+
+func (c *Cache) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	if types, ok := ctx.Value(typesKey{}).(*resolvedTypes); ok {
+		if fc := graphql.GetFieldContext(ctx); fc != nil && !strings.HasPrefix(fc.Object, "__") {
+			types.add(fc.Object)
+		}
+	}
+	return next(ctx)
+}
+
+// lookup returns the unexpired entry under the public key, or else under the
+// private one.
+func (c *Cache) lookup(ctx context.Context, keys ...string) (*entry, bool) {
+	now := c.now()
+	for _, key := range keys {
+		e, ok := c.entries.Get(ctx, key)
+		if !ok {
+			continue
+		}
+		if !now.Before(e.expires) {
+			c.entries.Remove(key)
+			continue
+		}
+		return e, true
+	}
+	return nil, false
+}
+
+// invalidating runs a mutation, then invalidates the responses holding the
+// types of its fields, whether they succeeded or not.
+func (c *Cache) invalidating(ctx context.Context, oc *graphql.OperationContext, next graphql.OperationHandler) graphql.ResponseHandler {
+	var types []string
+	for _, sel := range oc.Operation.SelectionSet {
+		if f, ok := sel.(*ast.Field); ok && f.Definition != nil {
+			types = append(types, f.Definition.Type.Name())
+		}
+	}
+	handler := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		c.Invalidate(types...)
+		return resp
+	}
+}
+
+// Invalidate drops the responses holding objects of any of types.
+func (c *Cache) Invalidate(types ...string) {
+	if len(types) == 0 {
+		return
+	}
+	dropped := 0
+	for _, key := range c.entries.Keys() {
+		e, ok := c.entries.Peek(key)
+		if ok && slices.ContainsFunc(types, func(t string) bool { return e.types[t] }) {
+			c.entries.Remove(key)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		logger.Log.Debug("response cache invalidated", "types", types, "responses", dropped)
+	}
+}
+
+// Stats counts as hits the responses served from the cache.
+func (c *Cache) Stats() cache.Stats {
+	s := c.entries.Stats()
+	s.Hits = c.hits.Load()
+	s.Misses = c.misses.Load()
+	return s
+}
+
+// Purge drops every response.
+func (c *Cache) Purge() {
+	c.entries.Purge()
+}
+
+// requestDirectives returns the no-cache and no-store directives of the
+// request Cache-Control header.
+func requestDirectives(oc *graphql.OperationContext) (noCache, noStore bool) {
+	for _, v := range oc.Headers.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-cache":
+				noCache = true
+			case "no-store":
+				noCache, noStore = true, true
+			}
+		}
+	}
+	return noCache, noStore
+}
+
+// operationKey identifies the operation of oc regardless of the formatting
+// of its document.
+func operationKey(oc *graphql.OperationContext) (string, error) {
+	var doc bytes.Buffer
+	formatter.NewFormatter(&doc, formatter.WithCompacted()).FormatQueryDocument(oc.Doc)
+	vars, err := json.Marshal(oc.Variables)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(doc.Bytes())
+	h.Write([]byte{0})
+	h.Write([]byte(oc.Operation.Name))
+	h.Write([]byte{0})
+	h.Write(vars)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scopedKey adds to key what the response may depend on: the roles and
+// scopes of principal, and its subject for private responses.
+func scopedKey(key string, principal *auth.Principal, private bool) string {
+	if principal == nil {
+		return key + "|anonymous"
+	}
+	roles, scopes := slices.Sorted(slices.Values(principal.Roles)), slices.Sorted(slices.Values(principal.Scopes))
+	key += "|" + strings.Join(roles, ",") + "|" + strings.Join(scopes, ",")
+	if private {
+		key += "|" + principal.Subject
+	}
+	return key
+}
+
+type typesKey struct{}
+
+// resolvedTypes records the object types of the fields an operation resolves.
+type resolvedTypes struct {
+	mu    sync.Mutex
+	types map[string]bool
+}
+
+func (r *resolvedTypes) add(t string) {
+	r.mu.Lock()
+	r.types[t] = true
+	r.mu.Unlock()
+}
+
+func (r *resolvedTypes) get() map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.types)
+}
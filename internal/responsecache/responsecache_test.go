@@ -0,0 +1,200 @@
+package responsecache_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/cachecontrol"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/graph"
+	"go-graphql-aggregator/internal/jobs"
+	"go-graphql-aggregator/internal/responsecache"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+// countingUsers counts the users fetched.
+type countingUsers struct{ calls atomic.Int32 }
+
+func (c *countingUsers) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	c.calls.Add(1)
+	return &fetcher.User{ID: userID, Name: "John Doe", Email: "john@example.com"}, nil
+}
+
+type server struct {
+	http.Handler
+	users *countingUsers
+	cache *responsecache.Cache
+}
+
+func newServer(t *testing.T, defaultMaxAge int) *server {
+	users := &countingUsers{}
+	agg := &aggregator.Aggregator{
+		UserFetcher:  users,
+		PostsFetcher: &mock.MockPostsFetcher{Posts: mock.PostsMock},
+		Timeout:      time.Second,
+	}
+	jm, err := jobs.NewManager(jobs.Options{Aggregator: func() *aggregator.Aggregator { return agg }, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := responsecache.New(100)
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Aggregator: agg, Jobs: jm})))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(c)
+	srv.Use(&cachecontrol.Extension{DefaultMaxAge: defaultMaxAge})
+	return &server{Handler: srv, users: users, cache: c}
+}
+
+// post runs query as principal, returning the data of the response.
+func (s *server) post(t *testing.T, principal *auth.Principal, query string, vars map[string]any, header ...string) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	var resp struct {
+		Data   map[string]any
+		Errors []any
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || len(resp.Errors) > 0 {
+		t.Fatalf("query failed: %v %v", err, resp.Errors)
+	}
+	return resp.Data
+}
+
+var (
+	alice = &auth.Principal{Subject: "alice", Roles: []string{"USER"}, Scopes: []string{"read:pii"}}
+	bob   = &auth.Principal{Subject: "bob", Roles: []string{"USER"}, Scopes: []string{"read:pii"}}
+)
+
+func Test_Cache_ServesIdenticalOperations(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 0)
+
+	first := s.post(t, alice, "query($id: Int!) { userSummary(userId: $id) { name postCount } }", map[string]any{"id": 1})
+	second := s.post(t, bob, "query ($id: Int!) {\n  userSummary(userId: $id) {\n    name\n    postCount\n  }\n}", map[string]any{"id": 1})
+
+	assert.Equal(first, second)
+	assert.Equal(int32(1), s.users.calls.Load(), "formatting doesn't change the key, and public responses are shared")
+
+	s.post(t, alice, "query($id: Int!) { userSummary(userId: $id) { name postCount } }", map[string]any{"id": 2})
+	assert.Equal(int32(2), s.users.calls.Load(), "other variables are another operation")
+
+	s.post(t, nil, "query($id: Int!) { userSummary(userId: $id) { name postCount } }", map[string]any{"id": 1})
+	assert.Equal(int32(3), s.users.calls.Load(), "other roles and scopes are another key")
+
+	stats := s.cache.Stats()
+	assert.Equal(int64(1), stats.Hits)
+	assert.Equal(int64(3), stats.Misses)
+	assert.Equal(3, stats.Len)
+}
+
+func Test_Cache_PrivateResponsesArePerCaller(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 0)
+	query := "{ userSummary(userId: 1) { name email } }"
+
+	s.post(t, alice, query, nil)
+	s.post(t, alice, query, nil)
+	assert.Equal(int32(1), s.users.calls.Load())
+
+	s.post(t, bob, query, nil)
+	assert.Equal(int32(2), s.users.calls.Load())
+}
+
+func Test_Cache_UncacheableResponses(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 0)
+	query := "{ userSummary(userId: 1) { name } syncStatus { users } }"
+
+	s.post(t, alice, query, nil)
+	s.post(t, alice, query, nil)
+
+	assert.Equal(int32(2), s.users.calls.Load(), "syncStatus has maxAge 0")
+	assert.Equal(0, s.cache.Stats().Len)
+}
+
+func Test_Cache_BypassHeader(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 0)
+	query := "{ userSummary(userId: 1) { name } }"
+
+	s.post(t, alice, query, nil, "Cache-Control", "no-store")
+	assert.Equal(0, s.cache.Stats().Len, "no-store responses are not stored")
+
+	s.post(t, alice, query, nil)
+	s.post(t, alice, query, nil, "Cache-Control", "no-cache")
+	assert.Equal(int32(3), s.users.calls.Load())
+
+	s.post(t, alice, query, nil)
+	assert.Equal(int32(3), s.users.calls.Load(), "no-cache refreshes the stored response")
+}
+
+func Test_Cache_Invalidation(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 30)
+
+	started := s.post(t, alice, "mutation { startSummaryJob(userIds: [1]) { id } }", nil)
+	id := started["startSummaryJob"].(map[string]any)["id"]
+	jobQuery := "query($id: ID!) { job(id: $id) { status } }"
+
+	job := s.post(t, alice, jobQuery, map[string]any{"id": id})
+	assert.Equal("QUEUED", job["job"].(map[string]any)["status"])
+	s.post(t, alice, "{ userSummary(userId: 1) { name } }", nil)
+	assert.Equal(1, s.cache.Stats().Len, "jobs have maxAge 0 whatever the default")
+
+	s.post(t, alice, "mutation($id: ID!) { cancelJob(id: $id) { id } }", map[string]any{"id": id})
+	assert.Equal(1, s.cache.Stats().Len, "cancelJob returns a Job, which no response holds")
+
+	job = s.post(t, alice, jobQuery, map[string]any{"id": id})
+	assert.Equal("CANCELLED", job["job"].(map[string]any)["status"])
+
+	s.cache.Invalidate("UserSummary")
+	assert.Equal(0, s.cache.Stats().Len)
+	s.post(t, alice, "{ userSummary(userId: 1) { name } }", nil)
+	assert.Equal(int32(2), s.users.calls.Load())
+}
+
+func Test_Cache_HitsKeepCacheControlHeaders(t *testing.T) {
+	assert := assert.New(t)
+	s := newServer(t, 0)
+	h := cachecontrol.Middleware(s)
+	target := "/query?query=" + url.QueryEscape("{ userSummary(userId: 1) { name } }")
+
+	var etags []string
+	for range 2 {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Regexp(`^public, max-age=(59|60)$`, w.Header().Get("Cache-Control"))
+		etags = append(etags, w.Header().Get("ETag"))
+	}
+	assert.Equal(int32(1), s.users.calls.Load())
+	assert.Equal(etags[0], etags[1])
+}
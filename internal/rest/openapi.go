@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"go-graphql-aggregator/internal/apierror"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPI generates the OpenAPI 3 document of the routes. Body schemas come
+// from the Go types through their json tags, so they can't drift from the
+// responses.
+func openAPI(rts []route) map[string]any {
+	schemas := map[string]any{}
+	content := func(t reflect.Type) map[string]any {
+		schema := schemaOf(t, schemas)
+		return map[string]any{
+			mediaJSON: map[string]any{"schema": schema},
+			mediaYAML: map[string]any{"schema": schema},
+		}
+	}
+
+	paths := map[string]any{}
+	for _, rt := range rts {
+		responses := map[string]any{
+			"200": map[string]any{"description": "OK", "content": content(rt.response)},
+		}
+		// every route can be rejected by content negotiation and the middlewares
+		errs := append([]int{http.StatusUnauthorized, http.StatusNotAcceptable, http.StatusTooManyRequests}, rt.errors...)
+		slices.Sort(errs)
+		for _, status := range errs {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     content(reflect.TypeFor[apierror.ErrorResponse]()),
+			}
+		}
+
+		op := map[string]any{
+			"operationId": rt.operationID,
+			"summary":     rt.summary,
+			"responses":   responses,
+			"security":    []any{map[string]any{}, map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}},
+		}
+		if len(rt.params) > 0 {
+			var params []any
+			for _, p := range rt.params {
+				params = append(params, map[string]any{
+					"name":        p.name,
+					"in":          "path",
+					"required":    true,
+					"description": p.description,
+					"schema":      map[string]any{"type": "integer", "minimum": 1},
+				})
+			}
+			op["parameters"] = params
+		}
+
+		item, _ := paths[rt.pattern].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.pattern] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "go-graphql-aggregator REST API",
+			"version":     "v1",
+			"description": "REST façade over the GraphQL aggregator. Errors use the GraphQL error shape.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-Api-Key"},
+			},
+		},
+	}
+}
+
+// schemaOf returns the schema of t, registering named structs in schemas and
+// referencing them.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schemas[t.Name()] = nil // placeholder, for recursive types
+
+		properties := map[string]any{}
+		var required []string
+		for _, f := range reflect.VisibleFields(t) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/logger"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is an error reported with an HTTP status and a code.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+const (
+	mediaJSON = "application/json"
+	mediaYAML = "application/yaml"
+)
+
+// negotiate picks the representation for an Accept header: JSON unless YAML
+// is preferred, and false when neither is acceptable.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaJSON, true
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		for _, offered := range []string{mediaJSON, mediaYAML} {
+			if q > bestQ && matches(mediaType, offered) {
+				best, bestQ = offered, q
+			}
+		}
+	}
+	return best, best != ""
+}
+
+func matches(accepted, offered string) bool {
+	if accepted == "*/*" || accepted == offered {
+		return true
+	}
+	if offered == mediaYAML && (accepted == "application/x-yaml" || accepted == "text/yaml") {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(accepted, "/*")
+	return ok && strings.HasPrefix(offered, prefix+"/")
+}
+
+func write(w http.ResponseWriter, mediaType string, status int, v any) {
+	body, err := encode(mediaType, v)
+	if err != nil {
+		logger.Log.Error("encoding rest response failed", "error", err)
+		status, mediaType = http.StatusInternalServerError, mediaJSON
+		body, _ = json.Marshal(apierror.New(apierror.CodeInternal, "encoding response failed"))
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, mediaType string, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		logger.Log.Error("rest request failed", "error", err)
+		e = &Error{Status: http.StatusInternalServerError, Code: apierror.CodeInternal, Message: "internal server error"}
+	}
+	write(w, mediaType, e.Status, apierror.New(e.Code, e.Message))
+}
+
+// encode marshals v as JSON, or as YAML with the same field names and order.
+func encode(mediaType string, v any) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil || mediaType != mediaYAML {
+		return append(body, '\n'), err
+	}
+	// JSON is YAML: decoding into a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(body, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle drops the flow style of the decoded JSON; the encoder still
+// quotes strings that would read as other types.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,173 @@
+// Package rest serves a REST façade over the aggregator for clients that
+// don't speak GraphQL, with an OpenAPI 3 document generated from its routes.
+package rest
+
+import (
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/logger"
+	"go-graphql-aggregator/internal/store"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Options wires the REST endpoints to the running server.
+type Options struct {
+	// Aggregator returns the aggregator of the current server.
+	Aggregator func() *aggregator.Aggregator
+	// Store is the local store, nil when disabled.
+	Store *store.Store
+}
+
+// UserSummary is the body of GET /api/v1/users/{id}/summary. Email is only
+// included for callers granted the read:pii scope.
+type UserSummary struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Email     *string `json:"email,omitempty"`
+	PostCount int     `json:"postCount"`
+}
+
+// SyncStatus is the body of GET /api/v1/sync-status.
+type SyncStatus struct {
+	LastSuccessAt   *time.Time `json:"lastSuccessAt,omitempty"`
+	LastAttemptAt   *time.Time `json:"lastAttemptAt,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	LastDurationMs  int64      `json:"lastDurationMs"`
+	Users           int        `json:"users"`
+	Posts           int        `json:"posts"`
+	Summaries       int        `json:"summaries"`
+	AvgPostsPerUser float64    `json:"avgPostsPerUser"`
+	MaxPostsPerUser int        `json:"maxPostsPerUser"`
+}
+
+// route is an endpoint; the mux and the OpenAPI document are both built from
+// the routes.
+type route struct {
+	method      string
+	pattern     string
+	operationID string
+	summary     string
+	params      []param
+	response    reflect.Type
+	errors      []int
+	handle      func(r *http.Request) (any, error)
+}
+
+// param is a path parameter.
+type param struct {
+	name        string
+	description string
+}
+
+func routes(opts Options) []route {
+	return []route{
+		{
+			method:      http.MethodGet,
+			pattern:     "/api/v1/users/{id}/summary",
+			operationID: "getUserSummary",
+			summary:     "Summary of a user and their posts, like the userSummary query",
+			params:      []param{{"id", "user ID"}},
+			response:    reflect.TypeFor[UserSummary](),
+			errors:      append([]int{http.StatusBadRequest}, upstreamStatuses...),
+			handle: func(r *http.Request) (any, error) {
+				id, err := strconv.Atoi(r.PathValue("id"))
+				if err != nil || id <= 0 {
+					return nil, &Error{Status: http.StatusBadRequest, Code: apierror.CodeBadRequest, Message: fmt.Sprintf("invalid user ID %q", r.PathValue("id"))}
+				}
+				summary, err := opts.Aggregator().GetUserSummary(r.Context(), id)
+				if err != nil {
+					return nil, upstreamError(err)
+				}
+				res := &UserSummary{ID: id, Name: summary.Name, PostCount: summary.PostCount}
+				if auth.FromContext(r.Context()).HasScope("read:pii") {
+					res.Email = &summary.Email
+				}
+				return res, nil
+			},
+		},
+		{
+			method:      http.MethodGet,
+			pattern:     "/api/v1/sync-status",
+			operationID: "getSyncStatus",
+			summary:     "Syncs of the local store, like the syncStatus query",
+			response:    reflect.TypeFor[SyncStatus](),
+			errors:      []int{http.StatusNotFound},
+			handle: func(r *http.Request) (any, error) {
+				if opts.Store == nil {
+					return nil, &Error{Status: http.StatusNotFound, Code: apierror.CodeNotFound, Message: "the local store is disabled"}
+				}
+				status, err := opts.Store.Status()
+				if err != nil {
+					return nil, err
+				}
+				res := &SyncStatus{
+					LastError:       status.LastError,
+					LastDurationMs:  status.LastDuration.Milliseconds(),
+					Users:           status.Users,
+					Posts:           status.Posts,
+					Summaries:       status.Summaries,
+					AvgPostsPerUser: status.AvgPostsPerUser,
+					MaxPostsPerUser: status.MaxPostsPerUser,
+				}
+				if !status.LastSuccess.IsZero() {
+					res.LastSuccessAt = &status.LastSuccess
+				}
+				if !status.LastAttempt.IsZero() {
+					res.LastAttemptAt = &status.LastAttempt
+				}
+				return res, nil
+			},
+		},
+	}
+}
+
+// NewHandler returns the REST endpoints under /api/v1/ and the OpenAPI
+// document at /openapi.json.
+func NewHandler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	rts := routes(opts)
+	for _, rt := range rts {
+		mux.HandleFunc(rt.method+" "+rt.pattern, func(w http.ResponseWriter, r *http.Request) {
+			mediaType, ok := negotiate(r.Header.Get("Accept"))
+			if !ok {
+				writeError(w, mediaJSON, &Error{Status: http.StatusNotAcceptable, Code: apierror.CodeNotAcceptable,
+					Message: fmt.Sprintf("cannot produce %q, available: %s, %s", r.Header.Get("Accept"), mediaJSON, mediaYAML)})
+				return
+			}
+			res, err := rt.handle(r)
+			if err != nil {
+				writeError(w, mediaType, err)
+				return
+			}
+			write(w, mediaType, http.StatusOK, res)
+		})
+	}
+
+	doc := openAPI(rts)
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		write(w, mediaJSON, http.StatusOK, doc)
+	})
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, mediaJSON, &Error{Status: http.StatusNotFound, Code: apierror.CodeNotFound, Message: "no endpoint " + r.Method + " " + r.URL.Path})
+	})
+	return mux
+}
+
+// upstreamStatuses are the statuses upstreamError reports, documented for
+// the routes calling the aggregator.
+var upstreamStatuses = []int{http.StatusNotFound, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// upstreamError reports an aggregator error with the status of its code, one
+// of upstreamStatuses.
+func upstreamError(err error) error {
+	code := apierror.Code(err)
+	if code == apierror.CodeUpstream {
+		logger.Log.Error("rest request failed", "error", err)
+	}
+	return &Error{Status: apierror.Status(code), Code: code, Message: err.Error()}
+}
@@ -0,0 +1,153 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/apierror"
+	"go-graphql-aggregator/internal/auth"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/rest"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func newHandler(t *testing.T) http.Handler {
+	upstream := test.StartFakeUpstream(t)
+	agg := aggregator.NewAggregator(
+		&fetcher.HTTPUserFetcher{Client: http.DefaultClient, BaseURL: upstream.UsersURL()},
+		&fetcher.HTTPPostsFetcher{Client: http.DefaultClient, BaseURL: upstream.PostsURL()},
+		2*time.Second,
+	)
+	return rest.NewHandler(rest.Options{Aggregator: func() *aggregator.Aggregator { return agg }})
+}
+
+func get(h http.Handler, path, accept string, principal *auth.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func Test_UserSummary_Success(t *testing.T) {
+	assert := assert.New(t)
+	h := newHandler(t)
+
+	w := get(h, "/api/v1/users/1/summary", "", nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(`{"id":1,"name":"Leanne Graham","postCount":10}`, w.Body.String())
+
+	w = get(h, "/api/v1/users/1/summary", "", &auth.Principal{Scopes: []string{"read:pii"}})
+	assert.JSONEq(`{"id":1,"name":"Leanne Graham","email":"Sincere@april.biz","postCount":10}`, w.Body.String())
+}
+
+func Test_UserSummary_Errors(t *testing.T) {
+	assert := assert.New(t)
+	h := newHandler(t)
+
+	for path, want := range map[string]struct {
+		status int
+		code   string
+	}{
+		"/api/v1/users/abc/summary": {http.StatusBadRequest, apierror.CodeBadRequest},
+		"/api/v1/users/0/summary":   {http.StatusBadRequest, apierror.CodeBadRequest},
+		"/api/v1/users/42/summary":  {http.StatusNotFound, apierror.CodeNotFound},
+		"/api/v1/nope":              {http.StatusNotFound, apierror.CodeNotFound},
+		"/api/v1/sync-status":       {http.StatusNotFound, apierror.CodeNotFound},
+	} {
+		w := get(h, path, "", nil)
+		assert.Equal(want.status, w.Code, path)
+
+		var body apierror.ErrorResponse
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), &body), path)
+		if assert.Len(body.Errors, 1, path) {
+			assert.Equal(want.code, body.Errors[0].Extensions.Code, path)
+			assert.NotEmpty(body.Errors[0].Message, path)
+		}
+	}
+}
+
+func Test_ContentNegotiation(t *testing.T) {
+	assert := assert.New(t)
+	h := newHandler(t)
+
+	w := get(h, "/api/v1/users/1/summary", "application/yaml", nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/yaml", w.Header().Get("Content-Type"))
+	assert.Equal("id: 1\nname: Leanne Graham\npostCount: 10\n", w.Body.String())
+
+	w = get(h, "/api/v1/users/1/summary", "application/json;q=0.5, application/yaml;q=0.9", nil)
+	assert.Equal("application/yaml", w.Header().Get("Content-Type"))
+
+	w = get(h, "/api/v1/users/1/summary", "text/html, */*;q=0.1", nil)
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+
+	w = get(h, "/api/v1/users/1/summary", "text/html", nil)
+	assert.Equal(http.StatusNotAcceptable, w.Code)
+	assert.Contains(w.Body.String(), apierror.CodeNotAcceptable)
+}
+
+func Test_OpenAPI(t *testing.T) {
+	assert := assert.New(t)
+	h := newHandler(t)
+
+	w := get(h, "/openapi.json", "", nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string
+		Paths      map[string]map[string]map[string]any
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+				Required   []string
+			}
+		}
+	}
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal("3.0.3", doc.OpenAPI)
+	assert.Equal("getUserSummary", doc.Paths["/api/v1/users/{id}/summary"]["get"]["operationId"])
+	assert.Contains(doc.Paths["/api/v1/users/{id}/summary"]["get"]["responses"], "404")
+	assert.Contains(doc.Paths, "/api/v1/sync-status")
+
+	summary := doc.Components.Schemas["UserSummary"]
+	assert.Contains(summary.Properties, "email")
+	assert.ElementsMatch([]string{"id", "name", "postCount"}, summary.Required)
+	assert.Contains(doc.Components.Schemas, "ErrorResponse")
+}
+
+func Test_OpenAPI_DocumentsUpstreamErrors(t *testing.T) {
+	assert := assert.New(t)
+	var doc struct {
+		Paths map[string]map[string]struct{ Responses map[string]any }
+	}
+	assert.Nil(json.Unmarshal(get(newHandler(t), "/openapi.json", "", nil).Body.Bytes(), &doc))
+	documented := doc.Paths["/api/v1/users/{id}/summary"]["get"].Responses
+
+	for _, err := range []error{fetcher.ErrNotFound, context.DeadlineExceeded, fetcher.ErrCircuitOpen, errors.New("upstream down")} {
+		agg := aggregator.NewAggregator(&mock.MockUserFetcher{Err: err}, &mock.MockPostsFetcher{}, time.Second)
+		h := rest.NewHandler(rest.Options{Aggregator: func() *aggregator.Aggregator { return agg }})
+
+		w := get(h, "/api/v1/users/1/summary", "", nil)
+		assert.Contains(documented, strconv.Itoa(w.Code), err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"context"
+	"go-graphql-aggregator/internal/cachecontrol"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Extension reports, in the response extensions, the age of the snapshot
+// whenever data of the operation came from it:
+//
+//	"extensions": {"snapshot": {"createdAt": "2025-01-02T15:04:05Z", "ageSeconds": 3600}}
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "SnapshotAge"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	u := &usage{}
+	resp := next(context.WithValue(ctx, usageKey{}, u))
+	if resp == nil {
+		return nil
+	}
+
+	u.mu.Lock()
+	snap := u.snap
+	u.mu.Unlock()
+	if snap != nil {
+		if resp.Extensions == nil {
+			resp.Extensions = map[string]any{}
+		}
+		resp.Extensions["snapshot"] = map[string]any{
+			"createdAt":  snap.CreatedAt.Format(time.RFC3339),
+			"ageSeconds": int64(snap.Age().Seconds()),
+		}
+	}
+	return resp
+}
+
+type usageKey struct{}
+
+// usage records, per operation, the snapshot the fetchers served data from.
+type usage struct {
+	mu   sync.Mutex
+	snap *Snapshot
+}
+
+// recordUsage also makes the response uncacheable: the snapshot may be
+// stale, and its data must not outlive the outage it stands in for.
+func recordUsage(ctx context.Context, s *Snapshot) {
+	cachecontrol.Uncacheable(ctx)
+	if u, ok := ctx.Value(usageKey{}).(*usage); ok {
+		u.mu.Lock()
+		u.snap = s
+		u.mu.Unlock()
+	}
+}
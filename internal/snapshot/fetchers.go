@@ -0,0 +1,146 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/logger"
+	"net/http"
+)
+
+// ErrNotFound is returned for users absent from the snapshot; it matches
+// fetcher.ErrNotFound too.
+var ErrNotFound = fmt.Errorf("%w in snapshot", fetcher.ErrNotFound)
+
+// Fetchers serves a snapshot through the fetcher interfaces.
+type Fetchers struct {
+	snap  *Snapshot
+	users map[int]*fetcher.User
+	posts map[int][]fetcher.Post
+}
+
+// NewFetchers indexes s for lookups by user.
+func NewFetchers(s *Snapshot) *Fetchers {
+	f := &Fetchers{snap: s, users: map[int]*fetcher.User{}, posts: map[int][]fetcher.Post{}}
+	for i := range s.Users {
+		f.users[s.Users[i].ID] = &s.Users[i]
+	}
+	for _, p := range s.Posts {
+		f.posts[p.UserID] = append(f.posts[p.UserID], p)
+	}
+	return f
+}
+
+// Snapshot returns the snapshot served.
+func (f *Fetchers) Snapshot() *Snapshot {
+	return f.snap
+}
+
+// Users returns a fetcher.UserFetcher reading from the snapshot.
+func (f *Fetchers) Users() fetcher.UserFetcher {
+	return userFetcher{f}
+}
+
+// Posts returns a fetcher.PostsFetcher reading from the snapshot.
+func (f *Fetchers) Posts() fetcher.PostsFetcher {
+	return postsFetcher{f}
+}
+
+type userFetcher struct{ *Fetchers }
+
+func (f userFetcher) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	recordUsage(ctx, f.snap)
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	u := *user
+	return &u, nil
+}
+
+// List returns every user of the snapshot.
+func (f userFetcher) List(ctx context.Context) ([]fetcher.User, error) {
+	recordUsage(ctx, f.snap)
+	return append([]fetcher.User{}, f.snap.Users...), nil
+}
+
+type postsFetcher struct{ *Fetchers }
+
+// Fetch returns the posts of userID, or every post when userID is not positive,
+// like the upstream without a userId filter.
+func (f postsFetcher) Fetch(ctx context.Context, userID int) ([]fetcher.Post, error) {
+	recordUsage(ctx, f.snap)
+	if userID <= 0 {
+		return append([]fetcher.Post{}, f.snap.Posts...), nil
+	}
+	return append([]fetcher.Post{}, f.posts[userID]...), nil
+}
+
+// FallbackUserFetcher fetches from Upstream and, when it's unavailable, from
+// Snapshot. Answers of the upstream, such as a user it no longer has, are
+// returned as they are.
+type FallbackUserFetcher struct {
+	Upstream fetcher.UserFetcher
+	Snapshot fetcher.UserFetcher
+}
+
+func (f *FallbackUserFetcher) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	user, err := f.Upstream.Fetch(ctx, userID)
+	if err == nil || !unavailable(ctx, err) {
+		return user, err
+	}
+	logger.Log.Warn("users upstream failed, falling back to snapshot", "userId", userID, "error", err)
+	user, snapErr := f.Snapshot.Fetch(ctx, userID)
+	if snapErr != nil {
+		return nil, fmt.Errorf("%w; snapshot fallback: %v", err, snapErr)
+	}
+	return user, nil
+}
+
+// List lists the users of Upstream, if it can list them, falling back to
+// Snapshot.
+func (f *FallbackUserFetcher) List(ctx context.Context) ([]fetcher.User, error) {
+	if lister, ok := f.Upstream.(fetcher.UserLister); ok {
+		users, err := lister.List(ctx)
+		if err == nil || !unavailable(ctx, err) {
+			return users, err
+		}
+		logger.Log.Warn("users upstream failed listing, falling back to snapshot", "error", err)
+	}
+	lister, ok := f.Snapshot.(fetcher.UserLister)
+	if !ok {
+		return nil, errors.New("snapshot fetcher can't list users")
+	}
+	return lister.List(ctx)
+}
+
+// FallbackPostsFetcher fetches from Upstream and, when it's unavailable, from
+// Snapshot.
+type FallbackPostsFetcher struct {
+	Upstream fetcher.PostsFetcher
+	Snapshot fetcher.PostsFetcher
+}
+
+func (f *FallbackPostsFetcher) Fetch(ctx context.Context, userID int) ([]fetcher.Post, error) {
+	posts, err := f.Upstream.Fetch(ctx, userID)
+	if err == nil || !unavailable(ctx, err) {
+		return posts, err
+	}
+	logger.Log.Warn("posts upstream failed, falling back to snapshot", "userId", userID, "error", err)
+	return f.Snapshot.Fetch(ctx, userID)
+}
+
+// unavailable tells whether err means the upstream can't answer: a transport
+// error, a 5xx or an open circuit, not a 404 or another 4xx, nor a request the
+// caller cancelled.
+func unavailable(ctx context.Context, err error) bool {
+	var status *fetcher.StatusError
+	switch {
+	case errors.Is(err, fetcher.ErrNotFound), errors.Is(ctx.Err(), context.Canceled):
+		return false
+	case errors.As(err, &status):
+		return status.Code >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Package snapshot stores users, posts and comments downloaded from the
+// upstreams in a local file, and serves them through the fetcher interfaces
+// when the upstreams can't be used.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-graphql-aggregator/internal/fetcher"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FormatVersion is the version of the snapshot file format written by Save.
+// Load rejects files of other versions.
+const FormatVersion = 1
+
+// Snapshot is the content of a snapshot file.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Source    Source    `json:"source"`
+
+	Users    []fetcher.User    `json:"users"`
+	Posts    []fetcher.Post    `json:"posts"`
+	Comments []fetcher.Comment `json:"comments"`
+}
+
+// Source holds the upstream URLs a snapshot was downloaded from. An empty
+// CommentsURL skips comments.
+type Source struct {
+	UsersURL    string `json:"usersURL"`
+	PostsURL    string `json:"postsURL"`
+	CommentsURL string `json:"commentsURL,omitempty"`
+}
+
+// Age is how old the snapshot is.
+func (s *Snapshot) Age() time.Duration {
+	return time.Since(s.CreatedAt)
+}
+
+// Download fetches every user, post and comment of src with client.
+func Download(ctx context.Context, client fetcher.HTTPClient, src Source) (*Snapshot, error) {
+	s := &Snapshot{Version: FormatVersion, CreatedAt: time.Now().UTC(), Source: src}
+
+	if err := getJSON(ctx, client, src.UsersURL, &s.Users); err != nil {
+		return nil, fmt.Errorf("downloading users: %w", err)
+	}
+	if err := getJSON(ctx, client, src.PostsURL, &s.Posts); err != nil {
+		return nil, fmt.Errorf("downloading posts: %w", err)
+	}
+	if src.CommentsURL != "" {
+		if err := getJSON(ctx, client, src.CommentsURL, &s.Comments); err != nil {
+			return nil, fmt.Errorf("downloading comments: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func getJSON(ctx context.Context, client fetcher.HTTPClient, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// Load reads a snapshot file.
+func Load(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("decoding snapshot %s: %w", path, err)
+	}
+	if s.Version != FormatVersion {
+		return nil, fmt.Errorf("snapshot %s has format version %d, expected %d", path, s.Version, FormatVersion)
+	}
+	return &s, nil
+}
+
+// Save writes the snapshot to path atomically, so a server loading it never
+// sees a partial file.
+func (s *Snapshot) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating snapshot dir: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,154 @@
+package snapshot_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/snapshot"
+	"go-graphql-aggregator/internal/test"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func downloadSnapshot(t *testing.T) *snapshot.Snapshot {
+	upstream := test.StartFakeUpstream(t)
+	s, err := snapshot.Download(context.Background(), http.DefaultClient, snapshot.Source{
+		UsersURL:    upstream.UsersURL(),
+		PostsURL:    upstream.PostsURL(),
+		CommentsURL: strings.TrimSuffix(upstream.PostsURL(), "/posts") + "/comments",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_Snapshot_DownloadSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+	s := downloadSnapshot(t)
+	assert.Len(s.Users, 10)
+	assert.Len(s.Posts, 100)
+	assert.Len(s.Comments, 500)
+
+	path := filepath.Join(t.TempDir(), "data", "snapshot.json")
+	assert.Nil(s.Save(path))
+	loaded, err := snapshot.Load(path)
+	assert.Nil(err)
+	assert.Equal(snapshot.FormatVersion, loaded.Version)
+	assert.True(s.CreatedAt.Equal(loaded.CreatedAt))
+	assert.Equal(s.Users, loaded.Users)
+	assert.Equal(s.Comments, loaded.Comments)
+}
+
+func Test_Snapshot_LoadRejectsOtherVersions(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.Nil(os.WriteFile(path, []byte(`{"version": 99, "users": []}`), 0o600))
+
+	_, err := snapshot.Load(path)
+	assert.ErrorContains(err, "format version 99")
+}
+
+func Test_Fetchers_ServeTheSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	f := snapshot.NewFetchers(downloadSnapshot(t))
+
+	user, err := f.Users().Fetch(context.Background(), 2)
+	assert.Nil(err)
+	assert.Equal("Ervin Howell", user.Name)
+
+	posts, err := f.Posts().Fetch(context.Background(), 2)
+	assert.Nil(err)
+	assert.Len(posts, 10)
+	for _, p := range posts {
+		assert.Equal(2, p.UserID)
+	}
+
+	_, err = f.Users().Fetch(context.Background(), 42)
+	assert.ErrorIs(err, snapshot.ErrNotFound)
+}
+
+type failingUserFetcher struct {
+	calls int
+	err   error
+}
+
+func (f *failingUserFetcher) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return nil, errors.New("upstream down")
+}
+
+func Test_FallbackUserFetcher_UsesSnapshotWhenUpstreamFails(t *testing.T) {
+	assert := assert.New(t)
+	f := snapshot.NewFetchers(downloadSnapshot(t))
+	upstream := &failingUserFetcher{}
+	fallback := &snapshot.FallbackUserFetcher{Upstream: upstream, Snapshot: f.Users()}
+
+	user, err := fallback.Fetch(context.Background(), 3)
+	assert.Nil(err)
+	assert.Equal("Clementine Bauch", user.Name)
+	assert.Equal(1, upstream.calls)
+
+	_, err = fallback.Fetch(context.Background(), 42)
+	assert.ErrorContains(err, "upstream down")
+	assert.ErrorContains(err, "not found in snapshot")
+}
+
+func Test_FallbackUserFetcher_KeepsUpstreamAnswers(t *testing.T) {
+	assert := assert.New(t)
+	f := snapshot.NewFetchers(downloadSnapshot(t))
+
+	for _, tc := range []struct {
+		err      error
+		fallback bool
+	}{
+		{fmt.Errorf("fetching user: status code 404: %w", fetcher.ErrNotFound), false},
+		{fmt.Errorf("fetching user: %w", &fetcher.StatusError{Code: http.StatusBadRequest}), false},
+		{fmt.Errorf("fetching user: %w", &fetcher.StatusError{Code: http.StatusBadGateway}), true},
+		{fmt.Errorf("upstream users: %w", fetcher.ErrCircuitOpen), true},
+	} {
+		fallback := &snapshot.FallbackUserFetcher{Upstream: &failingUserFetcher{err: tc.err}, Snapshot: f.Users()}
+		user, err := fallback.Fetch(context.Background(), 3)
+		if tc.fallback {
+			assert.Nil(err, tc.err.Error())
+			assert.NotNil(user, tc.err.Error())
+		} else {
+			assert.ErrorIs(err, tc.err, "the upstream answer is returned as is")
+		}
+	}
+}
+
+func Test_Extension_ReportsAgeOnlyWhenSnapshotServed(t *testing.T) {
+	assert := assert.New(t)
+	f := snapshot.NewFetchers(downloadSnapshot(t))
+	ext := snapshot.Extension{}
+
+	resp := ext.InterceptResponse(context.Background(), func(ctx context.Context) *graphql.Response {
+		return &graphql.Response{}
+	})
+	assert.Nil(resp.Extensions)
+
+	resp = ext.InterceptResponse(context.Background(), func(ctx context.Context) *graphql.Response {
+		f.Users().Fetch(ctx, 1)
+		return &graphql.Response{}
+	})
+	info, ok := resp.Extensions["snapshot"].(map[string]any)
+	assert.True(ok)
+	assert.Equal(f.Snapshot().CreatedAt.Format(time.RFC3339), info["createdAt"])
+	assert.Contains(info, "ageSeconds")
+}
@@ -0,0 +1,392 @@
+// Package store keeps users and posts synced from the fetchers in an embedded
+// bbolt file, together with the summaries computed from them at each sync, so
+// summaries can be served without calling the upstreams.
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/logger"
+	"slices"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	bucketUsers     = []byte("users")
+	bucketPosts     = []byte("posts")
+	bucketSummaries = []byte("summaries")
+	bucketMeta      = []byte("meta")
+	bucketJobs      = []byte("jobs")
+	keyStatus       = []byte("status")
+)
+
+// Summary is the summary of a user precomputed at sync time.
+type Summary struct {
+	UserID    int    `json:"userId"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	PostCount int    `json:"postCount"`
+}
+
+// Status describes the syncs of a store. Counts and stats are those of the
+// last successful sync.
+type Status struct {
+	LastSuccess  time.Time     `json:"lastSuccess"`
+	LastAttempt  time.Time     `json:"lastAttempt"`
+	LastError    string        `json:"lastError,omitempty"`
+	LastDuration time.Duration `json:"lastDuration"`
+
+	Users           int     `json:"users"`
+	Posts           int     `json:"posts"`
+	Summaries       int     `json:"summaries"`
+	AvgPostsPerUser float64 `json:"avgPostsPerUser"`
+	MaxPostsPerUser int     `json:"maxPostsPerUser"`
+}
+
+// Store is a materialized view of the upstream data. It is safe for concurrent
+// use; syncs run one at a time.
+type Store struct {
+	// OnSync, if set, is called after each successful sync, such as to drop
+	// the responses cached from the previous data. Set it before syncing.
+	OnSync func()
+
+	db *bolt.DB
+
+	syncMu sync.Mutex
+
+	mu    sync.Mutex
+	users fetcher.UserFetcher
+	posts fetcher.PostsFetcher
+}
+
+// Open opens, or creates, the store file at path. The file is locked while
+// open, so it can't be shared between processes.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketUsers, bucketPosts, bucketSummaries, bucketMeta, bucketJobs} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing store %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SetFetchers sets the fetchers the next syncs read from.
+func (s *Store) SetFetchers(users fetcher.UserFetcher, posts fetcher.PostsFetcher) {
+	s.mu.Lock()
+	s.users, s.posts = users, posts
+	s.mu.Unlock()
+}
+
+// Run syncs right away and then every interval until ctx is done. Failed
+// syncs are logged and recorded in the status; the previous data stays.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			logger.Log.Error("store sync failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches every post and every user, listed when the user fetcher is a
+// fetcher.UserLister or else those who wrote posts or were already stored,
+// and replaces the stored data and summaries in one transaction.
+func (s *Store) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	users, posts := s.users, s.posts
+	s.mu.Unlock()
+	if users == nil || posts == nil {
+		return errors.New("store has no fetchers")
+	}
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	start := time.Now()
+	status, err := s.Status()
+	if err != nil {
+		return err
+	}
+	status.LastAttempt = start.UTC()
+
+	syncErr := s.sync(ctx, users, posts, &status)
+	if syncErr == nil {
+		logger.Log.Info("store synced", "users", status.Users, "posts", status.Posts, "elapsed_ms", status.LastDuration.Milliseconds())
+		if s.OnSync != nil {
+			s.OnSync()
+		}
+		return nil
+	}
+
+	status.LastDuration = time.Since(start)
+	status.LastError = syncErr.Error()
+	if err := s.db.Update(func(tx *bolt.Tx) error { return putJSON(tx.Bucket(bucketMeta), keyStatus, status) }); err != nil {
+		return errors.Join(syncErr, fmt.Errorf("saving store status: %w", err))
+	}
+	return syncErr
+}
+
+func (s *Store) sync(ctx context.Context, users fetcher.UserFetcher, posts fetcher.PostsFetcher, status *Status) error {
+	allPosts, err := posts.Fetch(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("fetching posts: %w", err)
+	}
+
+	postCounts := map[int]int{}
+	for _, p := range allPosts {
+		postCounts[p.UserID]++
+	}
+
+	var allUsers []*fetcher.User
+	if lister, ok := users.(fetcher.UserLister); ok {
+		allUsers, err = listUsers(ctx, lister, postCounts)
+	} else {
+		allUsers, err = s.fetchUsers(ctx, users, postCounts)
+	}
+	if err != nil {
+		return err
+	}
+
+	next := *status
+	next.Users, next.Posts, next.Summaries = len(allUsers), len(allPosts), len(allUsers)
+	next.AvgPostsPerUser, next.MaxPostsPerUser = 0, 0
+	if len(allUsers) > 0 {
+		next.AvgPostsPerUser = float64(len(allPosts)) / float64(len(allUsers))
+	}
+	for _, n := range postCounts {
+		next.MaxPostsPerUser = max(next.MaxPostsPerUser, n)
+	}
+	next.LastSuccess, next.LastError = next.LastAttempt, ""
+	next.LastDuration = time.Since(next.LastAttempt)
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketUsers, bucketPosts, bucketSummaries} {
+			if err := tx.DeleteBucket(b); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(b); err != nil {
+				return err
+			}
+		}
+		for _, u := range allUsers {
+			if err := putJSON(tx.Bucket(bucketUsers), idKey(u.ID), u); err != nil {
+				return err
+			}
+			summary := Summary{UserID: u.ID, Name: u.Name, Email: u.Email, PostCount: postCounts[u.ID]}
+			if err := putJSON(tx.Bucket(bucketSummaries), idKey(u.ID), summary); err != nil {
+				return err
+			}
+		}
+		for _, p := range allPosts {
+			if err := putJSON(tx.Bucket(bucketPosts), idKey(p.ID), p); err != nil {
+				return err
+			}
+		}
+		return putJSON(tx.Bucket(bucketMeta), keyStatus, next)
+	})
+	if err != nil {
+		return fmt.Errorf("saving synced data: %w", err)
+	}
+	*status = next
+	return nil
+}
+
+// listUsers lists every user with lister, so users without posts are stored
+// too, and leaves out of postCounts the posts of users it doesn't list.
+func listUsers(ctx context.Context, lister fetcher.UserLister, postCounts map[int]int) ([]*fetcher.User, error) {
+	listed, err := lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	allUsers := make([]*fetcher.User, len(listed))
+	ids := make(map[int]bool, len(listed))
+	for i := range listed {
+		allUsers[i] = &listed[i]
+		ids[listed[i].ID] = true
+		postCounts[listed[i].ID] += 0
+	}
+	for id := range postCounts {
+		if !ids[id] {
+			delete(postCounts, id)
+		}
+	}
+	return allUsers, nil
+}
+
+// fetchUsers fetches, one by one, the users who wrote posts or were already
+// stored, for user fetchers that can't list. Users not found upstream are
+// left out, and of postCounts.
+func (s *Store) fetchUsers(ctx context.Context, users fetcher.UserFetcher, postCounts map[int]int) ([]*fetcher.User, error) {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUsers).ForEach(func(k, _ []byte) error {
+			if _, ok := postCounts[keyID(k)]; !ok {
+				postCounts[keyID(k)] = 0
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading stored users: %w", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		allUsers []*fetcher.User
+		deleted  []int
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+	for id := range postCounts {
+		g.Go(func() error {
+			u, err := users.Fetch(gctx, id)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, fetcher.ErrNotFound):
+				// deleted upstream: left out of the rebuilt buckets
+				deleted = append(deleted, id)
+			case err != nil:
+				return fmt.Errorf("fetching user %d: %w", id, err)
+			default:
+				allUsers = append(allUsers, u)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for _, id := range deleted {
+		delete(postCounts, id)
+	}
+	if len(deleted) > 0 {
+		logger.Log.Info("store dropped users deleted upstream", "users", len(deleted))
+	}
+	return allUsers, nil
+}
+
+// Status returns the status of the syncs so far.
+func (s *Store) Status() (Status, error) {
+	var status Status
+	err := s.db.View(func(tx *bolt.Tx) error {
+		_, err := getJSON(tx.Bucket(bucketMeta), keyStatus, &status)
+		return err
+	})
+	if err != nil {
+		return Status{}, fmt.Errorf("reading store status: %w", err)
+	}
+	return status, nil
+}
+
+// UserSummary returns the stored summary of userID when the last successful
+// sync is at most maxAge old; a maxAge of 0 accepts any age. It implements
+// aggregator.SummaryStore.
+func (s *Store) UserSummary(ctx context.Context, userID int, maxAge time.Duration) (*aggregator.UserSummary, bool) {
+	var (
+		status  Status
+		summary Summary
+		found   bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if ok, err := getJSON(tx.Bucket(bucketMeta), keyStatus, &status); err != nil || !ok {
+			return err
+		}
+		if maxAge > 0 && time.Since(status.LastSuccess) > maxAge {
+			return nil
+		}
+		var err error
+		found, err = getJSON(tx.Bucket(bucketSummaries), idKey(userID), &summary)
+		return err
+	})
+	if err != nil {
+		logger.Log.Error("reading store summary failed", "userId", userID, "error", err)
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+	return &aggregator.UserSummary{Name: summary.Name, Email: summary.Email, PostCount: summary.PostCount}, true
+}
+
+// SaveJob stores the encoded job id, replacing any previous one. Jobs are
+// opaque to the store and survive syncs.
+func (s *Store) SaveJob(id string, data []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketJobs).Put([]byte(id), data)
+	})
+}
+
+// DeleteJob removes the job id, if stored.
+func (s *Store) DeleteJob(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketJobs).Delete([]byte(id))
+	})
+}
+
+// Jobs returns every stored job, encoded as saved.
+func (s *Store) Jobs() ([][]byte, error) {
+	var jobs [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketJobs).ForEach(func(_, v []byte) error {
+			jobs = append(jobs, slices.Clone(v))
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+func putJSON(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func getJSON(b *bolt.Bucket, key []byte, v any) (bool, error) {
+	data := b.Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// idKey encodes ids big-endian, so keys sort by id.
+func idKey(id int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+func keyID(key []byte) int {
+	return int(binary.BigEndian.Uint64(key))
+}
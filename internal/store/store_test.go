@@ -0,0 +1,180 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/store"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/test/mock"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+func openSyncedStore(t *testing.T) (*store.Store, string) {
+	upstream := test.StartFakeUpstream(t)
+	path := filepath.Join(t.TempDir(), "store.db")
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	st.SetFetchers(
+		&fetcher.HTTPUserFetcher{Client: http.DefaultClient, BaseURL: upstream.UsersURL()},
+		&fetcher.HTTPPostsFetcher{Client: http.DefaultClient, BaseURL: upstream.PostsURL()},
+	)
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return st, path
+}
+
+func Test_Store_SyncComputesSummariesAndStats(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+
+	status, err := st.Status()
+	assert.Nil(err)
+	assert.Equal(10, status.Users)
+	assert.Equal(100, status.Posts)
+	assert.Equal(10, status.Summaries)
+	assert.Equal(10.0, status.AvgPostsPerUser)
+	assert.Equal(10, status.MaxPostsPerUser)
+	assert.Empty(status.LastError)
+	assert.Equal(status.LastAttempt, status.LastSuccess)
+
+	summary, ok := st.UserSummary(context.Background(), 1, time.Minute)
+	assert.True(ok)
+	assert.Equal("Leanne Graham", summary.Name)
+	assert.Equal("Sincere@april.biz", summary.Email)
+	assert.Equal(10, summary.PostCount)
+
+	_, ok = st.UserSummary(context.Background(), 42, 0)
+	assert.False(ok)
+}
+
+func Test_Store_StaleSummariesAreNotServed(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := st.UserSummary(context.Background(), 1, time.Millisecond)
+	assert.False(ok)
+	_, ok = st.UserSummary(context.Background(), 1, 0)
+	assert.True(ok, "zero max age accepts any age")
+}
+
+func Test_Store_OnSyncFollowsSuccessfulSyncs(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+	synced := 0
+	st.OnSync = func() { synced++ }
+
+	assert.Nil(st.Sync(context.Background()))
+	assert.Equal(1, synced)
+
+	st.SetFetchers(&mock.MockUserFetcher{}, &mock.MockPostsFetcher{Err: errors.New("posts down")})
+	assert.NotNil(st.Sync(context.Background()))
+	assert.Equal(1, synced, "failed syncs keep the previous data")
+}
+
+func Test_Store_FailedSyncKeepsPreviousData(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+	before, _ := st.Status()
+
+	st.SetFetchers(&mock.MockUserFetcher{}, &mock.MockPostsFetcher{Err: errors.New("posts down")})
+	assert.ErrorContains(st.Sync(context.Background()), "posts down")
+
+	status, err := st.Status()
+	assert.Nil(err)
+	assert.Contains(status.LastError, "posts down")
+	assert.Equal(before.LastSuccess, status.LastSuccess)
+	assert.True(status.LastAttempt.After(before.LastAttempt) || status.LastAttempt.Equal(before.LastAttempt))
+	assert.Equal(10, status.Users)
+
+	_, ok := st.UserSummary(context.Background(), 1, 0)
+	assert.True(ok)
+}
+
+// deletingUsers finds every user but the deleted one.
+type deletingUsers struct{ deleted int }
+
+func (d deletingUsers) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	if userID == d.deleted {
+		return nil, fetcher.ErrNotFound
+	}
+	return &fetcher.User{ID: userID, Name: "John Doe"}, nil
+}
+
+func Test_Store_SyncDropsDeletedUsers(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+
+	st.SetFetchers(deletingUsers{deleted: 3}, &mock.MockPostsFetcher{Posts: []fetcher.Post{{ID: 1, UserID: 1}}})
+	assert.Nil(st.Sync(context.Background()))
+
+	status, err := st.Status()
+	assert.Nil(err)
+	assert.Empty(status.LastError)
+	assert.Equal(9, status.Users)
+	_, ok := st.UserSummary(context.Background(), 3, 0)
+	assert.False(ok)
+	_, ok = st.UserSummary(context.Background(), 4, 0)
+	assert.True(ok)
+}
+
+// listingUsers lists users, and fails fetching them one by one.
+type listingUsers []fetcher.User
+
+func (l listingUsers) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	return nil, errors.New("fetched instead of listed")
+}
+
+func (l listingUsers) List(ctx context.Context) ([]fetcher.User, error) {
+	return l, nil
+}
+
+func Test_Store_SyncStoresListedUsersWithoutPosts(t *testing.T) {
+	assert := assert.New(t)
+	st, _ := openSyncedStore(t)
+
+	users := listingUsers{{ID: 1, Name: "Leanne Graham"}, {ID: 11, Name: "John Doe"}}
+	st.SetFetchers(users, &mock.MockPostsFetcher{Posts: []fetcher.Post{{ID: 1, UserID: 1}, {ID: 2, UserID: 42}}})
+	assert.Nil(st.Sync(context.Background()))
+
+	status, err := st.Status()
+	assert.Nil(err)
+	assert.Equal(2, status.Users)
+	summary, ok := st.UserSummary(context.Background(), 11, 0)
+	assert.True(ok)
+	assert.Equal(0, summary.PostCount)
+	summary, ok = st.UserSummary(context.Background(), 1, 0)
+	assert.True(ok)
+	assert.Equal(1, summary.PostCount)
+	_, ok = st.UserSummary(context.Background(), 2, 0)
+	assert.False(ok, "users no longer listed are dropped")
+}
+
+func Test_Store_DataSurvivesReopen(t *testing.T) {
+	assert := assert.New(t)
+	st, path := openSyncedStore(t)
+	assert.Nil(st.Close())
+
+	reopened, err := store.Open(path)
+	assert.Nil(err)
+	defer reopened.Close()
+
+	summary, ok := reopened.UserSummary(context.Background(), 2, 0)
+	assert.True(ok)
+	assert.Equal("Ervin Howell", summary.Name)
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"bytes"
+	"go-graphql-aggregator/internal/logger"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// LogCapture guarda tudo que foi logado via logger.Log durante um teste,
+// passando pela mesma cadeia de handlers de produção (com redação de PII).
+type LogCapture struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// CaptureLogs redireciona logger.Log para um buffer, em nível debug, até o fim do teste.
+func CaptureLogs(t testing.TB) *LogCapture {
+	t.Helper()
+	c := &LogCapture{}
+	rules := logger.DefaultRedactRules()
+
+	previous, previousLevel := logger.Log, logger.Level()
+	logger.Log = slog.New(logger.NewHandler(c, logger.Options{Mode: "json", Redact: &rules}))
+	logger.SetLevel(slog.LevelDebug)
+	t.Cleanup(func() {
+		logger.Log = previous
+		logger.SetLevel(previousLevel)
+	})
+	return c
+}
+
+// Write implementa io.Writer.
+func (c *LogCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// String retorna os logs capturados.
+func (c *LogCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// AssertNoPII falha o teste se algum dos valores informados, ou qualquer e-mail
+// ou telefone, aparecer nos logs capturados.
+func (c *LogCapture) AssertNoPII(t testing.TB, values ...string) {
+	t.Helper()
+	out := c.String()
+	for _, v := range values {
+		if v != "" && bytes.Contains([]byte(out), []byte(v)) {
+			t.Errorf("PII %q leaked into logs:\n%s", v, out)
+		}
+	}
+	for _, p := range logger.DefaultRedactRules().Patterns {
+		if m := p.FindString(out); m != "" {
+			t.Errorf("PII %q matching %s leaked into logs:\n%s", m, p, out)
+		}
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 // SetupTests inicializa o logger para evitar panic em todos os pacotes de teste
 func SetupTests(m *testing.M) {
-	os.Setenv("LOG_MODE", "silent")
-	logger.Init()
+	logger.Init(logger.Options{Mode: "silent"})
 	os.Exit(m.Run())
 }
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"go-graphql-aggregator/internal/fakeupstream"
+	"net/http/httptest"
+	"testing"
+)
+
+// FakeUpstream é um fakeupstream.Server ouvindo em uma porta local durante o teste.
+type FakeUpstream struct {
+	*fakeupstream.Server
+	URL string
+}
+
+// UsersURL e PostsURL são as base URLs esperadas pelos fetchers.
+func (f *FakeUpstream) UsersURL() string { return f.URL + "/users" }
+func (f *FakeUpstream) PostsURL() string { return f.URL + "/posts" }
+
+// StartFakeUpstream sobe o fake upstream com as fixtures embutidas, sem falhas
+// e com seed fixa, encerrando-o no fim do teste.
+func StartFakeUpstream(t testing.TB) *FakeUpstream {
+	t.Helper()
+	srv, err := fakeupstream.New(fakeupstream.Options{Seed: 1})
+	if err != nil {
+		t.Fatalf("starting fake upstream: %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return &FakeUpstream{Server: srv, URL: ts.URL}
+}
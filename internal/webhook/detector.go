@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/logger"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Detector aggregates the summaries of every user periodically and publishes
+// an event for each change since the previous aggregation. The first one only
+// records the summaries; users that fail keep their previous summary.
+type Detector struct {
+	// Aggregator returns the aggregator used by each check; its user fetcher
+	// must implement fetcher.UserLister.
+	Aggregator func() *aggregator.Aggregator
+	Publish    func(Event)
+	// Active reports whether anyone listens; checks are skipped otherwise.
+	Active func() bool
+	// Concurrency is how many summaries are aggregated at once.
+	Concurrency int
+
+	mu   sync.Mutex
+	last map[int]aggregator.UserSummary
+}
+
+// Run checks right away and then every interval until ctx is done.
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if d.Active == nil || d.Active() {
+			if _, err := d.Check(ctx); err != nil && ctx.Err() == nil {
+				logger.Log.Error("webhook change check failed", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check aggregates every user, publishes the changes and returns them.
+func (d *Detector) Check(ctx context.Context) ([]Event, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	agg := d.Aggregator()
+	lister, ok := agg.UserFetcher.(fetcher.UserLister)
+	if !ok {
+		return nil, errors.New("the user fetcher can't list users")
+	}
+	users, err := lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		current = make(map[int]aggregator.UserSummary, len(users))
+		failed  int
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(d.Concurrency, 1))
+	for _, u := range users {
+		g.Go(func() error {
+			summary, err := agg.GetUserSummary(gctx, u.ID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				if prev, ok := d.last[u.ID]; ok {
+					current[u.ID] = prev
+				}
+				return nil
+			}
+			current[u.ID] = *summary
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	if d.last != nil {
+		now := time.Now().UTC()
+		for id, cur := range current {
+			prev, ok := d.last[id]
+			if !ok {
+				continue
+			}
+			for _, t := range changes(prev, cur) {
+				events = append(events, Event{
+					ID:         rand.Text(),
+					Type:       t,
+					UserID:     id,
+					OccurredAt: now,
+					Previous:   Summary{Name: prev.Name, PostCount: prev.PostCount},
+					Current:    Summary{Name: cur.Name, PostCount: cur.PostCount},
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(events, func(a, b Event) int { return cmp.Compare(a.UserID, b.UserID) })
+	d.last = current
+
+	for _, ev := range events {
+		d.Publish(ev)
+	}
+	logger.Log.Info("webhook changes checked", "users", len(users), "failed", failed, "events", len(events))
+	return events, nil
+}
+
+func changes(prev, cur aggregator.UserSummary) []EventType {
+	var types []EventType
+	if prev.PostCount != cur.PostCount {
+		types = append(types, PostCountChanged)
+	}
+	if prev.Name != cur.Name {
+		types = append(types, NameChanged)
+	}
+	if prev.Email != cur.Email {
+		types = append(types, EmailChanged)
+	}
+	return types
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" of
+// "<t>.<body>" with the subscription secret. The timestamp lets receivers
+// reject replays.
+const SignatureHeader = "X-Webhook-Signature"
+
+// Sign returns the SignatureHeader value of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac(secret, timestamp, body)))
+}
+
+// Verify checks a SignatureHeader value against body, rejecting timestamps
+// more than tolerance away from now; a tolerance of 0 accepts any.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var (
+		timestamp int64
+		signature []byte
+	)
+	for part := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signature, _ = hex.DecodeString(value)
+		}
+	}
+	if timestamp == 0 || signature == nil {
+		return errors.New("malformed signature")
+	}
+	if age := time.Since(time.Unix(timestamp, 0)).Abs(); tolerance > 0 && age > tolerance {
+		return fmt.Errorf("signature timestamp off by %s", age.Round(time.Second))
+	}
+	if !hmac.Equal(signature, mac(secret, timestamp, body)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(body)
+	return h.Sum(nil)
+}
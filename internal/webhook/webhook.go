@@ -0,0 +1,411 @@
+// Package webhook notifies subscribers over HTTP when user summaries change.
+// Deliveries are signed with the subscription secret and retried with
+// exponential backoff; those that keep failing end up in a dead-letter list.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of change an event notifies.
+type EventType string
+
+const (
+	PostCountChanged EventType = "POST_COUNT_CHANGED"
+	NameChanged      EventType = "NAME_CHANGED"
+	EmailChanged     EventType = "EMAIL_CHANGED"
+)
+
+// EventTypes are every event type, the ones of a subscription without events.
+var EventTypes = []EventType{PostCountChanged, NameChanged, EmailChanged}
+
+// Summary is the part of a user summary sent in events. Emails are never
+// sent: EMAIL_CHANGED only tells the email changed.
+type Summary struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"postCount"`
+}
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	UserID     int       `json:"userId"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Previous   Summary   `json:"previous"`
+	Current    Summary   `json:"current"`
+}
+
+// Subscription sends the events of its types to URL.
+type Subscription struct {
+	ID     string      `json:"id"`
+	URL    string      `json:"url"`
+	Secret string      `json:"-"`
+	Events []EventType `json:"events"`
+	// FromConfig subscriptions come from the WEBHOOKS setting and can't be
+	// deleted.
+	FromConfig bool      `json:"fromConfig"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Wants reports whether the subscription receives events of type t.
+func (s *Subscription) Wants(t EventType) bool {
+	return slices.Contains(s.Events, t)
+}
+
+// DeliveryStatus is the state of a delivery.
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "PENDING"
+	StatusDelivered DeliveryStatus = "DELIVERED"
+	StatusDead      DeliveryStatus = "DEAD"
+)
+
+// Delivery is the sending of an event to a subscription.
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	Event          Event
+	Status         DeliveryStatus
+	Attempts       int
+	// LastStatusCode is 0 when the last attempt got no response.
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+	NextAttemptAt  time.Time
+}
+
+var ErrNotFound = errors.New("not found")
+
+// Options configures a Manager.
+type Options struct {
+	Client fetcher.HTTPClient
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered. Retries wait Backoff, doubled after each attempt up to
+	// MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// HistorySize bounds the deliveries kept, and the dead letters.
+	HistorySize int
+}
+
+// Manager holds the subscriptions and delivers the events published to them.
+// It is safe for concurrent use.
+type Manager struct {
+	opts   Options
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu          sync.Mutex
+	subs        []*Subscription
+	history     []*Delivery
+	deadLetters []*Delivery
+}
+
+// NewManager returns a manager delivering to subs, the subscriptions of the
+// config. Call Close to stop the deliveries in progress.
+func NewManager(opts Options, subs []*Subscription) *Manager {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	opts.MaxAttempts = max(opts.MaxAttempts, 1)
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(time.Minute, opts.Backoff)
+	}
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = 500
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{opts: opts, ctx: ctx, cancel: cancel, subs: slices.Clone(subs)}
+}
+
+// Close abandons the pending deliveries and waits for the attempts in flight.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Subscriptions returns copies of the subscriptions, oldest first.
+func (m *Manager) Subscriptions() []Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := make([]Subscription, len(m.subs))
+	for i, s := range m.subs {
+		subs[i] = *s
+	}
+	return subs
+}
+
+// Subscribe adds a subscription of rawURL to events, every event when empty,
+// signed with secret, or with a generated secret when empty.
+func (m *Manager) Subscribe(rawURL string, events []EventType, secret string) (*Subscription, error) {
+	sub, err := newSubscription(rawURL, secret, events)
+	if err != nil {
+		return nil, err
+	}
+	sub.ID = rand.Text()
+	m.mu.Lock()
+	m.subs = append(m.subs, sub)
+	m.mu.Unlock()
+	logger.Log.Info("webhook subscribed", "webhook", sub.ID, "url", sub.URL, "events", sub.Events)
+	c := *sub
+	return &c, nil
+}
+
+// Unsubscribe deletes the subscription id; deliveries in progress go on.
+func (m *Manager) Unsubscribe(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.subs, func(s *Subscription) bool { return s.ID == id })
+	if i < 0 {
+		return fmt.Errorf("webhook %s: %w", id, ErrNotFound)
+	}
+	if m.subs[i].FromConfig {
+		return fmt.Errorf("webhook %s comes from the config and can't be deleted", id)
+	}
+	m.subs = slices.Delete(m.subs, i, i+1)
+	logger.Log.Info("webhook unsubscribed", "webhook", id)
+	return nil
+}
+
+// Publish delivers ev to every subscription of its type, in the background.
+func (m *Manager) Publish(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sub := range m.subs {
+		if sub.Wants(ev.Type) {
+			m.startLocked(sub, ev)
+		}
+	}
+}
+
+// Deliveries returns copies of the recent deliveries, newest first, filtered
+// by subscription and status when not empty. StatusDead lists the dead
+// letters, which are kept apart so they outlive the history.
+func (m *Manager) Deliveries(subscriptionID string, status DeliveryStatus, limit int) []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	source := m.history
+	if status == StatusDead {
+		source = m.deadLetters
+	}
+	var out []Delivery
+	for _, d := range slices.Backward(source) {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if (subscriptionID == "" || d.SubscriptionID == subscriptionID) && (status == "" || d.Status == status) {
+			out = append(out, *d)
+		}
+	}
+	return out
+}
+
+// Redeliver sends a dead letter again as a new delivery and removes it from
+// the dead letters.
+func (m *Manager) Redeliver(deliveryID string) (*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.deadLetters, func(d *Delivery) bool { return d.ID == deliveryID })
+	if i < 0 {
+		return nil, fmt.Errorf("dead letter %s: %w", deliveryID, ErrNotFound)
+	}
+	dead := m.deadLetters[i]
+	j := slices.IndexFunc(m.subs, func(s *Subscription) bool { return s.ID == dead.SubscriptionID })
+	if j < 0 {
+		return nil, fmt.Errorf("webhook %s: %w", dead.SubscriptionID, ErrNotFound)
+	}
+	m.deadLetters = slices.Delete(m.deadLetters, i, i+1)
+	d := *m.startLocked(m.subs[j], dead.Event)
+	return &d, nil
+}
+
+// startLocked records a delivery of ev to sub and starts it. Callers hold m.mu.
+func (m *Manager) startLocked(sub *Subscription, ev Event) *Delivery {
+	d := &Delivery{
+		ID:             rand.Text(),
+		SubscriptionID: sub.ID,
+		Event:          ev,
+		Status:         StatusPending,
+		CreatedAt:      time.Now().UTC(),
+	}
+	m.history = appendBounded(m.history, d, m.opts.HistorySize)
+	target := *sub
+	m.wg.Go(func() { m.deliver(&target, d) })
+	return d
+}
+
+func (m *Manager) deliver(sub *Subscription, d *Delivery) {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		m.finish(d, StatusDead, 0, err)
+		return
+	}
+	backoff := m.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		code, err := m.send(sub, d, body)
+		if err == nil {
+			m.finish(d, StatusDelivered, code, nil)
+			logger.Log.Info("webhook delivered", "webhook", sub.ID, "delivery", d.ID, "event", d.Event.Type, "attempts", attempt)
+			return
+		}
+		if attempt == m.opts.MaxAttempts || !retryable(code) {
+			m.finish(d, StatusDead, code, err)
+			logger.Log.Error("webhook dead-lettered", "webhook", sub.ID, "delivery", d.ID, "attempts", attempt, "error", err)
+			return
+		}
+
+		m.mu.Lock()
+		d.LastStatusCode, d.LastError, d.NextAttemptAt = code, err.Error(), time.Now().Add(backoff).UTC()
+		m.mu.Unlock()
+		logger.Log.Warn("webhook delivery failed, retrying", "webhook", sub.ID, "delivery", d.ID, "attempt", attempt, "backoff", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-m.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, m.opts.MaxBackoff)
+	}
+}
+
+// send makes one attempt, returning the response status code, if any.
+func (m *Manager) send(sub *Subscription, d *Delivery, body []byte) (int, error) {
+	m.mu.Lock()
+	d.Attempts++
+	m.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-graphql-aggregator-webhooks")
+	req.Header.Set("X-Webhook-Id", sub.ID)
+	req.Header.Set("X-Webhook-Delivery", d.ID)
+	req.Header.Set("X-Webhook-Event", string(d.Event.Type))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+
+	resp, err := m.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (m *Manager) finish(d *Delivery, status DeliveryStatus, code int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.Status, d.LastStatusCode, d.NextAttemptAt = status, code, time.Time{}
+	if err != nil {
+		d.LastError = err.Error()
+	}
+	if status == StatusDelivered {
+		d.DeliveredAt = time.Now().UTC()
+	} else {
+		m.deadLetters = appendBounded(m.deadLetters, d, m.opts.HistorySize)
+	}
+}
+
+// retryable reports whether an attempt answered with code may succeed later:
+// no response, timeouts, throttling and server errors.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+func appendBounded(s []*Delivery, d *Delivery, size int) []*Delivery {
+	if len(s) >= size {
+		s = slices.Delete(s, 0, len(s)-size+1)
+	}
+	return append(s, d)
+}
+
+func newSubscription(rawURL, secret string, events []EventType) (*Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q: expected an absolute http(s) URL", rawURL)
+	}
+	for _, t := range events {
+		if !slices.Contains(EventTypes, t) {
+			return nil, fmt.Errorf("unknown webhook event %q", t)
+		}
+	}
+	// in EventTypes order, without duplicates
+	var wanted []EventType
+	for _, t := range EventTypes {
+		if len(events) == 0 || slices.Contains(events, t) {
+			wanted = append(wanted, t)
+		}
+	}
+	if secret == "" {
+		secret = rand.Text()
+	}
+	return &Subscription{
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    wanted,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// ParseSubscriptions parses a webhook spec in the form
+// "url|secret|EVENT1,EVENT2;url2|...". Events are optional, every event
+// when omitted.
+func ParseSubscriptions(spec string) ([]*Subscription, error) {
+	var subs []*Subscription
+	for entry := range strings.SplitSeq(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid webhook entry %q: expected url|secret|events", parts[0])
+		}
+		var events []EventType
+		if len(parts) > 2 {
+			for e := range strings.SplitSeq(parts[2], ",") {
+				if e = strings.TrimSpace(e); e != "" {
+					events = append(events, EventType(e))
+				}
+			}
+		}
+		sub, err := newSubscription(parts[0], parts[1], events)
+		if err != nil {
+			return nil, err
+		}
+		sub.ID, sub.FromConfig = "config-"+strconv.Itoa(len(subs)+1), true
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
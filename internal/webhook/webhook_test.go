@@ -0,0 +1,253 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-graphql-aggregator/internal/aggregator"
+	"go-graphql-aggregator/internal/fetcher"
+	"go-graphql-aggregator/internal/test"
+	"go-graphql-aggregator/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTests(m)
+}
+
+// receiver records the events delivered to it, answering with the status
+// codes of fail first and 204 afterwards.
+type receiver struct {
+	*httptest.Server
+	mu     sync.Mutex
+	events []webhook.Event
+	errs   []error
+	calls  atomic.Int32
+	fail   []int
+}
+
+func newReceiver(t *testing.T, secret string, fail ...int) *receiver {
+	r := &receiver{fail: fail}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(r.calls.Add(1))
+		if n <= len(r.fail) {
+			w.WriteHeader(r.fail[n-1])
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		var ev webhook.Event
+		_ = json.Unmarshal(body, &ev)
+		r.mu.Lock()
+		r.events = append(r.events, ev)
+		r.errs = append(r.errs, webhook.Verify(secret, req.Header.Get(webhook.SignatureHeader), body, time.Minute))
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func waitForDelivery(t *testing.T, m *webhook.Manager, status webhook.DeliveryStatus) webhook.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if d := m.Deliveries("", status, 1); len(d) == 1 {
+			return d[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s delivery, history %+v", status, m.Deliveries("", "", 0))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var event = webhook.Event{
+	ID:       "ev1",
+	Type:     webhook.PostCountChanged,
+	UserID:   1,
+	Previous: webhook.Summary{Name: "Leanne Graham", PostCount: 10},
+	Current:  webhook.Summary{Name: "Leanne Graham", PostCount: 11},
+}
+
+func Test_Sign_Verify(t *testing.T) {
+	assert := assert.New(t)
+	body := []byte(`{"id":"ev1"}`)
+	now := time.Now().Unix()
+
+	header := webhook.Sign("s3cret", now, body)
+	assert.Nil(webhook.Verify("s3cret", header, body, time.Minute))
+	assert.NotNil(webhook.Verify("other", header, body, time.Minute))
+	assert.NotNil(webhook.Verify("s3cret", header, []byte(`{"id":"ev2"}`), time.Minute))
+	assert.NotNil(webhook.Verify("s3cret", "garbage", body, 0))
+
+	old := webhook.Sign("s3cret", now-3600, body)
+	assert.NotNil(webhook.Verify("s3cret", old, body, time.Minute))
+	assert.Nil(webhook.Verify("s3cret", old, body, 0))
+}
+
+func Test_ParseSubscriptions(t *testing.T) {
+	assert := assert.New(t)
+
+	subs, err := webhook.ParseSubscriptions("https://a.example/hook|s1|NAME_CHANGED,POST_COUNT_CHANGED; http://b.example|s2")
+	assert.Nil(err)
+	if assert.Len(subs, 2) {
+		assert.Equal("config-1", subs[0].ID)
+		assert.True(subs[0].FromConfig)
+		assert.Equal("s1", subs[0].Secret)
+		assert.Equal([]webhook.EventType{webhook.PostCountChanged, webhook.NameChanged}, subs[0].Events)
+		assert.Equal(webhook.EventTypes, subs[1].Events, "every event when omitted")
+	}
+
+	for _, spec := range []string{"https://a.example", "ftp://a.example|s", "https://a.example|s|NOPE", "/relative|s"} {
+		_, err := webhook.ParseSubscriptions(spec)
+		assert.NotNil(err, spec)
+	}
+}
+
+func Test_Manager_DeliversSignedEvents(t *testing.T) {
+	assert := assert.New(t)
+	recv := newReceiver(t, "s3cret")
+	subs, _ := webhook.ParseSubscriptions(recv.URL + "|s3cret|POST_COUNT_CHANGED")
+	m := webhook.NewManager(webhook.Options{}, subs)
+	defer m.Close()
+
+	m.Publish(webhook.Event{ID: "ignored", Type: webhook.NameChanged, UserID: 1})
+	m.Publish(event)
+	d := waitForDelivery(t, m, webhook.StatusDelivered)
+	assert.Equal("config-1", d.SubscriptionID)
+	assert.Equal(1, d.Attempts)
+	assert.Equal(http.StatusNoContent, d.LastStatusCode)
+	assert.False(d.DeliveredAt.IsZero())
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	if assert.Len(recv.events, 1, "only subscribed events are delivered") {
+		assert.Equal(event, recv.events[0])
+		assert.Nil(recv.errs[0])
+	}
+	assert.Len(m.Deliveries("", "", 0), 1)
+}
+
+func Test_Manager_RetriesThenDeadLetters(t *testing.T) {
+	assert := assert.New(t)
+	recv := newReceiver(t, "s3cret", 500, 503, 500)
+	m := webhook.NewManager(webhook.Options{MaxAttempts: 3, Backoff: time.Millisecond}, nil)
+	defer m.Close()
+	sub, err := m.Subscribe(recv.URL, nil, "s3cret")
+	assert.Nil(err)
+
+	m.Publish(event)
+	dead := waitForDelivery(t, m, webhook.StatusDead)
+	assert.Equal(sub.ID, dead.SubscriptionID)
+	assert.Equal(3, dead.Attempts)
+	assert.Equal(500, dead.LastStatusCode)
+	assert.Equal("status code 500", dead.LastError)
+
+	// the receiver answers 204 from now on
+	redelivered, err := m.Redeliver(dead.ID)
+	assert.Nil(err)
+	assert.Equal(event.ID, redelivered.Event.ID)
+	d := waitForDelivery(t, m, webhook.StatusDelivered)
+	assert.Equal(redelivered.ID, d.ID)
+	assert.Empty(m.Deliveries("", webhook.StatusDead, 0), "redelivered dead letters leave the list")
+
+	_, err = m.Redeliver(dead.ID)
+	assert.ErrorIs(err, webhook.ErrNotFound)
+}
+
+func Test_Manager_ClientErrorsAreNotRetried(t *testing.T) {
+	assert := assert.New(t)
+	recv := newReceiver(t, "s3cret", 400)
+	m := webhook.NewManager(webhook.Options{MaxAttempts: 5, Backoff: time.Millisecond}, nil)
+	defer m.Close()
+	_, err := m.Subscribe(recv.URL, []webhook.EventType{webhook.PostCountChanged}, "s3cret")
+	assert.Nil(err)
+
+	m.Publish(event)
+	dead := waitForDelivery(t, m, webhook.StatusDead)
+	assert.Equal(1, dead.Attempts)
+	assert.Equal(int32(1), recv.calls.Load())
+}
+
+func Test_Manager_Unsubscribe(t *testing.T) {
+	assert := assert.New(t)
+	subs, _ := webhook.ParseSubscriptions("https://a.example|s")
+	m := webhook.NewManager(webhook.Options{}, subs)
+	defer m.Close()
+
+	sub, err := m.Subscribe("https://b.example", nil, "")
+	assert.Nil(err)
+	assert.NotEmpty(sub.Secret, "generated when omitted")
+	assert.Len(m.Subscriptions(), 2)
+
+	assert.NotNil(m.Unsubscribe("config-1"), "config webhooks can't be deleted")
+	assert.Nil(m.Unsubscribe(sub.ID))
+	assert.ErrorIs(m.Unsubscribe(sub.ID), webhook.ErrNotFound)
+	assert.Len(m.Subscriptions(), 1)
+}
+
+// users is a lister and fetcher of users whose posts can be changed.
+type users struct {
+	mu    sync.Mutex
+	posts map[int]int
+}
+
+func (u *users) List(ctx context.Context) ([]fetcher.User, error) {
+	return []fetcher.User{{ID: 1}, {ID: 2}}, nil
+}
+
+func (u *users) Fetch(ctx context.Context, userID int) (*fetcher.User, error) {
+	return &fetcher.User{ID: userID, Name: "user", Email: "user@example.com"}, nil
+}
+
+func (u *users) FetchPosts(ctx context.Context, userID int) ([]fetcher.Post, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return make([]fetcher.Post, u.posts[userID]), nil
+}
+
+type postsOf struct{ *users }
+
+func (p postsOf) Fetch(ctx context.Context, userID int) ([]fetcher.Post, error) {
+	return p.FetchPosts(ctx, userID)
+}
+
+func Test_Detector_PublishesChanges(t *testing.T) {
+	assert := assert.New(t)
+	u := &users{posts: map[int]int{1: 2, 2: 3}}
+	agg := aggregator.NewAggregator(u, postsOf{u}, time.Second)
+
+	var published []webhook.Event
+	d := &webhook.Detector{
+		Aggregator: func() *aggregator.Aggregator { return agg },
+		Publish:    func(ev webhook.Event) { published = append(published, ev) },
+	}
+
+	events, err := d.Check(context.Background())
+	assert.Nil(err)
+	assert.Empty(events, "the first check is the baseline")
+
+	u.mu.Lock()
+	u.posts[2] = 5
+	u.mu.Unlock()
+	events, err = d.Check(context.Background())
+	assert.Nil(err)
+	if assert.Len(events, 1) {
+		assert.Equal(webhook.PostCountChanged, events[0].Type)
+		assert.Equal(2, events[0].UserID)
+		assert.Equal(webhook.Summary{Name: "user", PostCount: 3}, events[0].Previous)
+		assert.Equal(webhook.Summary{Name: "user", PostCount: 5}, events[0].Current)
+	}
+	assert.Equal(events, published)
+
+	events, err = d.Check(context.Background())
+	assert.Nil(err)
+	assert.Empty(events)
+}
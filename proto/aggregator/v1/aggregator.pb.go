@@ -0,0 +1,463 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: aggregator/v1/aggregator.proto
+
+package aggregatorv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetUserSummaryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserSummaryRequest) Reset() {
+	*x = GetUserSummaryRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserSummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserSummaryRequest) ProtoMessage() {}
+
+func (x *GetUserSummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserSummaryRequest.ProtoReflect.Descriptor instead.
+func (*GetUserSummaryRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetUserSummaryRequest) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+type GetUserSummaryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       *UserSummary           `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserSummaryResponse) Reset() {
+	*x = GetUserSummaryResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserSummaryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserSummaryResponse) ProtoMessage() {}
+
+func (x *GetUserSummaryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserSummaryResponse.ProtoReflect.Descriptor instead.
+func (*GetUserSummaryResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetUserSummaryResponse) GetSummary() *UserSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type UserSummary struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Name   string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Only set for callers granted the read:pii scope.
+	Email         *string `protobuf:"bytes,3,opt,name=email,proto3,oneof" json:"email,omitempty"`
+	PostCount     int32   `protobuf:"varint,4,opt,name=post_count,json=postCount,proto3" json:"post_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserSummary) Reset() {
+	*x = UserSummary{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserSummary) ProtoMessage() {}
+
+func (x *UserSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserSummary.ProtoReflect.Descriptor instead.
+func (*UserSummary) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserSummary) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *UserSummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UserSummary) GetEmail() string {
+	if x != nil && x.Email != nil {
+		return *x.Email
+	}
+	return ""
+}
+
+func (x *UserSummary) GetPostCount() int32 {
+	if x != nil {
+		return x.PostCount
+	}
+	return 0
+}
+
+type BatchGetUserSummariesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// At most 100 IDs; duplicates are answered once.
+	UserIds       []int32 `protobuf:"varint,1,rep,packed,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetUserSummariesRequest) Reset() {
+	*x = BatchGetUserSummariesRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetUserSummariesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetUserSummariesRequest) ProtoMessage() {}
+
+func (x *BatchGetUserSummariesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetUserSummariesRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetUserSummariesRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchGetUserSummariesRequest) GetUserIds() []int32 {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+type BatchGetUserSummariesResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*BatchGetUserSummariesResponse_Summary
+	//	*BatchGetUserSummariesResponse_Error
+	Result        isBatchGetUserSummariesResponse_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetUserSummariesResponse) Reset() {
+	*x = BatchGetUserSummariesResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetUserSummariesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetUserSummariesResponse) ProtoMessage() {}
+
+func (x *BatchGetUserSummariesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetUserSummariesResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetUserSummariesResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetUserSummariesResponse) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *BatchGetUserSummariesResponse) GetResult() isBatchGetUserSummariesResponse_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *BatchGetUserSummariesResponse) GetSummary() *UserSummary {
+	if x != nil {
+		if x, ok := x.Result.(*BatchGetUserSummariesResponse_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+func (x *BatchGetUserSummariesResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Result.(*BatchGetUserSummariesResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isBatchGetUserSummariesResponse_Result interface {
+	isBatchGetUserSummariesResponse_Result()
+}
+
+type BatchGetUserSummariesResponse_Summary struct {
+	Summary *UserSummary `protobuf:"bytes,2,opt,name=summary,proto3,oneof"`
+}
+
+type BatchGetUserSummariesResponse_Error struct {
+	Error *Error `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*BatchGetUserSummariesResponse_Summary) isBatchGetUserSummariesResponse_Result() {}
+
+func (*BatchGetUserSummariesResponse_Error) isBatchGetUserSummariesResponse_Result() {}
+
+// Error is a per-item failure of a batch.
+type Error struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// gRPC status code name, e.g. "NOT_FOUND".
+	Code          string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_aggregator_v1_aggregator_proto protoreflect.FileDescriptor
+
+const file_aggregator_v1_aggregator_proto_rawDesc = "" +
+	"\n" +
+	"\x1eaggregator/v1/aggregator.proto\x12\raggregator.v1\"0\n" +
+	"\x15GetUserSummaryRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\"N\n" +
+	"\x16GetUserSummaryResponse\x124\n" +
+	"\asummary\x18\x01 \x01(\v2\x1a.aggregator.v1.UserSummaryR\asummary\"~\n" +
+	"\vUserSummary\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x19\n" +
+	"\x05email\x18\x03 \x01(\tH\x00R\x05email\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"post_count\x18\x04 \x01(\x05R\tpostCountB\b\n" +
+	"\x06_email\"9\n" +
+	"\x1cBatchGetUserSummariesRequest\x12\x19\n" +
+	"\buser_ids\x18\x01 \x03(\x05R\auserIds\"\xa8\x01\n" +
+	"\x1dBatchGetUserSummariesResponse\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\x126\n" +
+	"\asummary\x18\x02 \x01(\v2\x1a.aggregator.v1.UserSummaryH\x00R\asummary\x12,\n" +
+	"\x05error\x18\x03 \x01(\v2\x14.aggregator.v1.ErrorH\x00R\x05errorB\b\n" +
+	"\x06result\"5\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage2\xe8\x01\n" +
+	"\x11AggregatorService\x12]\n" +
+	"\x0eGetUserSummary\x12$.aggregator.v1.GetUserSummaryRequest\x1a%.aggregator.v1.GetUserSummaryResponse\x12t\n" +
+	"\x15BatchGetUserSummaries\x12+.aggregator.v1.BatchGetUserSummariesRequest\x1a,.aggregator.v1.BatchGetUserSummariesResponse0\x01B8Z6go-graphql-aggregator/proto/aggregator/v1;aggregatorv1b\x06proto3"
+
+var (
+	file_aggregator_v1_aggregator_proto_rawDescOnce sync.Once
+	file_aggregator_v1_aggregator_proto_rawDescData []byte
+)
+
+func file_aggregator_v1_aggregator_proto_rawDescGZIP() []byte {
+	file_aggregator_v1_aggregator_proto_rawDescOnce.Do(func() {
+		file_aggregator_v1_aggregator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_aggregator_v1_aggregator_proto_rawDesc), len(file_aggregator_v1_aggregator_proto_rawDesc)))
+	})
+	return file_aggregator_v1_aggregator_proto_rawDescData
+}
+
+var file_aggregator_v1_aggregator_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_aggregator_v1_aggregator_proto_goTypes = []any{
+	(*GetUserSummaryRequest)(nil),         // 0: aggregator.v1.GetUserSummaryRequest
+	(*GetUserSummaryResponse)(nil),        // 1: aggregator.v1.GetUserSummaryResponse
+	(*UserSummary)(nil),                   // 2: aggregator.v1.UserSummary
+	(*BatchGetUserSummariesRequest)(nil),  // 3: aggregator.v1.BatchGetUserSummariesRequest
+	(*BatchGetUserSummariesResponse)(nil), // 4: aggregator.v1.BatchGetUserSummariesResponse
+	(*Error)(nil),                         // 5: aggregator.v1.Error
+}
+var file_aggregator_v1_aggregator_proto_depIdxs = []int32{
+	2, // 0: aggregator.v1.GetUserSummaryResponse.summary:type_name -> aggregator.v1.UserSummary
+	2, // 1: aggregator.v1.BatchGetUserSummariesResponse.summary:type_name -> aggregator.v1.UserSummary
+	5, // 2: aggregator.v1.BatchGetUserSummariesResponse.error:type_name -> aggregator.v1.Error
+	0, // 3: aggregator.v1.AggregatorService.GetUserSummary:input_type -> aggregator.v1.GetUserSummaryRequest
+	3, // 4: aggregator.v1.AggregatorService.BatchGetUserSummaries:input_type -> aggregator.v1.BatchGetUserSummariesRequest
+	1, // 5: aggregator.v1.AggregatorService.GetUserSummary:output_type -> aggregator.v1.GetUserSummaryResponse
+	4, // 6: aggregator.v1.AggregatorService.BatchGetUserSummaries:output_type -> aggregator.v1.BatchGetUserSummariesResponse
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_aggregator_v1_aggregator_proto_init() }
+func file_aggregator_v1_aggregator_proto_init() {
+	if File_aggregator_v1_aggregator_proto != nil {
+		return
+	}
+	file_aggregator_v1_aggregator_proto_msgTypes[2].OneofWrappers = []any{}
+	file_aggregator_v1_aggregator_proto_msgTypes[4].OneofWrappers = []any{
+		(*BatchGetUserSummariesResponse_Summary)(nil),
+		(*BatchGetUserSummariesResponse_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_aggregator_v1_aggregator_proto_rawDesc), len(file_aggregator_v1_aggregator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_aggregator_v1_aggregator_proto_goTypes,
+		DependencyIndexes: file_aggregator_v1_aggregator_proto_depIdxs,
+		MessageInfos:      file_aggregator_v1_aggregator_proto_msgTypes,
+	}.Build()
+	File_aggregator_v1_aggregator_proto = out.File
+	file_aggregator_v1_aggregator_proto_goTypes = nil
+	file_aggregator_v1_aggregator_proto_depIdxs = nil
+}
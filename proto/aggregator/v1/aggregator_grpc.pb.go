@@ -0,0 +1,185 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: aggregator/v1/aggregator.proto
+
+package aggregatorv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AggregatorService_GetUserSummary_FullMethodName        = "/aggregator.v1.AggregatorService/GetUserSummary"
+	AggregatorService_BatchGetUserSummaries_FullMethodName = "/aggregator.v1.AggregatorService/BatchGetUserSummaries"
+)
+
+// AggregatorServiceClient is the client API for AggregatorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AggregatorService serves the user summaries of the GraphQL API to backend
+// services. Calls carry credentials in the "authorization: Bearer <token>" or
+// "x-api-key" metadata, like the HTTP API; the email is only returned to
+// callers granted the read:pii scope.
+type AggregatorServiceClient interface {
+	// GetUserSummary returns the summary of a user. Fails with NOT_FOUND for
+	// unknown users, INVALID_ARGUMENT for non-positive IDs, UNAVAILABLE when the
+	// upstreams fail and DEADLINE_EXCEEDED past the call deadline or the
+	// aggregator timeout, whichever comes first.
+	GetUserSummary(ctx context.Context, in *GetUserSummaryRequest, opts ...grpc.CallOption) (*GetUserSummaryResponse, error)
+	// BatchGetUserSummaries streams a result per requested user, in completion
+	// order. A failed user is reported in its result without ending the stream.
+	BatchGetUserSummaries(ctx context.Context, in *BatchGetUserSummariesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BatchGetUserSummariesResponse], error)
+}
+
+type aggregatorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAggregatorServiceClient(cc grpc.ClientConnInterface) AggregatorServiceClient {
+	return &aggregatorServiceClient{cc}
+}
+
+func (c *aggregatorServiceClient) GetUserSummary(ctx context.Context, in *GetUserSummaryRequest, opts ...grpc.CallOption) (*GetUserSummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserSummaryResponse)
+	err := c.cc.Invoke(ctx, AggregatorService_GetUserSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aggregatorServiceClient) BatchGetUserSummaries(ctx context.Context, in *BatchGetUserSummariesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BatchGetUserSummariesResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AggregatorService_ServiceDesc.Streams[0], AggregatorService_BatchGetUserSummaries_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchGetUserSummariesRequest, BatchGetUserSummariesResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AggregatorService_BatchGetUserSummariesClient = grpc.ServerStreamingClient[BatchGetUserSummariesResponse]
+
+// AggregatorServiceServer is the server API for AggregatorService service.
+// All implementations must embed UnimplementedAggregatorServiceServer
+// for forward compatibility.
+//
+// AggregatorService serves the user summaries of the GraphQL API to backend
+// services. Calls carry credentials in the "authorization: Bearer <token>" or
+// "x-api-key" metadata, like the HTTP API; the email is only returned to
+// callers granted the read:pii scope.
+type AggregatorServiceServer interface {
+	// GetUserSummary returns the summary of a user. Fails with NOT_FOUND for
+	// unknown users, INVALID_ARGUMENT for non-positive IDs, UNAVAILABLE when the
+	// upstreams fail and DEADLINE_EXCEEDED past the call deadline or the
+	// aggregator timeout, whichever comes first.
+	GetUserSummary(context.Context, *GetUserSummaryRequest) (*GetUserSummaryResponse, error)
+	// BatchGetUserSummaries streams a result per requested user, in completion
+	// order. A failed user is reported in its result without ending the stream.
+	BatchGetUserSummaries(*BatchGetUserSummariesRequest, grpc.ServerStreamingServer[BatchGetUserSummariesResponse]) error
+	mustEmbedUnimplementedAggregatorServiceServer()
+}
+
+// UnimplementedAggregatorServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAggregatorServiceServer struct{}
+
+func (UnimplementedAggregatorServiceServer) GetUserSummary(context.Context, *GetUserSummaryRequest) (*GetUserSummaryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserSummary not implemented")
+}
+func (UnimplementedAggregatorServiceServer) BatchGetUserSummaries(*BatchGetUserSummariesRequest, grpc.ServerStreamingServer[BatchGetUserSummariesResponse]) error {
+	return status.Error(codes.Unimplemented, "method BatchGetUserSummaries not implemented")
+}
+func (UnimplementedAggregatorServiceServer) mustEmbedUnimplementedAggregatorServiceServer() {}
+func (UnimplementedAggregatorServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeAggregatorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AggregatorServiceServer will
+// result in compilation errors.
+type UnsafeAggregatorServiceServer interface {
+	mustEmbedUnimplementedAggregatorServiceServer()
+}
+
+func RegisterAggregatorServiceServer(s grpc.ServiceRegistrar, srv AggregatorServiceServer) {
+	// If the following call panics, it indicates UnimplementedAggregatorServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AggregatorService_ServiceDesc, srv)
+}
+
+func _AggregatorService_GetUserSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AggregatorServiceServer).GetUserSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AggregatorService_GetUserSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AggregatorServiceServer).GetUserSummary(ctx, req.(*GetUserSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AggregatorService_BatchGetUserSummaries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchGetUserSummariesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AggregatorServiceServer).BatchGetUserSummaries(m, &grpc.GenericServerStream[BatchGetUserSummariesRequest, BatchGetUserSummariesResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AggregatorService_BatchGetUserSummariesServer = grpc.ServerStreamingServer[BatchGetUserSummariesResponse]
+
+// AggregatorService_ServiceDesc is the grpc.ServiceDesc for AggregatorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AggregatorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "aggregator.v1.AggregatorService",
+	HandlerType: (*AggregatorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUserSummary",
+			Handler:    _AggregatorService_GetUserSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchGetUserSummaries",
+			Handler:       _AggregatorService_BatchGetUserSummaries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "aggregator/v1/aggregator.proto",
+}